@@ -1,4 +1,6 @@
 go build -o build/ ./cmd/cli
+go build -o build/ ./cmd/admin
 go build -o build/ ./cmd/services/api-service
 go build -o build/ ./cmd/services/channel-service
-go build -o build/ ./cmd/services/news-service
\ No newline at end of file
+go build -o build/ ./cmd/services/news-service
+go build -o build/ ./cmd/webhook-receiver
\ No newline at end of file
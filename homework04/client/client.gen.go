@@ -0,0 +1,868 @@
+// Code generated by clientgen from openapi/openapi.json. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type AddChannelRequest struct {
+	Link string `json:"link"` // URL of the feed
+}
+
+type AddSearchRequest struct {
+	Name       string   `json:"name,omitempty"` // The query when empty
+	Query      string   `json:"query"`
+	Threshold  *float32 `json:"threshold,omitempty"` // Minimal cosine similarity, 0.75 when missing
+	WebhookURL string   `json:"webhook_url"`
+	Secret     string   `json:"secret,omitempty"`   // HMAC key used to sign webhook payloads
+	GroupID    int      `json:"group_id,omitempty"` // Alerts only for news of the channels in the group
+}
+
+type Channel struct {
+	ID           int           `json:"ID,omitempty"`
+	Link         string        `json:"Link,omitempty"` // URL of the channel
+	Title        string        `json:"Title,omitempty"`
+	Description  string        `json:"Description,omitempty"`
+	RSSLink      string        `json:"RSSLink,omitempty"`  // Link inside the RSS feed
+	UpdatedAt    time.Time     `json:"UpdatedAt,omitzero"` // Last time the feed was checked
+	Name         string        `json:"Name,omitempty"`     // Display name given by the user, the title is shown when empty
+	Paused       bool          `json:"Paused,omitempty"`
+	PollInterval int64         `json:"PollInterval,omitempty"` // Minimal time between checks in nanoseconds, the interval of channel-service when 0
+	FullArticles bool          `json:"FullArticles,omitempty"` // Articles are downloaded, otherwise the feed description is indexed
+	Failures     int           `json:"Failures,omitempty"`     // Consecutive failed fetches
+	LastSuccess  time.Time     `json:"LastSuccess,omitzero"`
+	Items        []ChannelNews `json:"Items,omitempty"`
+}
+
+// ChannelAuth sets the Authorization header of a channel.
+type ChannelAuth struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"` // Bearer token, instead of username and password
+}
+
+type ChannelFetch struct {
+	ID         int       `json:"ID,omitempty"`
+	ChannelID  int       `json:"ChannelID,omitempty"`
+	StartedAt  time.Time `json:"StartedAt,omitzero"`
+	Duration   int64     `json:"Duration,omitempty"`   // Nanoseconds
+	StatusCode int       `json:"StatusCode,omitempty"` // HTTP status, 0 when no response was received
+	Bytes      int       `json:"Bytes,omitempty"`
+	Items      int       `json:"Items,omitempty"`
+	NewItems   int       `json:"NewItems,omitempty"`
+	Error      string    `json:"Error,omitempty"` // Empty on success
+}
+
+type ChannelGroup struct {
+	ID         int    `json:"ID,omitempty"`
+	Name       string `json:"Name,omitempty"`
+	ChannelIDs []int  `json:"ChannelIDs,omitempty"`
+}
+
+type ChannelHealth struct {
+	ChannelID   int            `json:"ChannelID,omitempty"`
+	Status      ChannelStatus  `json:"Status,omitempty"`
+	Failures    int            `json:"Failures,omitempty"`
+	LastSuccess time.Time      `json:"LastSuccess,omitzero"`
+	LastError   string         `json:"LastError,omitempty"`
+	SuccessRate float64        `json:"SuccessRate,omitempty"`
+	Fetches     []ChannelFetch `json:"Fetches,omitempty"`
+}
+
+type ChannelNews struct {
+	ID          int        `json:"ID,omitempty"`
+	ChannelID   int        `json:"ChannelID,omitempty"`
+	Title       string     `json:"Title,omitempty"`
+	Link        string     `json:"Link,omitempty"`
+	Description string     `json:"Description,omitempty"`
+	Author      string     `json:"Author,omitempty"`
+	Category    string     `json:"Category,omitempty"`
+	PubDate     time.Time  `json:"PubDate,omitzero"`
+	GUID        string     `json:"GUID,omitempty"`
+	State       *NewsState `json:"State,omitempty"` // State of the user who listed the news, null in other lists
+}
+
+type ChannelPage struct {
+	Items      []Channel `json:"items"`
+	NextCursor string    `json:"next_cursor,omitempty"` // Cursor of the next page, missing on the last one
+}
+
+type ChannelStatus string
+
+const (
+	ChannelStatusUnknown ChannelStatus = "unknown"
+	ChannelStatusOK      ChannelStatus = "ok"
+	ChannelStatusFailing ChannelStatus = "failing"
+	ChannelStatusPaused  ChannelStatus = "paused"
+)
+
+type Created struct {
+	Status string `json:"status"`
+	ID     int    `json:"id"`
+}
+
+type GroupRequest struct {
+	Name string `json:"name"`
+}
+
+type ImportReport struct {
+	Added      int            `json:"added"`
+	Duplicates int            `json:"duplicates"`
+	Failed     int            `json:"failed"`
+	Feeds      []ImportedFeed `json:"feeds"`
+}
+
+type ImportedFeed struct {
+	URL       string `json:"url"`
+	Title     string `json:"title,omitempty"`
+	Group     string `json:"group,omitempty"`
+	Status    string `json:"status"`
+	ChannelID int    `json:"channel_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type JobPage struct {
+	Items      []NewsJob `json:"items"`
+	NextCursor string    `json:"next_cursor,omitempty"` // Cursor of the next page, missing on the last one
+}
+
+type JobStatus string
+
+const (
+	JobStatusPending   JobStatus = "pending"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusDead      JobStatus = "dead"
+)
+
+type Message struct {
+	Message string `json:"message"`
+}
+
+type NewsContent struct {
+	NewsID    int       `json:"NewsID,omitempty"`
+	Title     string    `json:"Title,omitempty"`
+	Byline    string    `json:"Byline,omitempty"`
+	Excerpt   string    `json:"Excerpt,omitempty"`
+	SiteName  string    `json:"SiteName,omitempty"`
+	Image     string    `json:"Image,omitempty"`
+	Length    int       `json:"Length,omitempty"`
+	HTML      string    `json:"HTML,omitempty"`
+	Text      string    `json:"Text,omitempty"`
+	FetchedAt time.Time `json:"FetchedAt,omitzero"`
+}
+
+type NewsJob struct {
+	ID          int       `json:"ID,omitempty"`
+	Link        string    `json:"Link,omitempty"`
+	Status      JobStatus `json:"Status,omitempty"`
+	Attempts    int       `json:"Attempts,omitempty"`
+	LastError   string    `json:"LastError,omitempty"`
+	NextRunAt   time.Time `json:"NextRunAt,omitzero"`
+	LockedUntil time.Time `json:"LockedUntil,omitzero"`
+	CreatedAt   time.Time `json:"CreatedAt,omitzero"`
+	UpdatedAt   time.Time `json:"UpdatedAt,omitzero"`
+}
+
+type NewsPage struct {
+	Items      []ChannelNews `json:"items"`
+	NextCursor string        `json:"next_cursor,omitempty"` // Cursor of the next page, missing on the last one
+}
+
+type NewsState struct {
+	Read    bool `json:"Read,omitempty"`
+	Starred bool `json:"Starred,omitempty"`
+}
+
+// NewsStateRequest changes only the fields it contains.
+type NewsStateRequest struct {
+	Read    *bool `json:"read,omitempty"`
+	Starred *bool `json:"starred,omitempty"`
+}
+
+// Problem is an error response (RFC 9457).
+type Problem struct {
+	Type     string `json:"type"` // urn:rss-fetcher:problem:<code>
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"` // Path of the request
+	Code     string `json:"code"`               // Stable error code, e.g. channel_not_found
+}
+
+type QueryResponse struct {
+	Query    string `json:"query"`
+	Response string `json:"response"`
+}
+
+type SavedSearch struct {
+	ID         int       `json:"ID,omitempty"`
+	Name       string    `json:"Name,omitempty"`
+	Query      string    `json:"Query,omitempty"`
+	Threshold  float32   `json:"Threshold,omitempty"`
+	WebhookURL string    `json:"WebhookURL,omitempty"`
+	GroupID    int       `json:"GroupID,omitempty"`
+	CreatedAt  time.Time `json:"CreatedAt,omitzero"`
+}
+
+type Status struct {
+	Status string `json:"status"`
+}
+
+// UpdateChannelRequest changes only the fields it contains.
+type UpdateChannelRequest struct {
+	Link         *string            `json:"link,omitempty"`
+	Name         *string            `json:"name,omitempty"` // Display name, the title is shown when empty
+	Paused       *bool              `json:"paused,omitempty"`
+	PollInterval *string            `json:"poll_interval,omitempty"` // Duration like 30m, 0 uses the interval of channel-service
+	FullArticles *bool              `json:"full_articles,omitempty"`
+	Headers      *map[string]string `json:"headers,omitempty"` // Replaces all custom headers
+	Auth         *ChannelAuth       `json:"auth,omitempty"`
+}
+
+// DeleteChannels deletes all channels with their news.
+//
+// DELETE /api/v1/channels
+func (c *Client) DeleteChannels(ctx context.Context) (*Message, error) {
+	req := request{method: "DELETE", path: "/api/v1/channels"}
+	var result Message
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListChannelsParams are the query parameters of ListChannels, zero values are not sent.
+type ListChannelsParams struct {
+	Limit  int    // Number of items, 50 by default
+	Cursor string // next_cursor of the previous page
+}
+
+// ListChannels lists channels in the order they were added, a page at a time.
+//
+// GET /api/v1/channels
+func (c *Client) ListChannels(ctx context.Context, params *ListChannelsParams) (*ChannelPage, error) {
+	req := request{method: "GET", path: "/api/v1/channels"}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Limit != 0 {
+			req.query.Set("limit", strconv.Itoa(params.Limit))
+		}
+		if params.Cursor != "" {
+			req.query.Set("cursor", params.Cursor)
+		}
+	}
+	var result ChannelPage
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AddChannel adds a feed, it is fetched by channel-service.
+//
+// POST /api/v1/channels
+func (c *Client) AddChannel(ctx context.Context, body AddChannelRequest) (*Status, error) {
+	req := request{method: "POST", path: "/api/v1/channels"}
+	var err error
+	if req.body, err = jsonBody(body); err != nil {
+		return nil, err
+	}
+	req.contentType = "application/json"
+	var result Status
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ExportChannels returns all channels as an OPML file, nested in the folders of their groups.
+//
+// GET /api/v1/channels/export
+func (c *Client) ExportChannels(ctx context.Context) ([]byte, error) {
+	req := request{method: "GET", path: "/api/v1/channels/export"}
+	var result []byte
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ImportChannels adds the feeds of an OPML file, folders become channel groups.
+//
+// POST /api/v1/channels/import
+func (c *Client) ImportChannels(ctx context.Context, body io.Reader) (*ImportReport, error) {
+	req := request{method: "POST", path: "/api/v1/channels/import"}
+	req.body, req.contentType = body, "text/x-opml"
+	var result ImportReport
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteChannel deletes a channel with its news.
+//
+// DELETE /api/v1/channels/{id}
+func (c *Client) DeleteChannel(ctx context.Context, id int) (*Message, error) {
+	req := request{method: "DELETE", path: "/api/v1/channels/" + url.PathEscape(strconv.Itoa(id))}
+	var result Message
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetChannel returns a channel with its news.
+//
+// GET /api/v1/channels/{id}
+func (c *Client) GetChannel(ctx context.Context, id int) (*Channel, error) {
+	req := request{method: "GET", path: "/api/v1/channels/" + url.PathEscape(strconv.Itoa(id))}
+	var result Channel
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdateChannel changes the link or the settings of a channel, its news are kept.
+//
+// PATCH /api/v1/channels/{id}
+func (c *Client) UpdateChannel(ctx context.Context, id int, body UpdateChannelRequest) (*Channel, error) {
+	req := request{method: "PATCH", path: "/api/v1/channels/" + url.PathEscape(strconv.Itoa(id))}
+	var err error
+	if req.body, err = jsonBody(body); err != nil {
+		return nil, err
+	}
+	req.contentType = "application/json"
+	var result Channel
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetChannelHealthParams are the query parameters of GetChannelHealth, zero values are not sent.
+type GetChannelHealthParams struct {
+	Limit int // Number of items, 50 by default
+}
+
+// GetChannelHealth reports the consecutive failures of a channel with its latest fetches.
+//
+// GET /api/v1/channels/{id}/health
+func (c *Client) GetChannelHealth(ctx context.Context, id int, params *GetChannelHealthParams) (*ChannelHealth, error) {
+	req := request{method: "GET", path: "/api/v1/channels/" + url.PathEscape(strconv.Itoa(id)) + "/health"}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Limit != 0 {
+			req.query.Set("limit", strconv.Itoa(params.Limit))
+		}
+	}
+	var result ChannelHealth
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListGroups lists all groups with the ids of their channels, ordered by name.
+//
+// GET /api/v1/groups
+func (c *Client) ListGroups(ctx context.Context) ([]ChannelGroup, error) {
+	req := request{method: "GET", path: "/api/v1/groups"}
+	var result []ChannelGroup
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// AddGroup creates an empty group of channels.
+//
+// POST /api/v1/groups
+func (c *Client) AddGroup(ctx context.Context, body GroupRequest) (*ChannelGroup, error) {
+	req := request{method: "POST", path: "/api/v1/groups"}
+	var err error
+	if req.body, err = jsonBody(body); err != nil {
+		return nil, err
+	}
+	req.contentType = "application/json"
+	var result ChannelGroup
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteGroup deletes a group and the saved searches scoped to it, the channels are kept.
+//
+// DELETE /api/v1/groups/{id}
+func (c *Client) DeleteGroup(ctx context.Context, id int) (*Message, error) {
+	req := request{method: "DELETE", path: "/api/v1/groups/" + url.PathEscape(strconv.Itoa(id))}
+	var result Message
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetGroup returns a group with the ids of its channels.
+//
+// GET /api/v1/groups/{id}
+func (c *Client) GetGroup(ctx context.Context, id int) (*ChannelGroup, error) {
+	req := request{method: "GET", path: "/api/v1/groups/" + url.PathEscape(strconv.Itoa(id))}
+	var result ChannelGroup
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdateGroup renames a group.
+//
+// PATCH /api/v1/groups/{id}
+func (c *Client) UpdateGroup(ctx context.Context, id int, body GroupRequest) (*ChannelGroup, error) {
+	req := request{method: "PATCH", path: "/api/v1/groups/" + url.PathEscape(strconv.Itoa(id))}
+	var err error
+	if req.body, err = jsonBody(body); err != nil {
+		return nil, err
+	}
+	req.contentType = "application/json"
+	var result ChannelGroup
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteGroupChannel takes a channel out of a group, the channel is kept.
+//
+// DELETE /api/v1/groups/{id}/channels/{channel}
+func (c *Client) DeleteGroupChannel(ctx context.Context, id int, channel int) (*Message, error) {
+	req := request{method: "DELETE", path: "/api/v1/groups/" + url.PathEscape(strconv.Itoa(id)) + "/channels/" + url.PathEscape(strconv.Itoa(channel))}
+	var result Message
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AddGroupChannel puts a channel into a group.
+//
+// PUT /api/v1/groups/{id}/channels/{channel}
+func (c *Client) AddGroupChannel(ctx context.Context, id int, channel int) (*Message, error) {
+	req := request{method: "PUT", path: "/api/v1/groups/" + url.PathEscape(strconv.Itoa(id)) + "/channels/" + url.PathEscape(strconv.Itoa(channel))}
+	var result Message
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListJobsParams are the query parameters of ListJobs, zero values are not sent.
+type ListJobsParams struct {
+	Limit  int       // Number of items, 50 by default
+	Cursor string    // next_cursor of the previous page
+	Status JobStatus // Only jobs with the status
+}
+
+// ListJobs lists article download jobs in the order they were queued, a page at a time.
+//
+// GET /api/v1/jobs
+func (c *Client) ListJobs(ctx context.Context, params *ListJobsParams) (*JobPage, error) {
+	req := request{method: "GET", path: "/api/v1/jobs"}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Limit != 0 {
+			req.query.Set("limit", strconv.Itoa(params.Limit))
+		}
+		if params.Cursor != "" {
+			req.query.Set("cursor", params.Cursor)
+		}
+		if params.Status != "" {
+			req.query.Set("status", string(params.Status))
+		}
+	}
+	var result JobPage
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListNewsParams are the query parameters of ListNews, zero values are not sent.
+type ListNewsParams struct {
+	Limit    int    // Number of items, 50 by default
+	Cursor   string // next_cursor of the previous page
+	Sort     string // Order of the news, by id unless given
+	Channel  int    // Only news of the channel
+	Group    int    // Only the channels in the group
+	From     string // Published at or after, a date (2006-01-02) or an RFC 3339 timestamp
+	To       string // Published before, a date (2006-01-02) or an RFC 3339 timestamp
+	Author   string // Only news of the author
+	Category string // Only news in the category
+	Title    string // Only news whose title contains the text
+	Unread   bool   // Only news the user has not read, needs the credentials of a user
+	Starred  bool   // Only news the user starred, needs the credentials of a user
+}
+
+// ListNews lists news a page at a time, sorted and filtered.
+//
+// GET /api/v1/news
+func (c *Client) ListNews(ctx context.Context, params *ListNewsParams) (*NewsPage, error) {
+	req := request{method: "GET", path: "/api/v1/news"}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Limit != 0 {
+			req.query.Set("limit", strconv.Itoa(params.Limit))
+		}
+		if params.Cursor != "" {
+			req.query.Set("cursor", params.Cursor)
+		}
+		if params.Sort != "" {
+			req.query.Set("sort", params.Sort)
+		}
+		if params.Channel != 0 {
+			req.query.Set("channel", strconv.Itoa(params.Channel))
+		}
+		if params.Group != 0 {
+			req.query.Set("group", strconv.Itoa(params.Group))
+		}
+		if params.From != "" {
+			req.query.Set("from", params.From)
+		}
+		if params.To != "" {
+			req.query.Set("to", params.To)
+		}
+		if params.Author != "" {
+			req.query.Set("author", params.Author)
+		}
+		if params.Category != "" {
+			req.query.Set("category", params.Category)
+		}
+		if params.Title != "" {
+			req.query.Set("title", params.Title)
+		}
+		if params.Unread {
+			req.query.Set("unread", strconv.FormatBool(params.Unread))
+		}
+		if params.Starred {
+			req.query.Set("starred", strconv.FormatBool(params.Starred))
+		}
+	}
+	var result NewsPage
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteNews deletes a news item with its indexed article.
+//
+// DELETE /api/v1/news/{id}
+func (c *Client) DeleteNews(ctx context.Context, id int) (*Message, error) {
+	req := request{method: "DELETE", path: "/api/v1/news/" + url.PathEscape(strconv.Itoa(id))}
+	var result Message
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetNewsContentParams are the query parameters of GetNewsContent, zero values are not sent.
+type GetNewsContentParams struct {
+	Format string // json by default, html or text for a reader view
+}
+
+// GetNewsContent returns the readable version of an article.
+//
+// GET /api/v1/news/{id}/content
+func (c *Client) GetNewsContent(ctx context.Context, id int, params *GetNewsContentParams) (*NewsContent, error) {
+	req := request{method: "GET", path: "/api/v1/news/" + url.PathEscape(strconv.Itoa(id)) + "/content"}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Format != "" {
+			req.query.Set("format", params.Format)
+		}
+	}
+	var result NewsContent
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdateNewsState marks a news item of the subscriptions of the user as read or starred, only the fields sent are changed.
+//
+// PATCH /api/v1/news/{id}/state
+func (c *Client) UpdateNewsState(ctx context.Context, id int, body NewsStateRequest) (*NewsState, error) {
+	req := request{method: "PATCH", path: "/api/v1/news/" + url.PathEscape(strconv.Itoa(id)) + "/state"}
+	var err error
+	if req.body, err = jsonBody(body); err != nil {
+		return nil, err
+	}
+	req.contentType = "application/json"
+	var result NewsState
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetOpenAPI returns this specification.
+//
+// GET /api/v1/openapi.json
+func (c *Client) GetOpenAPI(ctx context.Context) ([]byte, error) {
+	req := request{method: "GET", path: "/api/v1/openapi.json"}
+	var result []byte
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// QueryParams are the query parameters of Query, zero values are not sent.
+type QueryParams struct {
+	Group int // Only the channels in the group
+}
+
+// Query answers a question from the closest chunks of the indexed news.
+//
+// GET /api/v1/query/{q}
+func (c *Client) Query(ctx context.Context, q string, params *QueryParams) (*QueryResponse, error) {
+	req := request{method: "GET", path: "/api/v1/query/" + url.PathEscape(q)}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Group != 0 {
+			req.query.Set("group", strconv.Itoa(params.Group))
+		}
+	}
+	var result QueryResponse
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListSearches lists the saved searches.
+//
+// GET /api/v1/searches
+func (c *Client) ListSearches(ctx context.Context) ([]SavedSearch, error) {
+	req := request{method: "GET", path: "/api/v1/searches"}
+	var result []SavedSearch
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// AddSearch saves a search, new news matching it are posted to the webhook.
+//
+// POST /api/v1/searches
+func (c *Client) AddSearch(ctx context.Context, body AddSearchRequest) (*Created, error) {
+	req := request{method: "POST", path: "/api/v1/searches"}
+	var err error
+	if req.body, err = jsonBody(body); err != nil {
+		return nil, err
+	}
+	req.contentType = "application/json"
+	var result Created
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteSearch deletes a saved search with its alerts.
+//
+// DELETE /api/v1/searches/{id}
+func (c *Client) DeleteSearch(ctx context.Context, id int) (*Message, error) {
+	req := request{method: "DELETE", path: "/api/v1/searches/" + url.PathEscape(strconv.Itoa(id))}
+	var result Message
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListSubscriptionsParams are the query parameters of ListSubscriptions, zero values are not sent.
+type ListSubscriptionsParams struct {
+	Limit  int    // Number of items, 50 by default
+	Cursor string // next_cursor of the previous page
+}
+
+// ListSubscriptions lists the channels the user subscribes to, a page at a time.
+//
+// GET /api/v1/subscriptions
+func (c *Client) ListSubscriptions(ctx context.Context, params *ListSubscriptionsParams) (*ChannelPage, error) {
+	req := request{method: "GET", path: "/api/v1/subscriptions"}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Limit != 0 {
+			req.query.Set("limit", strconv.Itoa(params.Limit))
+		}
+		if params.Cursor != "" {
+			req.query.Set("cursor", params.Cursor)
+		}
+	}
+	var result ChannelPage
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AddSubscription subscribes the user to a feed, a channel missing for the feed is only added with the admin scope.
+//
+// POST /api/v1/subscriptions
+func (c *Client) AddSubscription(ctx context.Context, body AddChannelRequest) (*Channel, error) {
+	req := request{method: "POST", path: "/api/v1/subscriptions"}
+	var err error
+	if req.body, err = jsonBody(body); err != nil {
+		return nil, err
+	}
+	req.contentType = "application/json"
+	var result Channel
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UnsubscribeChannel unsubscribes the user from a channel, the channel and its news are kept.
+//
+// DELETE /api/v1/subscriptions/{channel}
+func (c *Client) UnsubscribeChannel(ctx context.Context, channel int) (*Message, error) {
+	req := request{method: "DELETE", path: "/api/v1/subscriptions/" + url.PathEscape(strconv.Itoa(channel))}
+	var result Message
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SubscribeChannel subscribes the user to a channel.
+//
+// PUT /api/v1/subscriptions/{channel}
+func (c *Client) SubscribeChannel(ctx context.Context, channel int) (*Message, error) {
+	req := request{method: "PUT", path: "/api/v1/subscriptions/" + url.PathEscape(strconv.Itoa(channel))}
+	var result Message
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetGroupFeedParams are the query parameters of GetGroupFeed, zero values are not sent.
+type GetGroupFeedParams struct {
+	Limit   int  // Number of items, 50 by default
+	Summary bool // Replaces descriptions with LLM summaries
+}
+
+// GetGroupFeed serves the latest news of the channels in a group, <id>.xml or <id>.atom.
+//
+// GET /feeds/groups/{feed}
+func (c *Client) GetGroupFeed(ctx context.Context, feed string, params *GetGroupFeedParams) ([]byte, error) {
+	req := request{method: "GET", path: "/feeds/groups/" + url.PathEscape(feed)}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Limit != 0 {
+			req.query.Set("limit", strconv.Itoa(params.Limit))
+		}
+		if params.Summary {
+			req.query.Set("summary", strconv.FormatBool(params.Summary))
+		}
+	}
+	var result []byte
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetSearchFeedParams are the query parameters of GetSearchFeed, zero values are not sent.
+type GetSearchFeedParams struct {
+	Limit   int  // Number of items, 50 by default
+	Summary bool // Replaces descriptions with LLM summaries
+}
+
+// GetSearchFeed serves the news which matched a saved search, <id>.xml or <id>.atom.
+//
+// GET /feeds/searches/{feed}
+func (c *Client) GetSearchFeed(ctx context.Context, feed string, params *GetSearchFeedParams) ([]byte, error) {
+	req := request{method: "GET", path: "/feeds/searches/" + url.PathEscape(feed)}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Limit != 0 {
+			req.query.Set("limit", strconv.Itoa(params.Limit))
+		}
+		if params.Summary {
+			req.query.Set("summary", strconv.FormatBool(params.Summary))
+		}
+	}
+	var result []byte
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetTagFeedParams are the query parameters of GetTagFeed, zero values are not sent.
+type GetTagFeedParams struct {
+	Limit   int  // Number of items, 50 by default
+	Summary bool // Replaces descriptions with LLM summaries
+}
+
+// GetTagFeed serves the latest news of a category, <category>.xml or <category>.atom.
+//
+// GET /feeds/tags/{feed}
+func (c *Client) GetTagFeed(ctx context.Context, feed string, params *GetTagFeedParams) ([]byte, error) {
+	req := request{method: "GET", path: "/feeds/tags/" + url.PathEscape(feed)}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Limit != 0 {
+			req.query.Set("limit", strconv.Itoa(params.Limit))
+		}
+		if params.Summary {
+			req.query.Set("summary", strconv.FormatBool(params.Summary))
+		}
+	}
+	var result []byte
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetAllFeedParams are the query parameters of GetAllFeed, zero values are not sent.
+type GetAllFeedParams struct {
+	Limit   int  // Number of items, 50 by default
+	Summary bool // Replaces descriptions with LLM summaries
+}
+
+// GetAllFeed serves the latest news of all channels, all.xml or all.atom.
+//
+// GET /feeds/{feed}
+func (c *Client) GetAllFeed(ctx context.Context, feed string, params *GetAllFeedParams) ([]byte, error) {
+	req := request{method: "GET", path: "/feeds/" + url.PathEscape(feed)}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Limit != 0 {
+			req.query.Set("limit", strconv.Itoa(params.Limit))
+		}
+		if params.Summary {
+			req.query.Set("summary", strconv.FormatBool(params.Summary))
+		}
+	}
+	var result []byte
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
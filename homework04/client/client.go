@@ -0,0 +1,146 @@
+// Package client is the Go client of the REST API of api-service. The types and the
+// methods of client.gen.go are generated from openapi/openapi.json, run go generate
+// ./client after changing the specification.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//go:generate go run ../cmd/clientgen -out client.gen.go
+
+// Client calls the API of one api-service.
+type Client struct {
+	baseURL string // e.g. http://localhost:8080, paths of the specification are appended
+	http    *http.Client
+	header  http.Header // sent with every request
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with another client than http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) { c.http = client }
+}
+
+// WithHeader sends the header with every request.
+func WithHeader(name, value string) Option {
+	return func(c *Client) { c.header.Set(name, value) }
+}
+
+// WithAPIKey authenticates every request with an API key of api-service.
+func WithAPIKey(key string) Option {
+	return WithHeader("X-API-Key", key)
+}
+
+// WithToken authenticates every request with a bearer token.
+func WithToken(token string) Option {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    http.DefaultClient,
+		header:  make(http.Header),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Error returns the code and the detail of the problem, the API answers every error with one.
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return fmt.Sprintf("%s (%d)", p.Code, p.Status)
+	}
+	return fmt.Sprintf("%s (%d): %s", p.Code, p.Status, p.Detail)
+}
+
+// request is one call of a generated method.
+type request struct {
+	method      string
+	path        string // path parameters are already escaped
+	query       url.Values
+	body        io.Reader
+	contentType string
+}
+
+// jsonBody encodes the body of a request.
+func jsonBody(value any) (io.Reader, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("encode request: %w", err)
+	}
+	return bytes.NewReader(encoded), nil
+}
+
+// do sends the request and decodes a JSON response into result, *[]byte receives the
+// raw body. Responses with an error status are returned as *Problem.
+func (c *Client) do(ctx context.Context, r request, result any) error {
+	target := c.baseURL + r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, target, r.body)
+	if err != nil {
+		return err
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return responseProblem(resp, body)
+	}
+	switch result := result.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		*result = body
+		return nil
+	default:
+		if err := json.Unmarshal(body, result); err != nil {
+			return fmt.Errorf("decode response of %s %s: %w", r.method, r.path, err)
+		}
+		return nil
+	}
+}
+
+// responseProblem decodes the problem of an error response, responses of proxies
+// without a problem body get one made from the status.
+func responseProblem(resp *http.Response, body []byte) error {
+	problem := &Problem{Status: resp.StatusCode}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" || mediaType == "application/json" {
+		if json.Unmarshal(body, problem) == nil && problem.Code != "" {
+			return problem
+		}
+	}
+	problem.Title = http.StatusText(resp.StatusCode)
+	problem.Code = strings.ReplaceAll(strings.ToLower(problem.Title), " ", "_")
+	problem.Detail = strings.TrimSpace(string(body))
+	return problem
+}
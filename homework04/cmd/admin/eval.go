@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/internal/db"
+	"rss_fetcher/internal/embedding"
+	"rss_fetcher/internal/eval"
+	"rss_fetcher/internal/parser"
+	"rss_fetcher/internal/rag"
+	"strings"
+	"time"
+
+	backend "rss_fetcher/internal/ollama"
+)
+
+const (
+	genDefaultModel    = "llama3"
+	defaultHashingDims = 512
+	generateTimeout    = 60 * time.Second
+)
+
+// specList collects the values of a repeated flag.
+type specList []string
+
+func (l *specList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *specList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func evaluate(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("eval", flag.ExitOnError)
+	dbParams := flags.String("db", defaultConnection, "Database URL: Postgres connection string or sqlite://path")
+	ollamaConnection := flags.String("ollama", ollamaDefaultConnection, "Ollama server URL")
+	questionsPath := flags.String("questions", "", "Evaluation set: YAML list or JSONL of {question, links}")
+	profileNames := flags.String("profiles", "", "Comma separated profiles to evaluate, the active and building ones when empty")
+	fixturePath := flags.String("fixture", "", "Evaluate offline: index this YAML/JSON corpus in memory with the hashing embedder")
+	var chunkers specList
+	flags.Var(&chunkers, "chunker", "Fixture: chunker to evaluate, repeat the flag to compare several")
+	contextual := flags.Bool("contextual", false, "Fixture: embed chunks with contextual headers")
+	hashingDims := flags.Int("hashing-dims", defaultHashingDims, "Fixture: dimension of the hashing embedder")
+	k := flags.Int("k", 5, "Number of retrieved articles scored per question")
+	contextChunks := flags.Int("context-chunks", rag.DefaultContextChunks, "Chunks passed to the generative model, as configured for the API")
+	genModel := flags.String("gen", genDefaultModel, "Generative model answering the questions")
+	judgeModel := flags.String("judge", "", "Model judging the faithfulness of the answers, answers are not generated when empty")
+	minRecall := flags.Float64("min-recall", 0, "Fail when the recall of a profile is lower")
+	minMRR := flags.Float64("min-mrr", 0, "Fail when the MRR of a profile is lower")
+	minNDCG := flags.Float64("min-ndcg", 0, "Fail when the nDCG of a profile is lower")
+	verbose := flags.Bool("v", false, "Print the questions whose articles were not retrieved")
+	flags.Parse(args)
+
+	if *questionsPath == "" {
+		return fmt.Errorf("--questions is required")
+	}
+	questions, err := eval.LoadQuestions(*questionsPath)
+	if err != nil {
+		return err
+	}
+
+	evaluator := &eval.Evaluator{K: *k, ContextChunks: max(*contextChunks, 1)}
+	if *judgeModel != "" {
+		evaluator.Generator = backend.NewOllamaBackend(*ollamaConnection, *genModel, generateTimeout)
+		evaluator.Judge = backend.NewOllamaBackend(*ollamaConnection, *judgeModel, generateTimeout)
+	}
+
+	var reports []eval.Report
+	if *fixturePath != "" {
+		if len(chunkers) == 0 {
+			chunkers = specList{parser.DefaultChunker}
+		}
+		reports, err = evaluateFixture(ctx, evaluator, questions, *fixturePath, chunkers, *contextual, *hashingDims)
+	} else {
+		reports, err = evaluateProfiles(ctx, evaluator, questions, *dbParams, *ollamaConnection, *profileNames)
+	}
+	if err != nil {
+		return err
+	}
+
+	printReports(reports, *k, *verbose)
+
+	for _, report := range reports {
+		if report.Recall < *minRecall || report.MRR < *minMRR || report.NDCG < *minNDCG {
+			return fmt.Errorf("profile %s is below the required quality", report.Profile.Name)
+		}
+	}
+	return nil
+}
+
+func evaluateProfiles(ctx context.Context, evaluator *eval.Evaluator, questions []eval.Question, dbParams, ollamaConnection, profileNames string) ([]eval.Report, error) {
+	store, err := db.Open(ctx, dbParams)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	profiles, err := store.LoadProfiles(ctx, data.ProfileActive, data.ProfileBuilding)
+	if err != nil {
+		return nil, err
+	}
+	if profileNames != "" {
+		profiles = profiles[:0]
+		for _, name := range strings.Split(profileNames, ",") {
+			profile, err := store.LoadProfileByName(ctx, strings.TrimSpace(name))
+			if err != nil {
+				return nil, err
+			}
+			if profile == nil {
+				return nil, fmt.Errorf("profile %s not found", name)
+			}
+			profiles = append(profiles, *profile)
+		}
+	}
+
+	evaluator.Pipeline = rag.New(store, embedding.NewOllama(ollamaConnection, embedRequestTimeout, 1, 1))
+	evaluator.Links = eval.NewStoreLinks(store)
+
+	reports := make([]eval.Report, 0, len(profiles))
+	for _, profile := range profiles {
+		report, err := evaluator.Run(ctx, profile, questions)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", profile.Name, err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func evaluateFixture(ctx context.Context, evaluator *eval.Evaluator, questions []eval.Question, path string, chunkers []string, contextual bool, dims int) ([]eval.Report, error) {
+	fixture, err := eval.LoadFixture(path)
+	if err != nil {
+		return nil, err
+	}
+	embedders := embedding.NewHashing(dims)
+	store, err := fixture.Store(ctx)
+	if err != nil {
+		return nil, err
+	}
+	evaluator.Pipeline = rag.New(store, embedders)
+	evaluator.Links = eval.NewStoreLinks(store)
+
+	reports := make([]eval.Report, 0, len(chunkers))
+	for i, spec := range chunkers {
+		chunker, err := parser.NewChunker(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chunker %q: %w", spec, err)
+		}
+		profile, err := fixture.Index(ctx, store, embedders, data.EmbeddingProfile{
+			Name:       fmt.Sprintf("fixture-%d", i+1),
+			Model:      embedding.HashingModel,
+			Dims:       dims,
+			Status:     data.ProfileBuilding,
+			Chunker:    chunker.Name(),
+			Contextual: contextual,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		report, err := evaluator.Run(ctx, *profile, questions)
+		if err != nil {
+			return nil, fmt.Errorf("chunker %s: %w", profile.Chunker, err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func printReports(reports []eval.Report, k int, verbose bool) {
+	fmt.Printf("%-24s %-10s %-48s %9s %6s %7s %9s\n", "PROFILE", "CONTEXTUAL", "CHUNKER",
+		fmt.Sprintf("RECALL@%d", k), "MRR", fmt.Sprintf("NDCG@%d", k), "FAITHFUL")
+	for _, report := range reports {
+		faithfulness := "-"
+		if report.Judged {
+			faithfulness = fmt.Sprintf("%.3f", report.Faithfulness)
+		}
+		fmt.Printf("%-24s %-10t %-48s %9.3f %6.3f %7.3f %9s\n", report.Profile.Name, report.Profile.Contextual, report.Profile.Chunker,
+			report.Recall, report.MRR, report.NDCG, faithfulness)
+		if !verbose {
+			continue
+		}
+		for _, result := range report.Results {
+			if result.Rank == 0 {
+				fmt.Printf("    missed: %s\n", result.Question.Question)
+			}
+		}
+	}
+}
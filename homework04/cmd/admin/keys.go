@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"rss_fetcher/internal/auth"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/internal/db"
+	"time"
+)
+
+const defaultTokenTTL = time.Hour
+
+func keys(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("keys command requires one of: create, list, revoke")
+	}
+	command, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet("keys "+command, flag.ExitOnError)
+	dbParams := flags.String("db", defaultConnection, "Database URL: Postgres connection string or sqlite://path")
+	var name, scope, user *string
+	var id *int
+	switch command {
+	case "create":
+		name = flags.String("name", "", "Who or what uses the key, e.g. ci or alice")
+		scope = flags.String("scope", string(data.ScopeRead), "read (GET requests) or admin (every request)")
+		user = flags.String("user", "", "Name of the user the key acts for, see users create")
+	case "list":
+	case "revoke":
+		id = flags.Int("id", 0, "ID of the key, see keys list")
+	default:
+		return fmt.Errorf("unknown keys command: %s", command)
+	}
+	flags.Parse(args)
+
+	store, err := db.Open(ctx, *dbParams)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	switch command {
+	case "create":
+		key := data.APIKey{Name: *name, Scope: data.APIScope(*scope)}
+		if *user != "" {
+			found, err := store.LoadUserByName(ctx, *user)
+			if err != nil {
+				return err
+			}
+			if found == nil {
+				return fmt.Errorf("unknown user %s", *user)
+			}
+			key.UserID = found.ID
+		}
+		return createKey(ctx, store, key)
+	case "list":
+		keys, err := store.LoadAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+		users, err := store.LoadUsers(ctx)
+		if err != nil {
+			return err
+		}
+		userNames := make(map[int]string, len(users))
+		for _, user := range users {
+			userNames[user.ID] = user.Name
+		}
+		fmt.Printf("%-4s %-24s %-14s %-6s %-16s %-20s %s\n", "ID", "NAME", "PREFIX", "SCOPE", "USER", "CREATED", "LAST USED")
+		for _, key := range keys {
+			user := "-"
+			if key.UserID != 0 {
+				user = userNames[key.UserID]
+			}
+			lastUsed := "-"
+			if !key.LastUsedAt.IsZero() {
+				lastUsed = key.LastUsedAt.Format(time.DateTime)
+			}
+			fmt.Printf("%-4d %-24s %-14s %-6s %-16s %-20s %s\n", key.ID, key.Name, key.Prefix, key.Scope, user, key.CreatedAt.Format(time.DateTime), lastUsed)
+		}
+		return nil
+	default:
+		if *id == 0 {
+			return fmt.Errorf("--id is required")
+		}
+		if err := store.DeleteAPIKey(ctx, *id); err != nil {
+			return err
+		}
+		fmt.Printf("Revoked key %d\n", *id)
+		return nil
+	}
+}
+
+// createKey stores a new key and prints it, it cannot be shown again.
+func createKey(ctx context.Context, store db.KeyStore, key data.APIKey) error {
+	if key.Name == "" {
+		return fmt.Errorf("--name is required")
+	}
+	if key.Scope != data.ScopeRead && key.Scope != data.ScopeAdmin {
+		return fmt.Errorf("--scope must be read or admin")
+	}
+	secret, prefix, hash, err := auth.NewKey()
+	if err != nil {
+		return err
+	}
+	key.Prefix, key.Hash = prefix, hash
+	id, err := store.AddAPIKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Created %s key %d for %s, it is shown only once:\n", key.Scope, id, key.Name)
+	fmt.Println(secret)
+	return nil
+}
+
+// token issues a token of the local issuer, for tests and setups without an identity provider.
+func token(args []string) error {
+	if len(args) < 1 || args[0] != "issue" {
+		return fmt.Errorf("token command requires: issue")
+	}
+	flags := flag.NewFlagSet("token issue", flag.ExitOnError)
+	secret := flags.String("secret", os.Getenv("RSS_TOKEN_SECRET"), "Secret shared with api-service --token-secret")
+	issuerName := flags.String("issuer", auth.DefaultIssuer, "Issuer of the token, api-service --token-issuer")
+	subject := flags.String("subject", "", "Who the token is for")
+	scope := flags.String("scope", string(data.ScopeRead), "read (GET requests) or admin (every request)")
+	ttl := flags.Duration("ttl", defaultTokenTTL, "Lifetime of the token")
+	flags.Parse(args[1:])
+
+	if *subject == "" {
+		return fmt.Errorf("--subject is required")
+	}
+	if s := data.APIScope(*scope); s != data.ScopeRead && s != data.ScopeAdmin {
+		return fmt.Errorf("--scope must be read or admin")
+	}
+	issuer, err := auth.NewIssuer(*issuerName, *secret)
+	if err != nil {
+		return err
+	}
+	signed, err := issuer.Issue(*subject, data.APIScope(*scope), *ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Println(signed)
+	return nil
+}
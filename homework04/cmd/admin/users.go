@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"rss_fetcher/internal/db"
+	"strings"
+	"time"
+)
+
+func users(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("users command requires one of: create, list, delete")
+	}
+	command, args := args[0], args[1:]
+
+	flags := flag.NewFlagSet("users "+command, flag.ExitOnError)
+	dbParams := flags.String("db", defaultConnection, "Database URL: Postgres connection string or sqlite://path")
+	var name *string
+	var id *int
+	switch command {
+	case "create":
+		name = flags.String("name", "", "Name of the user, also the subject of its tokens")
+	case "list":
+	case "delete":
+		id = flags.Int("id", 0, "ID of the user, see users list")
+	default:
+		return fmt.Errorf("unknown users command: %s", command)
+	}
+	flags.Parse(args)
+
+	store, err := db.Open(ctx, *dbParams)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	switch command {
+	case "create":
+		if strings.TrimSpace(*name) == "" {
+			return fmt.Errorf("--name is required")
+		}
+		id, err := store.AddUser(ctx, strings.TrimSpace(*name))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created user %d, give it a key with: keys create --name=<name> --user=%s\n", id, strings.TrimSpace(*name))
+		return nil
+	case "list":
+		users, err := store.LoadUsers(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-4s %-24s %-20s %s\n", "ID", "NAME", "CREATED", "SUBSCRIPTIONS")
+		for _, user := range users {
+			fmt.Printf("%-4d %-24s %-20s %d\n", user.ID, user.Name, user.CreatedAt.Format(time.DateTime), len(user.ChannelIDs))
+		}
+		return nil
+	default:
+		if *id == 0 {
+			return fmt.Errorf("--id is required")
+		}
+		if err := store.DeleteUser(ctx, *id); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted user %d with its subscriptions and keys\n", *id)
+		return nil
+	}
+}
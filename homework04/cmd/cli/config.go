@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config is read from the config file, e.g.
+//
+//	server: http://rss.example.com:8080
+//	api_key: rss_...
+type config struct {
+	Server string `yaml:"server"`
+	APIKey string `yaml:"api_key"`
+	Token  string `yaml:"token"`
+}
+
+// defaultConfigPath is cli.yaml in the rss-fetcher directory of the user config directory,
+// e.g. ~/.config/rss-fetcher/cli.yaml.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "rss-fetcher", "cli.yaml")
+}
+
+// loadConfig reads the config file, a missing default file is an empty config.
+// RSS_API_KEY and RSS_TOKEN override the file.
+func loadConfig(path string, explicit bool) (config, error) {
+	var cfg config
+	if path != "" {
+		content, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && !explicit:
+		case err != nil:
+			return cfg, err
+		default:
+			if err := yaml.Unmarshal(content, &cfg); err != nil {
+				return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+			}
+		}
+	}
+	if key := os.Getenv("RSS_API_KEY"); key != "" {
+		cfg.APIKey = key
+	}
+	if token := os.Getenv("RSS_TOKEN"); token != "" {
+		cfg.Token = token
+	}
+	return cfg, nil
+}
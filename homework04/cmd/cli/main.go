@@ -1,103 +1,154 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"net/url"
+	"os"
+	"rss_fetcher/client"
 )
 
-const baseURL = "http://localhost:8080/api/v1"
+const serverURL = "http://localhost:8080"
 
 const SPLIT_LINE = "==========================================="
 
-func clearChannels() {
-	// Create client
-	client := &http.Client{}
+// listPageSize is the largest page the API returns, lists are read with few requests.
+const listPageSize = 500
 
-	// Create request
-	req, err := http.NewRequest("DELETE", baseURL+"/channels", nil)
+// printResult prints a response of the API as indented JSON, or the problem of a failed request.
+func printResult(result any, err error) {
 	if err != nil {
-		fmt.Println(err)
+		fmt.Println("Error:", err)
 		return
 	}
-
-	resp, err := client.Do(req)
+	out, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
-		fmt.Println(err)
-		return
+		log.Fatal(err)
 	}
-	defer resp.Body.Close()
+	fmt.Println(string(out))
+}
 
-	// Read Response Body
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println(err)
-		return
+func clearChannels(ctx context.Context, api *client.Client) {
+	printResult(api.DeleteChannels(ctx))
+}
+
+// readAll follows the next_cursor of every page until the last one and returns the
+// items of all pages.
+func readAll[T any](page func(cursor string) ([]T, string, error)) ([]T, error) {
+	var items []T
+	cursor := ""
+	for {
+		batch, next, err := page(cursor)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, batch...)
+		if next == "" {
+			return items, nil
+		}
+		cursor = next
 	}
+}
 
-	fmt.Println("Response Status:", resp.Status)
-	fmt.Println("Response Body:", string(respBody))
+func readNews(ctx context.Context, api *client.Client, group int, unread bool) {
+	printResult(readAll(func(cursor string) ([]client.ChannelNews, string, error) {
+		page, err := api.ListNews(ctx, &client.ListNewsParams{Limit: listPageSize, Cursor: cursor, Group: group, Unread: unread})
+		if err != nil {
+			return nil, "", err
+		}
+		return page.Items, page.NextCursor, nil
+	}))
 }
 
-func readNews() {
-	resp, err := http.Get(baseURL + "/news")
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	fmt.Println("Response Status:", resp.Status)
-	fmt.Println("Response Body:", string(body))
+func readJobs(ctx context.Context, api *client.Client) {
+	printResult(readAll(func(cursor string) ([]client.NewsJob, string, error) {
+		page, err := api.ListJobs(ctx, &client.ListJobsParams{Limit: listPageSize, Cursor: cursor})
+		if err != nil {
+			return nil, "", err
+		}
+		return page.Items, page.NextCursor, nil
+	}))
 }
 
-func readJobs() {
-	resp, err := http.Get(baseURL + "/jobs")
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	fmt.Println("Response Status:", resp.Status)
-	fmt.Println("Response Body:", string(body))
+func readChannels(ctx context.Context, api *client.Client) {
+	printResult(readAll(func(cursor string) ([]client.Channel, string, error) {
+		page, err := api.ListChannels(ctx, &client.ListChannelsParams{Limit: listPageSize, Cursor: cursor})
+		if err != nil {
+			return nil, "", err
+		}
+		return page.Items, page.NextCursor, nil
+	}))
 }
 
-func readChannels() {
-	resp, err := http.Get(baseURL + "/channels")
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	fmt.Println("Response Status:", resp.Status)
-	fmt.Println("Response Body:", string(body))
+func addChannel(ctx context.Context, api *client.Client, url string) {
+	printResult(api.AddChannel(ctx, client.AddChannelRequest{Link: url}))
+}
+
+func askModel(ctx context.Context, api *client.Client, query string, group int) {
+	printResult(api.Query(ctx, query, &client.QueryParams{Group: group}))
+}
+
+func subscribe(ctx context.Context, api *client.Client, url string) {
+	printResult(api.AddSubscription(ctx, client.AddChannelRequest{Link: url}))
+}
+
+func readSubscriptions(ctx context.Context, api *client.Client) {
+	printResult(readAll(func(cursor string) ([]client.Channel, string, error) {
+		page, err := api.ListSubscriptions(ctx, &client.ListSubscriptionsParams{Limit: listPageSize, Cursor: cursor})
+		if err != nil {
+			return nil, "", err
+		}
+		return page.Items, page.NextCursor, nil
+	}))
+}
+
+// updateNewsState marks a news item as read or starred, only the given state is sent.
+func updateNewsState(ctx context.Context, api *client.Client, id int, state client.NewsStateRequest) {
+	printResult(api.UpdateNewsState(ctx, id, state))
+}
+
+func readGroups(ctx context.Context, api *client.Client) {
+	printResult(api.ListGroups(ctx))
+}
+
+func readSearches(ctx context.Context, api *client.Client) {
+	printResult(api.ListSearches(ctx))
+}
+
+// addSearch saves a search, the threshold of the server applies when threshold is nil.
+func addSearch(ctx context.Context, api *client.Client, query, webhookURL, secret string, threshold *float32, group int) {
+	printResult(api.AddSearch(ctx, client.AddSearchRequest{
+		Query:      query,
+		WebhookURL: webhookURL,
+		Secret:     secret,
+		Threshold:  threshold,
+		GroupID:    group,
+	}))
 }
 
-func addChannel(url string) {
-	payload := map[string]string{"link": url}
-	data, _ := json.Marshal(payload)
-	resp, err := http.Post(baseURL+"/channels", "application/json", bytes.NewBuffer(data))
+// importOPML sends the OPML file and prints the import report.
+func importOPML(ctx context.Context, api *client.Client, path string) {
+	file, err := os.Open(path)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	fmt.Println(string(body))
+	defer file.Close()
+	printResult(api.ImportChannels(ctx, file))
 }
 
-func askModel(query string) {
-	resp, err := http.Get(baseURL + "/query/" + url.QueryEscape(query))
+// exportOPML saves all channels with their groups to an OPML file.
+func exportOPML(ctx context.Context, api *client.Client, path string) {
+	body, err := api.ExportChannels(ctx)
 	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if err := os.WriteFile(path, body, 0o644); err != nil {
 		log.Fatal(err)
 	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	fmt.Println("Response Status:", resp.Status)
-	fmt.Println("Response Body:", string(body))
+	fmt.Println("Channels exported to", path)
 }
 
 func main() {
@@ -107,35 +158,130 @@ func main() {
 	showChannels := flag.Bool("show-channels", false, "Show all channels")
 	showNews := flag.Bool("show-news", false, "Show all news")
 	showJobs := flag.Bool("show-jobs", false, "Show all jobs")
+	watch := flag.String("watch", "", "Save a search query and be alerted about matching news")
+	webhookURL := flag.String("webhook", "", "Webhook URL for --watch alerts")
+	secret := flag.String("secret", "", "Secret used to sign --watch webhooks")
+	threshold := flag.Float64("threshold", 0.75, "Similarity threshold for --watch alerts (0..1)")
+	showSearches := flag.Bool("show-searches", false, "Show all saved searches")
+	importFile := flag.String("import-opml", "", "Add the channels of an OPML file")
+	exportFile := flag.String("export-opml", "", "Save the channels to an OPML file")
+	showGroups := flag.Bool("show-groups", false, "Show all channel groups")
+	group := flag.Int("group", 0, "Limit --query, --watch and --show-news to the channels of a group")
+	subscribeURL := flag.String("subscribe", "", "Follow an RSS feed, it is added once for all users")
+	showSubscriptions := flag.Bool("show-subscriptions", false, "Show the channels you follow")
+	unread := flag.Bool("unread", false, "Limit --show-news to news you have not read")
+	markRead := flag.Int("mark-read", 0, "Mark the news with the ID as read")
+	star := flag.Int("star", 0, "Star the news with the ID")
+	server := flag.String("server", "", "URL of api-service (default "+serverURL+")")
+	configPath := flag.String("config", defaultConfigPath(), "Config file with server and api_key")
+	apiKey := flag.String("api-key", "", "API key of api-service, overrides the config and RSS_API_KEY")
 	flag.Parse()
 
-	if *rssURL == "" && !*showChannels && !*reset && !*showNews && !*showJobs && *query == "" {
+	explicitConfig := false
+	var watchThreshold *float32
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "config":
+			explicitConfig = true
+		case "threshold":
+			value := float32(*threshold)
+			watchThreshold = &value
+		}
+	})
+	cfg, err := loadConfig(*configPath, explicitConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *server != "" {
+		cfg.Server = *server
+	}
+	if cfg.Server == "" {
+		cfg.Server = serverURL
+	}
+	if *apiKey != "" {
+		cfg.APIKey = *apiKey
+	}
+
+	if *rssURL == "" && !*showChannels && !*reset && !*showNews && !*showJobs && *query == "" && *watch == "" && !*showSearches &&
+		*importFile == "" && *exportFile == "" && !*showGroups && *subscribeURL == "" && !*showSubscriptions && *markRead == 0 && *star == 0 {
 		log.Fatal("Please specify either --url or --show-channels or --show-news or --reset parameter")
 	}
 
+	if *watch != "" && *webhookURL == "" {
+		log.Fatal("Please specify --webhook for --watch")
+	}
+
+	ctx := context.Background()
+	var options []client.Option
+	switch {
+	case cfg.APIKey != "":
+		options = append(options, client.WithAPIKey(cfg.APIKey))
+	case cfg.Token != "":
+		options = append(options, client.WithToken(cfg.Token))
+	}
+	api := client.New(cfg.Server, options...)
+
 	if *reset {
-		clearChannels()
+		clearChannels(ctx, api)
 	}
 
 	if *rssURL != "" {
-		addChannel(*rssURL)
+		addChannel(ctx, api, *rssURL)
+	}
+
+	if *importFile != "" {
+		importOPML(ctx, api, *importFile)
+	}
+
+	if *exportFile != "" {
+		exportOPML(ctx, api, *exportFile)
 	}
 
 	if *showNews {
-		readNews()
+		readNews(ctx, api, *group, *unread)
 	}
 
 	if *showChannels {
-		readChannels()
+		readChannels(ctx, api)
 	}
 
 	if *showJobs {
-		readJobs()
+		readJobs(ctx, api)
+	}
+
+	if *watch != "" {
+		addSearch(ctx, api, *watch, *webhookURL, *secret, watchThreshold, *group)
+	}
+
+	if *showSearches {
+		readSearches(ctx, api)
+	}
+
+	if *showGroups {
+		readGroups(ctx, api)
+	}
+
+	if *subscribeURL != "" {
+		subscribe(ctx, api, *subscribeURL)
+	}
+
+	if *showSubscriptions {
+		readSubscriptions(ctx, api)
+	}
+
+	if *markRead != 0 {
+		read := true
+		updateNewsState(ctx, api, *markRead, client.NewsStateRequest{Read: &read})
+	}
+
+	if *star != 0 {
+		starred := true
+		updateNewsState(ctx, api, *star, client.NewsStateRequest{Starred: &starred})
 	}
 
 	log.Printf("Query %s", *query)
 
 	if *query != "" {
-		askModel(*query)
+		askModel(ctx, api, *query, *group)
 	}
 }
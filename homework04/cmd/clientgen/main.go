@@ -0,0 +1,513 @@
+// Command clientgen writes the typed Go client of api-service from the OpenAPI
+// specification, it is run by go generate in the client package.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"rss_fetcher/openapi"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const jsonType = "application/json"
+
+// initialisms keep the Go spelling of words in generated names, e.g. webhook_url becomes WebhookURL.
+var initialisms = map[string]string{
+	"api":     "API",
+	"guid":    "GUID",
+	"html":    "HTML",
+	"http":    "HTTP",
+	"id":      "ID",
+	"ids":     "IDs",
+	"json":    "JSON",
+	"ok":      "OK",
+	"openapi": "OpenAPI",
+	"opml":    "OPML",
+	"rss":     "RSS",
+	"url":     "URL",
+}
+
+func main() {
+	out := flag.String("out", "client.gen.go", "Generated file")
+	pkg := flag.String("package", "client", "Package of the generated file")
+	flag.Parse()
+
+	spec, err := openapi.Load()
+	if err != nil {
+		log.Fatalf("OpenAPI specification error: %v", err)
+	}
+	g := &generator{spec: spec, imports: map[string]bool{}}
+	source, err := g.generate(*pkg)
+	if err != nil {
+		log.Fatalf("Generation error: %v", err)
+	}
+	if err := os.WriteFile(*out, source, 0o644); err != nil {
+		log.Fatalf("Write error: %v", err)
+	}
+	log.Printf("Wrote %s", *out)
+}
+
+type generator struct {
+	spec    *openapi.Document
+	imports map[string]bool
+	body    bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+// generate writes the types of the schemas, then a method for every operation.
+func (g *generator) generate(pkg string) ([]byte, error) {
+	names := make([]string, 0, len(g.spec.Components.Schemas))
+	for name := range g.spec.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := g.schemaType(name, g.spec.Components.Schemas[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+
+	paths := make([]string, 0, len(g.spec.Paths))
+	for path := range g.spec.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		for _, method := range g.spec.Methods(path) {
+			operation := g.spec.Paths[path][method]
+			if err := g.operation(path, method, operation); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+		}
+	}
+
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "// Code generated by clientgen from openapi/openapi.json. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&file, "package %s\n\n", pkg)
+	imports := make([]string, 0, len(g.imports))
+	for name := range g.imports {
+		imports = append(imports, name)
+	}
+	sort.Strings(imports)
+	fmt.Fprintf(&file, "import (\n")
+	for _, name := range imports {
+		fmt.Fprintf(&file, "\t%q\n", name)
+	}
+	fmt.Fprintf(&file, ")\n\n")
+	file.Write(g.body.Bytes())
+
+	source, err := format.Source(file.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return source, nil
+}
+
+// schemaType declares the type of a component schema: a struct for objects and a
+// string type with constants for enums.
+func (g *generator) schemaType(name string, schema *openapi.Schema) error {
+	typeName := goName(name)
+	if schema.Description != "" {
+		g.printf("// %s\n", schema.Description)
+	}
+	switch {
+	case schema.Type == "string" && len(schema.Enum) > 0:
+		g.printf("type %s string\n\nconst (\n", typeName)
+		for _, value := range schema.Enum {
+			text := fmt.Sprint(value)
+			g.printf("\t%s%s %s = %q\n", typeName, goName(text), typeName, text)
+		}
+		g.printf(")\n\n")
+	case schema.Type == "object" && len(schema.Properties) > 0:
+		g.printf("type %s struct {\n", typeName)
+		for _, property := range schema.Properties {
+			required := slices.Contains(schema.Required, property.Name)
+			fieldType, err := g.goType(property.Schema)
+			if err != nil {
+				return fmt.Errorf("property %s: %w", property.Name, err)
+			}
+			tag := property.Name
+			if !required {
+				if fieldType == "time.Time" {
+					tag += ",omitzero"
+				} else {
+					tag += ",omitempty"
+				}
+				if schema.Partial {
+					// only the fields which are set are sent
+					fieldType = "*" + fieldType
+				}
+			}
+			g.printf("\t%s %s `json:%q`", goName(property.Name), fieldType, tag)
+			if property.Schema.Description != "" {
+				g.printf(" // %s", property.Schema.Description)
+			}
+			g.printf("\n")
+		}
+		g.printf("}\n\n")
+	default:
+		return fmt.Errorf("unsupported schema of type %q", schema.Type)
+	}
+	return nil
+}
+
+// goType is the Go type of a schema, referenced schemas use their declared types.
+// Nullable schemas are pointers, so that null and the zero value differ; slices are
+// nil already.
+func (g *generator) goType(schema *openapi.Schema) (string, error) {
+	if schema.Nullable && schema.Type != "array" {
+		value := *schema
+		value.Nullable = false
+		fieldType, err := g.goType(&value)
+		return "*" + fieldType, err
+	}
+	if schema.Ref != "" {
+		return goName(openapi.SchemaName(schema)), nil
+	}
+	switch schema.Type {
+	case "string":
+		switch schema.Format {
+		case "date-time":
+			g.imports["time"] = true
+			return "time.Time", nil
+		case "binary":
+			return "[]byte", nil
+		}
+		return "string", nil
+	case "integer":
+		if schema.Format == "int64" {
+			return "int64", nil
+		}
+		return "int", nil
+	case "number":
+		if schema.Format == "float" {
+			return "float32", nil
+		}
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if schema.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		item, err := g.goType(schema.Items)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case "object":
+		if additional := schema.AdditionalSchema(); additional != nil {
+			value, err := g.goType(additional)
+			if err != nil {
+				return "", err
+			}
+			return "map[string]" + value, nil
+		}
+		return "map[string]any", nil
+	}
+	return "", fmt.Errorf("unsupported schema of type %q", schema.Type)
+}
+
+// result is the return value of a method, kind is pointer, slice, raw or none.
+type result struct {
+	kind     string
+	typeName string
+}
+
+// operation writes the method of an operation and the struct of its query parameters.
+func (g *generator) operation(path, method string, operation *openapi.Operation) error {
+	if operation.OperationID == "" {
+		return fmt.Errorf("operation without operationId")
+	}
+	name := goName(operation.OperationID)
+	g.imports["context"] = true
+
+	var pathParams, queryParams []*openapi.Parameter
+	for _, parameter := range operation.Parameters {
+		switch parameter.In {
+		case "path":
+			pathParams = append(pathParams, parameter)
+		case "query":
+			queryParams = append(queryParams, parameter)
+		}
+	}
+
+	if len(queryParams) > 0 {
+		g.printf("// %sParams are the query parameters of %s, zero values are not sent.\n", name, name)
+		g.printf("type %sParams struct {\n", name)
+		for _, parameter := range queryParams {
+			fieldType, err := g.goType(parameter.Schema)
+			if err != nil {
+				return fmt.Errorf("parameter %s: %w", parameter.Name, err)
+			}
+			g.printf("\t%s %s", goName(parameter.Name), fieldType)
+			if parameter.Description != "" {
+				g.printf(" // %s", parameter.Description)
+			}
+			g.printf("\n")
+		}
+		g.printf("}\n\n")
+	}
+
+	arguments := []string{"ctx context.Context"}
+	for _, parameter := range pathParams {
+		fieldType, err := g.goType(parameter.Schema)
+		if err != nil {
+			return fmt.Errorf("parameter %s: %w", parameter.Name, err)
+		}
+		arguments = append(arguments, varName(parameter.Name)+" "+fieldType)
+	}
+	if len(queryParams) > 0 {
+		arguments = append(arguments, "params *"+name+"Params")
+	}
+	bodyType, contentType, err := g.requestBody(operation)
+	if err != nil {
+		return err
+	}
+	if bodyType != "" {
+		arguments = append(arguments, "body "+bodyType)
+	}
+	res, err := g.result(operation)
+	if err != nil {
+		return err
+	}
+
+	returns, zero := "error", ""
+	switch res.kind {
+	case "pointer":
+		returns, zero = "(*"+res.typeName+", error)", "nil, "
+	case "slice", "raw":
+		returns, zero = "("+res.typeName+", error)", "nil, "
+	}
+
+	if operation.Summary != "" {
+		g.printf("// %s %s\n//\n", name, lowerFirst(operation.Summary))
+	}
+	g.printf("// %s %s\n", strings.ToUpper(method), path)
+	g.printf("func (c *Client) %s(%s) %s {\n", name, strings.Join(arguments, ", "), returns)
+
+	pathExpr, err := g.pathExpression(path, pathParams)
+	if err != nil {
+		return err
+	}
+	g.printf("\treq := request{method: %q, path: %s}\n", strings.ToUpper(method), pathExpr)
+	if len(queryParams) > 0 {
+		g.imports["net/url"] = true
+		g.printf("\tif params != nil {\n\t\treq.query = url.Values{}\n")
+		for _, parameter := range queryParams {
+			if err := g.queryParameter(parameter); err != nil {
+				return err
+			}
+		}
+		g.printf("\t}\n")
+	}
+	switch {
+	case contentType == jsonType:
+		g.printf("\tvar err error\n\tif req.body, err = jsonBody(body); err != nil {\n\t\treturn %serr\n\t}\n", zero)
+		g.printf("\treq.contentType = %q\n", contentType)
+	case bodyType != "":
+		g.printf("\treq.body, req.contentType = body, %q\n", contentType)
+	}
+
+	switch res.kind {
+	case "pointer":
+		g.printf("\tvar result %s\n\tif err := c.do(ctx, req, &result); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &result, nil\n", res.typeName)
+	case "slice", "raw":
+		g.printf("\tvar result %s\n\tif err := c.do(ctx, req, &result); err != nil {\n\t\treturn nil, err\n\t}\n\treturn result, nil\n", res.typeName)
+	default:
+		g.printf("\treturn c.do(ctx, req, nil)\n")
+	}
+	g.printf("}\n\n")
+	return nil
+}
+
+// requestBody picks the JSON content of the body, or else its first content which
+// is not a form. Other contents are sent as an io.Reader.
+func (g *generator) requestBody(operation *openapi.Operation) (string, string, error) {
+	if operation.RequestBody == nil {
+		return "", "", nil
+	}
+	if media, ok := operation.RequestBody.Content[jsonType]; ok {
+		bodyType, err := g.goType(media.Schema)
+		return bodyType, jsonType, err
+	}
+	types := make([]string, 0, len(operation.RequestBody.Content))
+	for contentType := range operation.RequestBody.Content {
+		if !strings.HasPrefix(contentType, "multipart/") {
+			types = append(types, contentType)
+		}
+	}
+	if len(types) == 0 {
+		return "", "", fmt.Errorf("request body without a supported content type")
+	}
+	sort.Strings(types)
+	g.imports["io"] = true
+	return "io.Reader", types[0], nil
+}
+
+// result is decoded from the JSON content of the first successful response, other
+// contents are returned as bytes.
+func (g *generator) result(operation *openapi.Operation) (result, error) {
+	statuses := make([]string, 0, len(operation.Responses))
+	for status := range operation.Responses {
+		if strings.HasPrefix(status, "2") {
+			statuses = append(statuses, status)
+		}
+	}
+	if len(statuses) == 0 {
+		return result{}, fmt.Errorf("no successful response")
+	}
+	sort.Strings(statuses)
+	response := operation.Responses[statuses[0]]
+	if len(response.Content) == 0 {
+		return result{kind: "none"}, nil
+	}
+	media, ok := response.Content[jsonType]
+	if !ok || media.Schema == nil {
+		return result{kind: "raw", typeName: "[]byte"}, nil
+	}
+	switch {
+	case media.Schema.Ref != "":
+		return result{kind: "pointer", typeName: goName(openapi.SchemaName(media.Schema))}, nil
+	case media.Schema.Type == "array" && media.Schema.Items != nil && media.Schema.Items.Ref != "":
+		typeName, err := g.goType(media.Schema)
+		return result{kind: "slice", typeName: typeName}, err
+	}
+	return result{kind: "raw", typeName: "[]byte"}, nil
+}
+
+// pathExpression builds the path of a request, with the path parameters escaped.
+func (g *generator) pathExpression(path string, parameters []*openapi.Parameter) (string, error) {
+	parts := []string{}
+	rest := path
+	for {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(rest, "}")
+		if end < start {
+			return "", fmt.Errorf("invalid path")
+		}
+		name := rest[start+1 : end]
+		var parameter *openapi.Parameter
+		for _, candidate := range parameters {
+			if candidate.Name == name {
+				parameter = candidate
+			}
+		}
+		if parameter == nil {
+			return "", fmt.Errorf("path parameter %s is not described", name)
+		}
+		if rest[:start] != "" {
+			parts = append(parts, strconv.Quote(rest[:start]))
+		}
+		value, err := g.stringValue(parameter.Schema, varName(name))
+		if err != nil {
+			return "", err
+		}
+		g.imports["net/url"] = true
+		parts = append(parts, "url.PathEscape("+value+")")
+		rest = rest[end+1:]
+	}
+	if rest != "" {
+		parts = append(parts, strconv.Quote(rest))
+	}
+	return strings.Join(parts, " + "), nil
+}
+
+// queryParameter sets the query parameter when its field is not zero.
+func (g *generator) queryParameter(parameter *openapi.Parameter) error {
+	field := "params." + goName(parameter.Name)
+	schema, err := g.spec.Resolve(parameter.Schema)
+	if err != nil {
+		return err
+	}
+	value, err := g.stringValue(parameter.Schema, field)
+	if err != nil {
+		return err
+	}
+	var condition string
+	switch schema.Type {
+	case "string":
+		condition = field + ` != ""`
+	case "boolean":
+		condition = field
+	default:
+		condition = field + " != 0"
+	}
+	g.printf("\t\tif %s {\n\t\t\treq.query.Set(%q, %s)\n\t\t}\n", condition, parameter.Name, value)
+	return nil
+}
+
+// stringValue converts a Go expression of the schema's type to a string.
+func (g *generator) stringValue(schema *openapi.Schema, expression string) (string, error) {
+	resolved, err := g.spec.Resolve(schema)
+	if err != nil {
+		return "", err
+	}
+	switch resolved.Type {
+	case "string":
+		if schema.Ref != "" {
+			return "string(" + expression + ")", nil
+		}
+		return expression, nil
+	case "integer":
+		g.imports["strconv"] = true
+		if resolved.Format == "int64" {
+			return "strconv.FormatInt(" + expression + ", 10)", nil
+		}
+		return "strconv.Itoa(" + expression + ")", nil
+	case "number":
+		g.imports["strconv"] = true
+		return "strconv.FormatFloat(float64(" + expression + "), 'f', -1, 64)", nil
+	case "boolean":
+		g.imports["strconv"] = true
+		return "strconv.FormatBool(" + expression + ")", nil
+	}
+	return "", fmt.Errorf("unsupported parameter of type %q", resolved.Type)
+}
+
+// goName converts names of the specification to exported Go names.
+func goName(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, part := range parts {
+		if initialism, ok := initialisms[part]; ok {
+			b.WriteString(initialism)
+			continue
+		}
+		// camelCase ids like getOpenAPI keep their inner capitals
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// varName is the unexported form of a name, e.g. for path parameters.
+func varName(name string) string {
+	exported := goName(name)
+	if strings.ToUpper(exported) == exported {
+		return strings.ToLower(exported)
+	}
+	return lowerFirst(exported)
+}
+
+func lowerFirst(text string) string {
+	if text == "" {
+		return text
+	}
+	return strings.ToLower(text[:1]) + text[1:]
+}
@@ -1,11 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"rss_fetcher/internal/auth"
 	"rss_fetcher/internal/db"
+	"rss_fetcher/internal/embedding"
+	"rss_fetcher/internal/rag"
 	"rss_fetcher/internal/services/api"
+	"rss_fetcher/openapi"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
@@ -16,71 +27,139 @@ const (
 	embDefaultModel         = "mxbai-embed-large"
 	genDefaultModel         = "llama3"
 	defaultApiPort          = 8080
-
-	rootPath     = "/api/v1"
-	channelsPath = rootPath + "/channels"
-	newsPath     = rootPath + "/news"
-	jobsPath     = rootPath + "/jobs"
-	queryPath    = rootPath + "/query"
+	embedConcurrency        = 4
+	embedRequestTimeout     = 60 * time.Second
+
+	rootPath          = "/api/v1"
+	channelsPath      = rootPath + "/channels"
+	newsPath          = rootPath + "/news"
+	jobsPath          = rootPath + "/jobs"
+	queryPath         = rootPath + "/query"
+	searchesPath      = rootPath + "/searches"
+	groupsPath        = rootPath + "/groups"
+	subscriptionsPath = rootPath + "/subscriptions"
+	feedsPath         = "/feeds"
 )
 
 func main() {
 
-	dbParams := flag.String("db", defaultConnection, "Postgres connection string")
+	dbParams := flag.String("db", defaultConnection, "Database URL: Postgres connection string or sqlite://path")
+	schema := flag.String("schema", db.SchemaMigrate, "Postgres schema on startup: migrate applies pending migrations, check fails when some are pending, off skips both")
 	ollamaConnection := flag.String("ollama", ollamaDefaultConnection, "Postgres connection string")
-	embModel := flag.String("emb", embDefaultModel, "Embedding model")
+	embModel := flag.String("emb", embDefaultModel, "Expected embedding model, queries use the model of the active profile")
 	genModel := flag.String("gen", genDefaultModel, "Generative model")
 	apiPort := flag.Int("port", defaultApiPort, "REST API port")
+	contextChunks := flag.Int("context-chunks", rag.DefaultContextChunks, "Number of retrieved chunks passed to the generative model")
+	requireAuth := flag.Bool("auth", true, "Require API keys or tokens, with --auth=false anyone reaching the port is an admin")
+	tokenSecret := flag.String("token-secret", os.Getenv("RSS_TOKEN_SECRET"), "Secret of the local token issuer, tokens are refused when empty")
+	tokenIssuer := flag.String("token-issuer", auth.DefaultIssuer, "Expected issuer of tokens")
 
 	flag.Parse()
-	dbConn, err := db.InitDB(*dbParams)
+
+	store, err := db.Open(context.Background(), *dbParams)
 	if err != nil {
 		log.Fatalf("Database connection error: %v", err)
 	}
-	defer db.Close(dbConn)
+	defer store.Close()
+	if err := db.PrepareSchema(context.Background(), store, *schema); err != nil {
+		log.Fatalf("Database schema error: %v", err)
+	}
 
-	vectorDB, err := db.NewPGVector(*dbParams)
-	if err != nil {
-		log.Fatalf("Error initializing vector database: %v", err)
+	if profile, err := store.LoadActiveProfile(context.Background()); err != nil {
+		log.Printf("Error loading active embedding profile: %v", err)
+	} else if profile != nil && profile.Model != *embModel {
+		log.Printf("Active embedding profile %s uses model %s instead of %s", profile.Name, profile.Model, *embModel)
 	}
-	defer vectorDB.Close()
 
-	api := api.New(dbConn, vectorDB, *ollamaConnection, *embModel, *genModel)
+	embedders := embedding.NewOllama(*ollamaConnection, embedRequestTimeout, embedConcurrency, 1)
+	server := api.New(store, embedders, *ollamaConnection, *genModel, *contextChunks)
 
-	e := echo.New()
+	spec, err := openapi.Load()
+	if err != nil {
+		log.Fatalf("OpenAPI specification error: %v", err)
+	}
 
-	e.POST(channelsPath, api.AddChannel)
-	e.GET(channelsPath, api.GetChannels)
-	e.GET(channelsPath+"/:id", api.GetChannel)
-	e.DELETE(channelsPath, api.DeleteChannels)
-	e.DELETE(channelsPath+"/:id", api.DeleteChannel)
-	e.GET(newsPath, api.GetAllNews)
-	e.DELETE(newsPath+"/:id", api.DeleteNews)
-	e.GET(queryPath+"/:q", api.GetQuery)
-	e.GET(jobsPath, api.GetJobs)
+	e := echo.New()
+	e.HTTPErrorHandler = server.HandleError
+	if *requireAuth {
+		var issuer *auth.Issuer
+		if *tokenSecret != "" {
+			if issuer, err = auth.NewIssuer(*tokenIssuer, *tokenSecret); err != nil {
+				log.Fatalf("Token issuer error: %v", err)
+			}
+		}
+		e.Use(server.Authenticate(spec, issuer))
+	} else {
+		log.Printf("Authentication is off, every request is allowed")
+	}
+	e.Use(api.ValidateRequests(spec))
+
+	e.GET(rootPath+"/openapi.json", server.GetOpenAPI)
+
+	e.POST(channelsPath, server.AddChannel)
+	e.GET(channelsPath, server.GetChannels)
+	e.POST(channelsPath+"/import", server.ImportChannels)
+	e.GET(channelsPath+"/export", server.ExportChannels)
+	e.GET(channelsPath+"/:id", server.GetChannel)
+	e.GET(channelsPath+"/:id/health", server.GetChannelHealth)
+	e.PATCH(channelsPath+"/:id", server.UpdateChannel)
+	e.DELETE(channelsPath, server.DeleteChannels)
+	e.DELETE(channelsPath+"/:id", server.DeleteChannel)
+	e.POST(groupsPath, server.AddGroup)
+	e.GET(groupsPath, server.GetGroups)
+	e.GET(groupsPath+"/:id", server.GetGroup)
+	e.PATCH(groupsPath+"/:id", server.UpdateGroup)
+	e.DELETE(groupsPath+"/:id", server.DeleteGroup)
+	e.PUT(groupsPath+"/:id/channels/:channel", server.AddGroupChannel)
+	e.DELETE(groupsPath+"/:id/channels/:channel", server.DeleteGroupChannel)
+	e.GET(newsPath, server.GetAllNews)
+	e.DELETE(newsPath+"/:id", server.DeleteNews)
+	e.GET(newsPath+"/:id/content", server.GetNewsContent)
+	e.PATCH(newsPath+"/:id/state", server.UpdateNewsState)
+	e.GET(queryPath+"/:q", server.GetQuery)
+	e.GET(jobsPath, server.GetJobs)
+	e.POST(searchesPath, server.AddSearch)
+	e.GET(searchesPath, server.GetSearches)
+	e.DELETE(searchesPath+"/:id", server.DeleteSearch)
+	e.GET(subscriptionsPath, server.GetSubscriptions)
+	e.POST(subscriptionsPath, server.AddSubscription)
+	e.PUT(subscriptionsPath+"/:channel", server.SubscribeChannel)
+	e.DELETE(subscriptionsPath+"/:channel", server.UnsubscribeChannel)
+	e.GET(feedsPath+"/:feed", server.GetAllFeed)
+	e.GET(feedsPath+"/groups/:feed", server.GetGroupFeed)
+	e.GET(feedsPath+"/tags/:feed", server.GetTagFeed)
+	e.GET(feedsPath+"/searches/:feed", server.GetSearchFeed)
+
+	if err := api.CheckRoutes(spec, e.Routes()); err != nil {
+		log.Fatalf("OpenAPI specification error: %v", err)
+	}
 
 	// graceful exit from service
-	//quitChannel := make(chan os.Signal, 1)
-	//signal.Notify(quitChannel, syscall.SIGINT, syscall.SIGTERM)
+	quitChannel := make(chan os.Signal, 1)
+	signal.Notify(quitChannel, syscall.SIGINT, syscall.SIGTERM)
 
 	// Start the server in a goroutines
-	//go func() {
-	log.Printf("Starting REST API service on %d", *apiPort)
-	e.Logger.Fatal(e.Start(fmt.Sprintf(":%d", *apiPort)))
-	//}()
+	go func() {
+		log.Printf("Starting REST API service on %d", *apiPort)
+		if err := e.Start(fmt.Sprintf(":%d", *apiPort)); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("REST API service error: %v", err)
+		}
+	}()
 
 	// Block until a signal is received
-	//<-quitChannel
-	//log.Println("Shutting down REST API service...")
+	<-quitChannel
+	log.Println("Shutting down REST API service...")
 
-	// Create a context with a timeout for graceful shutdown
-	//ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	//defer cancel()
+	// Create a context with a timeout for graceful shutdown, requests still
+	// running when it expires are cancelled together with their queries
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
 	// Attempt graceful shutdown
-	//if err := e.Shutdown(ctx); err != nil {
-	//	log.Fatalf("REST API service shutdown error: %v", err)
-	//}
+	if err := e.Shutdown(ctx); err != nil {
+		log.Printf("REST API service shutdown error: %v", err)
+		e.Close()
+	}
 
-	//log.Println("REST API service is stopped.")
+	log.Println("REST API service is stopped.")
 }
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"os/signal"
 	"rss_fetcher/internal/db"
+	"rss_fetcher/internal/embedding"
+	"rss_fetcher/internal/parser"
 	"rss_fetcher/internal/services/daemon"
+	"syscall"
 	"time"
 )
 
@@ -13,35 +18,72 @@ const (
 	ollamaDefaultConnection = "http://localhost:11434"
 	embDefaultModel         = "mxbai-embed-large"
 	genDefaultModel         = "llama3"
+	defaultWorkers          = 4
+	defaultEmbedConcurrency = 2
+	defaultEmbedBatch       = 32
+	embedRequestTimeout     = 60 * time.Second
+	defaultPollInterval     = 1 * time.Minute
 )
 
 func main() {
-	dbParams := flag.String("db", defaultConnection, "Postgres connection string")
+	dbParams := flag.String("db", defaultConnection, "Database URL: Postgres connection string or sqlite://path")
+	schema := flag.String("schema", db.SchemaMigrate, "Postgres schema on startup: migrate applies pending migrations, check fails when some are pending, off skips both")
 	ollamaConnection := flag.String("ollama", ollamaDefaultConnection, "Postgres connection string")
-	embModel := flag.String("emb", embDefaultModel, "Embedding model")
+	embModel := flag.String("emb", embDefaultModel, "Embedding model of the first profile on a fresh database")
+	chunker := flag.String("chunker", parser.DefaultChunker, "Chunker of the first profile on a fresh database, e.g. paragraph:size=300,overlap=40,tokenizer=/models/vocab.txt")
+	contextual := flag.Bool("contextual", false, "Embed the chunks of the first profile with a header of the article title, channel and date")
 	genModel := flag.String("gen", genDefaultModel, "Generative model")
+	jobAttempts := flag.Int("job-attempts", daemon.DefaultJobPolicy.MaxAttempts, "Attempts before a job is declared dead")
+	jobLease := flag.Duration("job-lease", daemon.DefaultJobPolicy.Lease, "Time a claimed job stays locked before another worker may take it")
+	jobBackoff := flag.Duration("job-backoff", daemon.DefaultJobPolicy.RetryBackoff, "Delay before the first retry of a failed job, doubled on each retry")
+	workers := flag.Int("workers", defaultWorkers, "Number of articles processed in parallel")
+	embedConcurrency := flag.Int("embed-concurrency", defaultEmbedConcurrency, "Maximum number of concurrent requests to the embedding server")
+	embedBatch := flag.Int("embed-batch", defaultEmbedBatch, "Maximum number of chunks embedded in one request")
+	pollInterval := flag.Duration("poll-interval", defaultPollInterval, "Interval between queue checks when no job notification arrives")
 
 	flag.Parse()
-	dbConn, err := db.InitDB(*dbParams)
+
+	// a shutdown cancels the queries and requests of the running jobs,
+	// their leases expire and another replica retries them
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	store, err := db.Open(ctx, *dbParams)
 	if err != nil {
 		log.Fatalf("Database connection error: %v", err)
 	}
-	defer db.Close(dbConn)
+	defer store.Close()
+	if err := db.PrepareSchema(ctx, store, *schema); err != nil {
+		log.Fatalf("Database schema error: %v", err)
+	}
 
-	vectorDB, err := db.NewPGVector(*dbParams)
-	if err != nil {
-		log.Fatalf("Error initializing vector database: %v", err)
+	jobPolicy := daemon.JobPolicy{MaxAttempts: *jobAttempts, Lease: *jobLease, RetryBackoff: *jobBackoff}
+	embedders := embedding.NewOllama(*ollamaConnection, embedRequestTimeout, *embedConcurrency, *embedBatch)
+	daemon := daemon.NewNewsDaemon(store, embedders, *ollamaConnection, *genModel, jobPolicy, *workers)
+	if err := daemon.EnsureProfile(ctx, *embModel, *chunker, *contextual); err != nil {
+		log.Fatalf("Error initializing embedding profile: %v", err)
 	}
-	defer vectorDB.Close()
 
-	daemon := daemon.NewNewsDaemon(dbConn, vectorDB, *ollamaConnection, *embModel, *genModel)
+	// new jobs wake the daemon immediately, the ticker picks up
+	// retries, expired leases and notifications lost during reconnects
+	added := make(chan string, 1)
+	if listener, ok := store.(db.Listener); ok {
+		go listener.Listen(ctx, db.JobAddedEvent, added)
+	}
 
-	ticker := time.NewTicker(1 * time.Minute)
+	ticker := time.NewTicker(*pollInterval)
 	defer ticker.Stop()
 
 	log.Println("Starting news daemon")
 	for {
-		daemon.CheckJobs()
-		<-ticker.C
+		daemon.CheckJobs(ctx)
+		daemon.DeliverAlerts(ctx)
+		select {
+		case <-ticker.C:
+		case <-added:
+		case <-ctx.Done():
+			log.Println("News daemon is stopped")
+			return
+		}
 	}
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"rss_fetcher/internal/webhook"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultPort      = 9090
+	defaultTolerance = 5 * time.Minute
+)
+
+// Local receiver for saved search alerts. It verifies the signature
+// of every incoming webhook and prints the matched article.
+func main() {
+	port := flag.Int("port", defaultPort, "Port to listen on")
+	secret := flag.String("secret", "", "Secret of the saved search used to verify signatures")
+	fail := flag.Int("fail", 0, "Respond with 500 to the first N requests to exercise retries")
+	flag.Parse()
+
+	// requests are served concurrently
+	var received atomic.Int64
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		if n := received.Add(1); n <= int64(*fail) {
+			log.Printf("Request %d rejected on purpose", n)
+			http.Error(w, "failing on purpose", http.StatusInternalServerError)
+			return
+		}
+
+		if *secret != "" {
+			if err := webhook.Verify(*secret, r.Header, body, defaultTolerance); err != nil {
+				log.Printf("Rejected webhook: %v", err)
+				http.Error(w, "invalid signature", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		var payload webhook.Payload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			log.Printf("Invalid payload: %v", err)
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+		log.Printf("Search %q matched %q (%.3f): %s", payload.SearchName, payload.Title, payload.Similarity, payload.Link)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	log.Printf("Listening for webhooks on %d", *port)
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", *port), nil))
+}
@@ -0,0 +1,40 @@
+// Package auth creates the API keys of api-service and issues and verifies the tokens
+// of its local issuer.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+const (
+	// KeyPrefix starts every API key, so keys are told apart from tokens and found by secret scanners.
+	KeyPrefix = "rss_"
+	keyBytes  = 32
+	// shownPrefix is the length of the start of a key kept in the database to tell keys apart.
+	shownPrefix = len(KeyPrefix) + 8
+)
+
+// NewKey returns a random API key with the prefix and the hash which are stored, the key
+// itself is shown once and cannot be recovered.
+func NewKey() (key, prefix string, hash []byte, err error) {
+	secret := make([]byte, keyBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", nil, err
+	}
+	key = KeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return key, key[:shownPrefix], HashKey(key), nil
+}
+
+// IsKey tells whether the credential is an API key rather than a token.
+func IsKey(credential string) bool {
+	return strings.HasPrefix(credential, KeyPrefix)
+}
+
+// HashKey is the SHA-256 of the key, keys are random so they need no salt or slow hash.
+func HashKey(key string) []byte {
+	hash := sha256.Sum256([]byte(key))
+	return hash[:]
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"rss_fetcher/internal/data"
+	"strings"
+	"time"
+)
+
+// DefaultIssuer is the iss claim of the tokens of the local issuer.
+const DefaultIssuer = "rss-fetcher"
+
+// clockSkew is accepted between the clocks of the issuer and the service.
+const clockSkew = 30 * time.Second
+
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Claims are the claims of a token, the scope decides what its bearer may call.
+type Claims struct {
+	Issuer    string        `json:"iss"`
+	Subject   string        `json:"sub"`
+	Scope     data.APIScope `json:"scope"`
+	IssuedAt  int64         `json:"iat"`
+	ExpiresAt int64         `json:"exp"`
+}
+
+// Issuer issues and verifies JWTs signed with HS256 by a shared secret. It stands in
+// for an identity provider in tests and small setups: cmd/admin issues tokens and
+// api-service verifies them with the same secret.
+type Issuer struct {
+	name   string
+	secret []byte
+	now    func() time.Time
+}
+
+func NewIssuer(name, secret string) (*Issuer, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("the token secret must have at least 32 characters")
+	}
+	return &Issuer{name: name, secret: []byte(secret), now: time.Now}, nil
+}
+
+// Issue returns a token of the subject which expires after ttl.
+func (i *Issuer) Issue(subject string, scope data.APIScope, ttl time.Duration) (string, error) {
+	now := i.now()
+	claims := Claims{Issuer: i.name, Subject: subject, Scope: scope, IssuedAt: now.Unix(), ExpiresAt: now.Add(ttl).Unix()}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(i.sign(signed)), nil
+}
+
+// Verify checks the signature, the issuer and the lifetime of the token and returns its claims.
+func (i *Issuer) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("malformed token header")
+	}
+	var fields struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(header, &fields); err != nil || fields.Alg != "HS256" {
+		return nil, errors.New("token must be signed with HS256")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, i.sign(parts[0]+"."+parts[1])) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed token payload")
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+	now := i.now()
+	switch {
+	case claims.Issuer != i.name:
+		return nil, fmt.Errorf("token of another issuer %q", claims.Issuer)
+	case claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)):
+		return nil, errors.New("token has expired")
+	case now.Add(clockSkew).Before(time.Unix(claims.IssuedAt, 0)):
+		return nil, errors.New("token is not valid yet")
+	case claims.Scope != data.ScopeRead && claims.Scope != data.ScopeAdmin:
+		return nil, fmt.Errorf("unknown scope %q", claims.Scope)
+	}
+	return &claims, nil
+}
+
+func (i *Issuer) sign(signed string) []byte {
+	mac := hmac.New(sha256.New, i.secret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
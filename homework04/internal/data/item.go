@@ -6,6 +6,7 @@ import (
 
 type ChannelNews struct {
 	ID          int
+	ChannelID   int
 	Title       string
 	Link        string
 	Description string
@@ -13,22 +14,218 @@ type ChannelNews struct {
 	Category    string
 	PubDate     time.Time
 	GUID        string
+	State       *NewsState // State of the user who listed the news, nil in other lists
+}
+
+// NewsState is what a user did with an article, kept per user over the shared news.
+type NewsState struct {
+	Read    bool
+	Starred bool
 }
 
 type Channel struct {
-	ID          int
-	Link        string        // URL of the channel
-	Title       string        // Title of the channel
-	Description string        // Description of the channel
-	RSSLink     string        // Link inside the RSS feed
-	UpdatedAt   time.Time     // Last updated time of the channel
-	Items       []ChannelNews // List of items in the channel
+	ID           int
+	Link         string            // URL of the channel
+	Title        string            // Title of the channel
+	Description  string            // Description of the channel
+	RSSLink      string            // Link inside the RSS feed
+	UpdatedAt    time.Time         // Last time the feed was checked
+	Name         string            // Display name given by the user, the title is shown when empty
+	Paused       bool              // Feed is not checked while paused
+	PollInterval time.Duration     // Minimal time between checks, the interval of channel-service when 0
+	FullArticles bool              // Articles are downloaded, otherwise the feed description is indexed
+	Headers      map[string]string `json:"-"` // Sent with the requests of the feed and its articles, may carry credentials
+	Failures     int               // Consecutive failed fetches, reset by a success or when the channel is resumed
+	LastSuccess  time.Time         // Last successful fetch, zero if there was none
+	Items        []ChannelNews     // List of items in the channel
+}
+
+// ChannelGroup is a folder of channels. A channel may be in several groups, nested
+// folders of OPML files become groups named by their path, e.g. Tech/Security.
+type ChannelGroup struct {
+	ID         int
+	Name       string
+	ChannelIDs []int // Members of the group
+}
+
+// User is a member of the team sharing the instance. Channels and their news are
+// stored once, users subscribe to the channels they follow.
+type User struct {
+	ID         int
+	Name       string
+	ChannelIDs []int // Subscribed channels
+	CreatedAt  time.Time
+}
+
+// ChannelFetch is one attempt to download the feed of a channel.
+type ChannelFetch struct {
+	ID         int
+	ChannelID  int
+	StartedAt  time.Time
+	Duration   time.Duration
+	StatusCode int    // HTTP status, 0 when no response was received
+	Bytes      int    // Size of the downloaded feed
+	Items      int    // Items in the feed
+	NewItems   int    // Items which were not stored before
+	Error      string // Empty on success
+}
+
+type ChannelStatus string
+
+const (
+	ChannelUnknown ChannelStatus = "unknown" // never fetched
+	ChannelOK      ChannelStatus = "ok"      // the last fetch succeeded
+	ChannelFailing ChannelStatus = "failing" // the last fetch failed
+	ChannelPaused  ChannelStatus = "paused"  // not fetched, by the user or after too many failures
+)
+
+// ChannelHealth summarizes the recent fetches of a channel.
+type ChannelHealth struct {
+	ChannelID   int
+	Status      ChannelStatus
+	Failures    int            // Consecutive failed fetches
+	LastSuccess time.Time      // Zero if there was none
+	LastError   string         // Error of the last failed fetch
+	SuccessRate float64        // Share of successful fetches among the recent ones
+	Fetches     []ChannelFetch // Recent fetches, newest first
 }
 
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"   // waiting for the first run
+	JobRunning   JobStatus = "running"   // claimed by a worker until the lease expires
+	JobCompleted JobStatus = "completed" // article is indexed
+	JobFailed    JobStatus = "failed"    // last attempt failed, waiting for a retry
+	JobDead      JobStatus = "dead"      // all attempts are exhausted
+)
+
 type NewsJob struct {
-	ID        int
-	Link      string
-	Status    int // 0 - pending, 1 - in progress, 2 - completed
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID          int
+	Link        string
+	Status      JobStatus
+	Attempts    int       // Number of started attempts
+	LastError   string    // Error of the last failed attempt
+	NextRunAt   time.Time // Job is not claimed before this time
+	LockedUntil time.Time // Lease of a running job, zero if not running
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type SavedSearch struct {
+	ID         int
+	Name       string
+	Query      string
+	Threshold  float32 // Minimal cosine similarity of a chunk to trigger an alert
+	WebhookURL string
+	Secret     string `json:"-"` // HMAC key used to sign webhook payloads
+	GroupID    int    // Only news of the channels in the group raise alerts, all news when 0
+	CreatedAt  time.Time
+}
+
+type SearchAlert struct {
+	ID         int
+	Search     SavedSearch
+	News       ChannelNews
+	Similarity float32
+	Chunk      string // Chunk of the article which matched the search
+	Status     int    // 0 - pending, 1 - delivered, 2 - failed
+	Attempts   int
+	LastError  string
+	CreatedAt  time.Time
+}
+
+type ProfileStatus string
+
+const (
+	ProfileBuilding ProfileStatus = "building" // corpus is being re-embedded, new articles are indexed too
+	ProfileActive   ProfileStatus = "active"   // serves queries and saved searches
+	ProfileRetired  ProfileStatus = "retired"  // replaced by another profile, kept until pruned
+)
+
+// EmbeddingProfile describes one set of comparable vectors: all of them
+// are produced by the same model and have the same dimension.
+type EmbeddingProfile struct {
+	ID          int
+	Name        string
+	Model       string // Embedding model name
+	Dims        int    // Dimension of the vectors
+	Status      ProfileStatus
+	Chunker     string // Spec of the chunker which splits articles, see parser.NewChunker
+	Contextual  bool   // Chunks are embedded with a header of the article title, channel and date
+	Index       IndexSettings
+	CreatedAt   time.Time
+	ActivatedAt time.Time
+}
+
+type IndexType string
+
+const (
+	IndexIVFFlat IndexType = "ivfflat"
+	IndexHNSW    IndexType = "hnsw"
+)
+
+// IndexSettings holds the build parameters of the vector index of a profile
+// and the search parameters applied to every query against it.
+type IndexSettings struct {
+	Type           IndexType
+	Lists          int // ivfflat: number of inverted lists
+	Probes         int // ivfflat: lists scanned by a query
+	M              int // hnsw: connections per layer
+	EfConstruction int // hnsw: candidate list size while building
+	EfSearch       int // hnsw: candidate list size while searching
+}
+
+var DefaultIndexSettings = IndexSettings{
+	Type:           IndexHNSW,
+	Lists:          100,
+	Probes:         10,
+	M:              16,
+	EfConstruction: 64,
+	EfSearch:       40,
+}
+
+// NewsContent is the readable version of an article extracted from its page.
+type NewsContent struct {
+	NewsID    int
+	Title     string
+	Byline    string
+	Excerpt   string
+	SiteName  string
+	Image     string
+	Length    int    // Length of the text in characters
+	HTML      string // Cleaned article markup
+	Text      string // Plain text of the article
+	FetchedAt time.Time
+}
+
+// ChunkContext describes the article a chunk comes from. It is prepended to the chunks
+// embedded by contextual profiles, so that a chunk is understood without the rest of the article.
+type ChunkContext struct {
+	Title   string
+	Channel string
+	PubDate time.Time
+}
+
+type APIScope string
+
+const (
+	ScopeRead  APIScope = "read"  // GET requests
+	ScopeAdmin APIScope = "admin" // every request
+)
+
+// Allows tells whether a credential of the scope may call an operation which requires the other one.
+func (s APIScope) Allows(required APIScope) bool {
+	return s == ScopeAdmin || s == required
+}
+
+type APIKey struct {
+	ID         int
+	Name       string // Who or what uses the key
+	Prefix     string // Start of the key, tells keys apart without revealing them
+	Hash       []byte `json:"-"` // SHA-256 of the key, the key itself is not stored
+	Scope      APIScope
+	UserID     int // User the key acts for, 0 for keys of services and scripts
+	CreatedAt  time.Time
+	LastUsedAt time.Time // Zero if the key was never used
 }
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"rss_fetcher/internal/data"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const apiKeyColumns = "key_id, name, prefix, key_hash, scope, user_id, created_at, last_used_at"
+
+// keyUsageInterval limits how often the last use of a key is written, every request
+// of a busy client would update the row otherwise.
+const keyUsageInterval = time.Minute
+
+func scanAPIKey(row pgx.Row) (data.APIKey, error) {
+	var key data.APIKey
+	var userID *int
+	var lastUsed *time.Time
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &key.Scope, &userID, &key.CreatedAt, &lastUsed)
+	if userID != nil {
+		key.UserID = *userID
+	}
+	if lastUsed != nil {
+		key.LastUsedAt = *lastUsed
+	}
+	return key, err
+}
+
+func (pg *Postgres) AddAPIKey(ctx context.Context, key data.APIKey) (int, error) {
+	var id int
+	err := pg.pool.QueryRow(ctx, "INSERT INTO api_keys (name, prefix, key_hash, scope, user_id) VALUES ($1, $2, $3, $4, $5) RETURNING key_id",
+		key.Name, key.Prefix, key.Hash, key.Scope, optionalID(key.UserID)).Scan(&id)
+	return id, Classify(err)
+}
+
+func (pg *Postgres) LoadAPIKeys(ctx context.Context) ([]data.APIKey, error) {
+	rows, err := pg.pool.Query(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY key_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]data.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (pg *Postgres) LoadAPIKeyByHash(ctx context.Context, hash []byte) (*data.APIKey, error) {
+	key, err := scanAPIKey(pg.pool.QueryRow(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1", hash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (pg *Postgres) DeleteAPIKey(ctx context.Context, id int) error {
+	tag, err := pg.pool.Exec(ctx, "DELETE FROM api_keys WHERE key_id = $1", id)
+	return rowsAffected(tag, err, "key", id)
+}
+
+func (pg *Postgres) MarkAPIKeyUsed(ctx context.Context, id int) error {
+	_, err := pg.pool.Exec(ctx, `
+		UPDATE api_keys SET last_used_at = NOW()
+		WHERE key_id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - make_interval(secs => $2))`,
+		id, keyUsageInterval.Seconds())
+	return err
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"rss_fetcher/internal/data"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SaveNewsContent stores the readable version of an article, replacing the previous one.
+func (pg *Postgres) SaveNewsContent(ctx context.Context, content data.NewsContent) error {
+	_, err := pg.pool.Exec(ctx, `
+		INSERT INTO news_contents (news_id, title, byline, excerpt, site_name, image, length, html, text, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		ON CONFLICT (news_id) DO UPDATE SET
+			title = EXCLUDED.title, byline = EXCLUDED.byline, excerpt = EXCLUDED.excerpt,
+			site_name = EXCLUDED.site_name, image = EXCLUDED.image, length = EXCLUDED.length,
+			html = EXCLUDED.html, text = EXCLUDED.text, fetched_at = EXCLUDED.fetched_at`,
+		content.NewsID, content.Title, content.Byline, content.Excerpt, content.SiteName, content.Image, content.Length, content.HTML, content.Text)
+	return err
+}
+
+// LoadNewsContent returns the stored readable version of an article, nil if it was not fetched yet.
+func (pg *Postgres) LoadNewsContent(ctx context.Context, newsID int) (*data.NewsContent, error) {
+	var content data.NewsContent
+	err := pg.pool.QueryRow(ctx, `
+		SELECT news_id, title, byline, excerpt, site_name, image, length, html, text, fetched_at
+		FROM news_contents WHERE news_id = $1`, newsID).Scan(
+		&content.NewsID, &content.Title, &content.Byline, &content.Excerpt, &content.SiteName, &content.Image, &content.Length, &content.HTML, &content.Text, &content.FetchedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+func (pg *Postgres) SaveNewsSummary(ctx context.Context, newsID int, model, summary string) error {
+	_, err := pg.pool.Exec(ctx, `
+		INSERT INTO news_summaries (news_id, model, summary) VALUES ($1, $2, $3)
+		ON CONFLICT (news_id, model) DO UPDATE SET summary = EXCLUDED.summary, created_at = NOW()`,
+		newsID, model, summary)
+	return err
+}
+
+func (pg *Postgres) LoadNewsSummaries(ctx context.Context, model string, newsIDs []int) (map[int]string, error) {
+	rows, err := pg.pool.Query(ctx, "SELECT news_id, summary FROM news_summaries WHERE model = $1 AND news_id = ANY($2)",
+		model, newsIDs)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make(map[int]string)
+	var newsID int
+	var summary string
+	_, err = pgx.ForEachRow(rows, []any{&newsID, &summary}, func() error {
+		summaries[newsID] = summary
+		return nil
+	})
+	return summaries, err
+}
+
+// LoadChunkContext returns the title, channel and publication date of an article.
+func (pg *Postgres) LoadChunkContext(ctx context.Context, newsID int) (data.ChunkContext, error) {
+	var chunkContext data.ChunkContext
+	var pubDate *time.Time
+	err := pg.pool.QueryRow(ctx, `
+		SELECT COALESCE(n.title, ''), COALESCE(c.title, ''), n.pub_date
+		FROM channel_news n JOIN channels c ON c.channel_id = n.channel_id
+		WHERE n.news_id = $1`, newsID).Scan(&chunkContext.Title, &chunkContext.Channel, &pubDate)
+	if err != nil {
+		return chunkContext, err
+	}
+	if pubDate != nil {
+		chunkContext.PubDate = *pubDate
+	}
+	return chunkContext, nil
+}
@@ -2,103 +2,101 @@ package db
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"rss_fetcher/internal/data"
 	"time"
 
-	"fmt"
-
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// QueryInterface is implemented by the pool, a connection and a transaction,
+// so the same query helpers run inside and outside of transactions.
 type QueryInterface interface {
-	Query(sql string, args ...interface{}) (pgx.Rows, error)
-	QueryRow(sql string, args ...interface{}) pgx.Row
-	Exec(sql string, args ...interface{}) (pgconn.CommandTag, error)
-}
-
-type ConnectionQuery struct {
-	db *pgx.Conn
-}
-
-type TransactionQuery struct {
-	tx pgx.Tx
-}
-
-func NewTransactionQuery(tx pgx.Tx) *TransactionQuery {
-	return &TransactionQuery{tx: tx}
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
 }
 
-func NewConnectionQuery(db *pgx.Conn) *ConnectionQuery {
-	return &ConnectionQuery{db: db}
+// Postgres is the Store backed by PostgreSQL with the pgvector extension.
+// All queries share one connection pool, which is safe for concurrent use.
+type Postgres struct {
+	pool *pgxpool.Pool
 }
 
-func (c *ConnectionQuery) Query(sql string, args ...interface{}) (pgx.Rows, error) {
-	return c.db.Query(context.Background(), sql, args...)
-}
-
-func (c *ConnectionQuery) QueryRow(sql string, args ...interface{}) pgx.Row {
-	return c.db.QueryRow(context.Background(), sql, args...)
-}
+var _ Store = (*Postgres)(nil)
 
-func (c *ConnectionQuery) Exec(sql string, args ...interface{}) (pgconn.CommandTag, error) {
-	return c.db.Exec(context.Background(), sql, args...)
-}
-
-func (t *TransactionQuery) Exec(sql string, args ...interface{}) (pgconn.CommandTag, error) {
-	return t.tx.Exec(context.Background(), sql, args...)
-}
-
-func (t *TransactionQuery) Query(sql string, args ...interface{}) (pgx.Rows, error) {
-	return t.tx.Query(context.Background(), sql, args...)
-}
-
-func (t *TransactionQuery) QueryRow(sql string, args ...interface{}) pgx.Row {
-	return t.tx.QueryRow(context.Background(), sql, args...)
+// NewPostgres connects to the database. The pool connects lazily, so the
+// connection is checked with a ping.
+func NewPostgres(ctx context.Context, connectionUrl string) (*Postgres, error) {
+	pool, err := pgxpool.New(ctx, connectionUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return &Postgres{pool: pool}, nil
 }
 
-func InitDB(connectionUrl string) (*pgx.Conn, error) {
-	return pgx.Connect(context.Background(), connectionUrl)
+// Close closes the connection pool.
+func (pg *Postgres) Close() {
+	pg.pool.Close()
 }
 
-func StartTransaction(db *pgx.Conn) (pgx.Tx, error) {
-	tx, err := db.Begin(context.Background())
+// inTx runs fn in a transaction, which is committed when fn succeeds and rolled back otherwise.
+func (pg *Postgres) inTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := pg.pool.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to start transaction: %w", err)
 	}
-	return tx, nil
-}
+	defer tx.Rollback(context.Background())
 
-func CommitTransaction(tx pgx.Tx) error {
-	if err := tx.Commit(context.Background()); err != nil {
+	if err := fn(tx); err != nil {
 		return err
 	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit error: %w", err)
+	}
 	return nil
 }
 
-func RollbackTransaction(tx pgx.Tx) error {
-	if err := tx.Rollback(context.Background()); err != nil {
-		return err
-	}
-	return nil
+func (pg *Postgres) AddJob(ctx context.Context, link string) error {
+	return addJob(ctx, pg.pool, link)
 }
 
-func AddJob(db QueryInterface, link string) error {
-	_, err := db.Exec("INSERT INTO news_jobs (link) VALUES ($1)", link)
+func addJob(ctx context.Context, db QueryInterface, link string) error {
+	_, err := db.Exec(ctx, "INSERT INTO news_jobs (link) VALUES ($1)", link)
 	return err
 }
 
-func DeleteJob(db QueryInterface, id int) error {
-	_, err := db.Exec("DELETE FROM news_jobs WHERE job_id = $1", id)
-	return err
+func (pg *Postgres) DeleteJob(ctx context.Context, id int) error {
+	tag, err := pg.pool.Exec(ctx, "DELETE FROM news_jobs WHERE job_id = $1", id)
+	return rowsAffected(tag, err, "job", id)
 }
 
-func LoadJobs(db QueryInterface, filter string) ([]data.NewsJob, error) {
-	if filter != "" {
-		filter = " WHERE " + filter
+const jobColumns = "job_id, link, status, attempts, last_error, next_run_at, locked_until, created_at, updated_at"
+
+func scanJob(row pgx.Row) (data.NewsJob, error) {
+	var job data.NewsJob
+	var lockedUntil *time.Time
+
+	if err := row.Scan(&job.ID, &job.Link, &job.Status, &job.Attempts, &job.LastError, &job.NextRunAt, &lockedUntil, &job.CreatedAt, &job.UpdatedAt); err != nil {
+		return job, err
+	}
+	if lockedUntil != nil {
+		job.LockedUntil = *lockedUntil
 	}
-	rows, err := db.Query("SELECT job_id, link, status, created_at, updated_at FROM news_jobs" + filter)
+	return job, nil
+}
+
+func (pg *Postgres) LoadJobs(ctx context.Context, filter JobFilter) ([]data.NewsJob, error) {
+	where := filter.apply(&where{param: "$"})
+	rows, err := pg.pool.Query(ctx, "SELECT "+jobColumns+" FROM news_jobs"+where.String()+filter.order(), where.args...)
 	if err != nil {
 		return nil, err
 	}
@@ -107,21 +105,10 @@ func LoadJobs(db QueryInterface, filter string) ([]data.NewsJob, error) {
 	jobs := make([]data.NewsJob, 0)
 
 	for rows.Next() {
-		var id, status int
-		var link string
-		var createdAt, updatedAt time.Time
-
-		if err := rows.Scan(&id, &link, &status, &createdAt, &updatedAt); err != nil {
+		job, err := scanJob(rows)
+		if err != nil {
 			return nil, err
 		}
-
-		job := data.NewsJob{
-			ID:        id,
-			Link:      link,
-			Status:    status,
-			CreatedAt: createdAt,
-			UpdatedAt: updatedAt,
-		}
 		jobs = append(jobs, job)
 	}
 
@@ -132,28 +119,159 @@ func LoadJobs(db QueryInterface, filter string) ([]data.NewsJob, error) {
 	return jobs, nil
 }
 
-func UpdateJobStatus(db QueryInterface, id int, status int) error {
-	_, err := db.Exec("UPDATE news_jobs SET status = $1, updated_at = NOW() WHERE job_id = $2", status, id)
+// ClaimJob takes the next runnable job and leases it to the caller until the lease expires.
+// Runnable jobs are pending or failed jobs whose retry time has come and running jobs
+// whose lease expired because the worker died, unless they used up maxAttempts; those
+// are left to ReapJobs. Rows locked by concurrent claims are skipped, so several
+// news-service replicas can share the queue.
+// Returns nil when there is nothing to do.
+func (pg *Postgres) ClaimJob(ctx context.Context, lease time.Duration, maxAttempts int) (*data.NewsJob, error) {
+	job, err := scanJob(pg.pool.QueryRow(ctx, `
+		UPDATE news_jobs
+		SET status = 'running', attempts = attempts + 1, locked_until = NOW() + make_interval(secs => $1), updated_at = NOW()
+		WHERE job_id = (
+			SELECT job_id FROM news_jobs
+			WHERE (status IN ('pending', 'failed') AND next_run_at <= NOW())
+				OR (status = 'running' AND locked_until < NOW() AND attempts < $2)
+			ORDER BY next_run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+jobColumns, lease.Seconds(), maxAttempts))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RenewJob extends the lease of a running job, so a worker processing it for longer
+// than the lease keeps it.
+func (pg *Postgres) RenewJob(ctx context.Context, id int, lease time.Duration) error {
+	tag, err := pg.pool.Exec(ctx, `
+		UPDATE news_jobs
+		SET locked_until = NOW() + make_interval(secs => $2), updated_at = NOW()
+		WHERE job_id = $1 AND status = 'running'`, id, lease.Seconds())
+	return rowsAffected(tag, err, "job", id)
+}
+
+func (pg *Postgres) CompleteJob(ctx context.Context, id int) error {
+	_, err := pg.pool.Exec(ctx, "UPDATE news_jobs SET status = 'completed', last_error = '', locked_until = NULL, updated_at = NOW() WHERE job_id = $1", id)
 	return err
 }
 
-func AddChannel(db *pgx.Conn, link string) error {
-	_, err := db.Exec(context.Background(), "INSERT INTO channels (link) VALUES ($1)", link)
+// FailJob records a failed attempt. The job is retried after an exponential
+// backoff (backoff, 2*backoff, 4*backoff, ...) or becomes dead once maxAttempts
+// attempts have been made.
+func (pg *Postgres) FailJob(ctx context.Context, id int, reason string, maxAttempts int, backoff time.Duration) error {
+	_, err := pg.pool.Exec(ctx, `
+		UPDATE news_jobs
+		SET status = CASE WHEN attempts >= $3 THEN 'dead' ELSE 'failed' END,
+			last_error = $2,
+			next_run_at = NOW() + make_interval(secs => $4 * power(2, GREATEST(attempts - 1, 0))),
+			locked_until = NULL,
+			updated_at = NOW()
+		WHERE job_id = $1`, id, reason, maxAttempts, backoff.Seconds())
 	return err
 }
 
-func DeleteChannel(db *pgx.Conn, id int) error {
-	_, err := db.Exec(context.Background(), "DELETE FROM channels WHERE channel_id = $1", id)
+// ReapJobs buries running jobs whose lease expired after the last allowed attempt,
+// so a job crashing its worker is not retried forever.
+func (pg *Postgres) ReapJobs(ctx context.Context, maxAttempts int) (int64, error) {
+	tag, err := pg.pool.Exec(ctx, `
+		UPDATE news_jobs
+		SET status = 'dead', last_error = 'lease expired', locked_until = NULL, updated_at = NOW()
+		WHERE status = 'running' AND locked_until < NOW() AND attempts >= $1`, maxAttempts)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (pg *Postgres) AddChannel(ctx context.Context, link string) (int, error) {
+	var id int
+	err := pg.pool.QueryRow(ctx, "INSERT INTO channels (link) VALUES ($1) RETURNING channel_id", link).Scan(&id)
+	return id, Classify(err)
+}
+
+func (pg *Postgres) DeleteChannel(ctx context.Context, id int) error {
+	tag, err := pg.pool.Exec(ctx, "DELETE FROM channels WHERE channel_id = $1", id)
+	return rowsAffected(tag, err, "channel", id)
+}
+
+func (pg *Postgres) DeleteChannels(ctx context.Context) error {
+	_, err := pg.pool.Exec(ctx, "DELETE FROM channels;")
 	return err
 }
 
-func DeleteChannels(db *pgx.Conn) error {
-	_, err := db.Exec(context.Background(), "DELETE FROM channels;")
+// headers are read as text, which both Postgres (JSONB) and SQLite (TEXT) return the same way
+const channelColumns = "channel_id, link, title, description, rss_link, last_updated, name, paused, poll_interval, full_articles, CAST(headers AS TEXT), " +
+	"consecutive_failures, last_success_at"
+
+func scanChannel(row pgx.Row) (data.Channel, error) {
+	var channel data.Channel
+	var title, description, rssLink *string
+	var pollInterval int64
+	var headers string
+	var lastSuccess *time.Time
+
+	if err := row.Scan(&channel.ID, &channel.Link, &title, &description, &rssLink, &channel.UpdatedAt,
+		&channel.Name, &channel.Paused, &pollInterval, &channel.FullArticles, &headers,
+		&channel.Failures, &lastSuccess); err != nil {
+		return channel, err
+	}
+	if lastSuccess != nil {
+		channel.LastSuccess = *lastSuccess
+	}
+	channel.PollInterval = time.Duration(pollInterval) * time.Second
+	if err := json.Unmarshal([]byte(headers), &channel.Headers); err != nil {
+		return channel, fmt.Errorf("invalid headers of channel %d: %w", channel.ID, err)
+	}
+	if title != nil {
+		channel.Title = *title
+	}
+	if description != nil {
+		channel.Description = *description
+	}
+	if rssLink != nil {
+		channel.RSSLink = *rssLink
+	}
+	return channel, nil
+}
+
+// encodeHeaders returns the JSON object stored in the headers column.
+func encodeHeaders(headers map[string]string) string {
+	if len(headers) == 0 {
+		return "{}"
+	}
+	encoded, _ := json.Marshal(headers)
+	return string(encoded)
+}
+
+// UpdateChannel stores the link and the settings of the channel. Resuming a paused
+// channel resets its failures, so it is not paused again by the next failed fetch.
+func (pg *Postgres) UpdateChannel(ctx context.Context, channel data.Channel) error {
+	tag, err := pg.pool.Exec(ctx, `
+		UPDATE channels SET link = $2, name = $3, paused = $4, poll_interval = $5, full_articles = $6, headers = $7,
+			consecutive_failures = CASE WHEN paused AND NOT $4 THEN 0 ELSE consecutive_failures END
+		WHERE channel_id = $1`,
+		channel.ID, channel.Link, channel.Name, channel.Paused, int64(channel.PollInterval/time.Second), channel.FullArticles,
+		encodeHeaders(channel.Headers))
+	return rowsAffected(tag, err, "channel", channel.ID)
+}
+
+// MarkChannelChecked records that the feed of the channel was just checked.
+func (pg *Postgres) MarkChannelChecked(ctx context.Context, id int) error {
+	// the time is compared with poll intervals in Go, so it is written in UTC like it is read
+	_, err := pg.pool.Exec(ctx, "UPDATE channels SET last_updated = $2 WHERE channel_id = $1", id, time.Now().UTC())
 	return err
 }
 
-func LoadChannels(db *pgx.Conn) ([]data.Channel, error) {
-	rows, err := db.Query(context.Background(), "SELECT channel_id, link, title, description, rss_link, last_updated FROM channels")
+func (pg *Postgres) LoadChannels(ctx context.Context, filter ChannelFilter) ([]data.Channel, error) {
+	where := filter.apply(&where{param: "$"})
+	rows, err := pg.pool.Query(ctx, "SELECT "+channelColumns+" FROM channels"+where.String()+filter.order(), where.args...)
 	if err != nil {
 		return nil, err
 	}
@@ -162,22 +280,10 @@ func LoadChannels(db *pgx.Conn) ([]data.Channel, error) {
 	channels := make([]data.Channel, 0)
 
 	for rows.Next() {
-		var id int
-		var link, title, description, rssLink string
-		var updatedAt time.Time
-
-		if err := rows.Scan(&id, &link, &title, &description, &rssLink, &updatedAt); err != nil {
+		channel, err := scanChannel(rows)
+		if err != nil {
 			return nil, err
 		}
-
-		channel := data.Channel{
-			ID:          id,
-			Link:        link,
-			Title:       title,
-			Description: description,
-			RSSLink:     rssLink,
-			UpdatedAt:   updatedAt,
-		}
 		channels = append(channels, channel)
 	}
 
@@ -188,81 +294,77 @@ func LoadChannels(db *pgx.Conn) ([]data.Channel, error) {
 	return channels, nil
 }
 
-func LoadChannel(db *pgx.Conn, id int) (*data.Channel, error) {
-	row, err := db.Query(context.Background(), "SELECT link, title, rss_link, description, last_updated FROM channels WHERE channel_id = $1", id)
-	if err != nil {
-		return nil, err
+func (pg *Postgres) LoadChannel(ctx context.Context, id int) (*data.Channel, error) {
+	result, err := scanChannel(pg.pool.QueryRow(ctx, "SELECT "+channelColumns+" FROM channels WHERE channel_id = $1", id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
 	}
-	defer row.Close()
-	if !row.Next() {
-		return nil, errors.New("channel not found")
-	}
-	var title, link, rssLink, description string
-	var lastUpdated time.Time
-	if err := row.Scan(&id, &title, &link, &rssLink, &description, &lastUpdated); err != nil {
+	if err != nil {
 		return nil, err
 	}
 
-	result := &data.Channel{
-		ID:          id,
-		Title:       title,
-		Link:        link,
-		RSSLink:     rssLink,
-		Description: description,
-		UpdatedAt:   lastUpdated,
-	}
-
-	items, err := loadNews(&ConnectionQuery{db}, "channel_id = "+fmt.Sprint(result.ID))
+	items, err := loadNews(ctx, pg.pool, NewsFilter{ChannelID: result.ID})
 	if err != nil {
 		return nil, err
 	}
 	result.Items = items
-	return result, nil
+	return &result, nil
+}
+
+func (pg *Postgres) AddNewsWithJob(ctx context.Context, channelID int, news data.ChannelNews) (bool, error) {
+	added := false
+	err := pg.inTx(ctx, func(tx pgx.Tx) error {
+		if rows, err := loadNews(ctx, tx, NewsFilter{Link: news.Link}); err != nil {
+			return fmt.Errorf("failed to load news by link %s: %w", news.Link, err)
+		} else if len(rows) > 0 {
+			return nil
+		}
+		if err := addNews(ctx, tx, channelID, news); err != nil {
+			return fmt.Errorf("failed to save news item %s: %w", news.Link, err)
+		}
+		if err := addJob(ctx, tx, news.Link); err != nil {
+			return fmt.Errorf("failed to add job for news item %s: %w", news.Link, err)
+		}
+		added = true
+		return nil
+	})
+	return added, err
 }
 
-func AddNews(db QueryInterface, channelID int, news data.ChannelNews) error {
-	_, err := db.Exec("INSERT INTO channel_news (channel_id, title, link, description, author, category, pub_date, guid) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+func addNews(ctx context.Context, db QueryInterface, channelID int, news data.ChannelNews) error {
+	_, err := db.Exec(ctx, "INSERT INTO channel_news (channel_id, title, link, description, author, category, pub_date, guid) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
 		channelID, news.Title, news.Link, news.Description, news.Author, news.Category, news.PubDate, news.GUID)
 	return err
 }
 
-func DeleteNews(db *pgx.Conn, id int) error {
-	_, err := db.Exec(context.Background(), "DELETE FROM channel_news WHERE news_id = $1", id)
-	return err
+func (pg *Postgres) DeleteNews(ctx context.Context, id int) error {
+	tag, err := pg.pool.Exec(ctx, "DELETE FROM channel_news WHERE news_id = $1", id)
+	return rowsAffected(tag, err, "news", id)
 }
 
-func loadNews(db QueryInterface, filter string) ([]data.ChannelNews, error) {
+func loadNews(ctx context.Context, db QueryInterface, filter NewsFilter) ([]data.ChannelNews, error) {
 	result := make([]data.ChannelNews, 0)
 
-	var query string = "SELECT news_id, title, link, description, author, category, pub_date, guid FROM channel_news"
-	if filter != "" {
-		query += " WHERE " + filter
-	}
+	where := &where{param: "$"}
+	query := "SELECT " + filter.columns(where) + " FROM channel_news"
+	filter.apply(where)
+	query += where.String() + filter.order()
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(ctx, query, where.args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var id int
-		var title, link, description, author, category, guid string
-		var pubDate time.Time
-
-		if err := rows.Scan(&id, &title, &link, &description, &author, &category, &pubDate, &guid); err != nil {
-			return nil, err
+		var item data.ChannelNews
+		dest := []any{&item.ID, &item.ChannelID, &item.Title, &item.Link, &item.Description, &item.Author, &item.Category, &item.PubDate, &item.GUID}
+		if filter.UserID != 0 {
+			item.State = &data.NewsState{}
+			dest = append(dest, &item.State.Read, &item.State.Starred)
 		}
-
-		item := data.ChannelNews{
-			ID:          id,
-			Title:       title,
-			Link:        link,
-			Description: description,
-			Author:      author,
-			Category:    category,
-			PubDate:     pubDate,
-			GUID:        guid,
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
 		}
 		result = append(result, item)
 	}
@@ -274,16 +376,22 @@ func loadNews(db QueryInterface, filter string) ([]data.ChannelNews, error) {
 	return result, nil
 }
 
-func LoadNews(db QueryInterface) ([]data.ChannelNews, error) {
-	return loadNews(db, "")
+func (pg *Postgres) LoadNews(ctx context.Context, filter NewsFilter) ([]data.ChannelNews, error) {
+	return loadNews(ctx, pg.pool, filter)
 }
 
-func LoadNewsByLink(db QueryInterface, link string) ([]data.ChannelNews, error) {
-	return loadNews(db, "link = '"+link+"'")
+func (pg *Postgres) LoadNewsByLink(ctx context.Context, link string) (*data.ChannelNews, error) {
+	news, err := loadNews(ctx, pg.pool, NewsFilter{Link: link})
+	if err != nil || len(news) == 0 {
+		return nil, err
+	}
+	return &news[0], nil
 }
 
-func Close(db *pgx.Conn) {
-	if db != nil {
-		db.Close(context.Background())
+func (pg *Postgres) LoadNewsByID(ctx context.Context, id int) (*data.ChannelNews, error) {
+	news, err := loadNews(ctx, pg.pool, NewsFilter{ID: id})
+	if err != nil || len(news) == 0 {
+		return nil, err
 	}
+	return &news[0], nil
 }
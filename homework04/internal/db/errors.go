@@ -0,0 +1,128 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Errors of the stores, the same for Postgres, SQLite and Memory. They are tested
+// with errors.Is, the typed errors below carry the details.
+var (
+	// ErrNotFound is returned by updates and deletes of rows which do not exist,
+	// loads of a single row return nil instead.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is a violated unique constraint, e.g. a channel added twice.
+	ErrConflict = errors.New("already exists")
+	// ErrReference is a violated foreign key: the referenced row does not exist.
+	ErrReference = errors.New("referenced row does not exist")
+	// ErrUnavailable means the database can not be reached or is too busy, the
+	// operation may succeed when it is retried later.
+	ErrUnavailable = errors.New("database unavailable")
+)
+
+// NotFoundError tells which row was not found, it matches ErrNotFound.
+type NotFoundError struct {
+	Entity string // channel, news, group, search...
+	ID     int
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %d not found", e.Entity, e.ID)
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// ConstraintError is a violated unique constraint, matching ErrConflict, or a
+// violated foreign key, matching ErrReference.
+type ConstraintError struct {
+	Constraint string // name of the Postgres constraint, e.g. channels_link_key
+	Kind       error  // ErrConflict or ErrReference
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Kind, e.Constraint)
+}
+
+func (e *ConstraintError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// uniqueViolation is the error of a duplicate key in all stores.
+func uniqueViolation(constraint string) error {
+	return &ConstraintError{Constraint: constraint, Kind: ErrConflict}
+}
+
+// missingReference is the error of a foreign key pointing to nothing in all stores.
+func missingReference(constraint string) error {
+	return &ConstraintError{Constraint: constraint, Kind: ErrReference}
+}
+
+// rowsAffected returns a *NotFoundError when the statement of Postgres changed no row.
+func rowsAffected(tag pgconn.CommandTag, err error, entity string, id int) error {
+	if err != nil {
+		return Classify(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return &NotFoundError{Entity: entity, ID: id}
+	}
+	return nil
+}
+
+// unavailableError keeps the driver error for the logs.
+type unavailableError struct {
+	err error
+}
+
+func (e *unavailableError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrUnavailable, e.err)
+}
+
+func (e *unavailableError) Unwrap() error {
+	return e.err
+}
+
+func (e *unavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+// Classify turns driver errors into the errors of this package: violated constraints
+// into *ConstraintError, lost connections, timeouts and busy databases into
+// ErrUnavailable. Other errors, and errors classified before, are returned as they are.
+func Classify(err error) error {
+	var pgErr *pgconn.PgError
+	var sqliteErr sqlite3.Error
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	switch {
+	case err == nil, errors.Is(err, ErrNotFound), errors.Is(err, ErrConflict), errors.Is(err, ErrReference),
+		errors.Is(err, ErrUnavailable):
+		return err
+	case errors.As(err, &pgErr):
+		switch {
+		case pgErr.Code == "23505":
+			return uniqueViolation(pgErr.ConstraintName)
+		case pgErr.Code == "23503":
+			return missingReference(pgErr.ConstraintName)
+		// connection exceptions, insufficient resources and server shutdowns
+		case strings.HasPrefix(pgErr.Code, "08"), strings.HasPrefix(pgErr.Code, "53"), strings.HasPrefix(pgErr.Code, "57P"):
+			return &unavailableError{err: err}
+		}
+	case errors.As(err, &sqliteErr):
+		switch {
+		case sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey:
+			return missingReference("")
+		case sqliteErr.Code == sqlite3.ErrBusy, sqliteErr.Code == sqlite3.ErrLocked:
+			return &unavailableError{err: err}
+		}
+	case errors.As(err, &connectErr), pgconn.Timeout(err), errors.As(err, &netErr):
+		return &unavailableError{err: err}
+	}
+	return err
+}
@@ -0,0 +1,311 @@
+package db
+
+import (
+	"cmp"
+	"fmt"
+	"rss_fetcher/internal/data"
+	"strings"
+	"time"
+)
+
+// NewsFilter selects news items. Zero fields match everything, set fields must all match.
+type NewsFilter struct {
+	ID        int
+	ChannelID int
+	Link      string
+	Author    string
+	Category  string
+	Title     string    // case-insensitive substring of the title
+	GroupID   int       // news of the channels in the group
+	SearchID  int       // news which raised alerts of the saved search
+	UserID    int       // news of the channels the user subscribes to, with the state of the user
+	Unread    bool      // news the user has not read, needs UserID
+	Starred   bool      // news the user starred, needs UserID
+	From      time.Time // published at or after
+	To        time.Time // published before
+	Order     NewsOrder
+	After     *NewsCursor // continues a list after this item, in the same order
+	Limit     int         // maximum number of items, all when 0
+}
+
+type NewsOrder string
+
+const (
+	NewsByID          NewsOrder = ""          // order of insertion
+	NewsByPubDate     NewsOrder = "pub_date"  // oldest first
+	NewsByPubDateDesc NewsOrder = "-pub_date" // newest first
+)
+
+// NewsCursor is the position of an item in a list of news, ties of the
+// publication date are broken by id.
+type NewsCursor struct {
+	PubDate time.Time
+	ID      int
+}
+
+// ChannelFilter selects channels, in the order they were added.
+type ChannelFilter struct {
+	ID      int
+	Link    string
+	UserID  int // channels the user subscribes to
+	AfterID int // continues a list after this channel
+	Limit   int // maximum number of channels, all when 0
+}
+
+// DocumentScope restricts the chunks a query retrieves, the zero value searches all of them.
+type DocumentScope struct {
+	GroupID int // chunks of the news of the channels in the group
+	UserID  int // chunks of the news of the channels the user subscribes to
+}
+
+// JobFilter selects news jobs. Zero fields match everything, set fields must all match.
+type JobFilter struct {
+	ID      int
+	Link    string
+	Status  data.JobStatus
+	AfterID int // continues a list after this job
+	Limit   int // maximum number of jobs, all when 0
+}
+
+// newsPubDate sorts news without a publication date before the others. The literal
+// is read the same way by both databases.
+const newsPubDate = "COALESCE(pub_date, '1970-01-01 00:00:00+00:00')"
+
+func (f NewsFilter) apply(w *where) *where {
+	if f.ID != 0 {
+		w.equal("news_id", f.ID)
+	}
+	if f.ChannelID != 0 {
+		w.equal("channel_id", f.ChannelID)
+	}
+	if f.Link != "" {
+		w.equal("link", f.Link)
+	}
+	if f.Author != "" {
+		w.equal("author", f.Author)
+	}
+	if f.Category != "" {
+		w.equal("category", f.Category)
+	}
+	if f.Title != "" {
+		w.contains("title", f.Title)
+	}
+	if f.GroupID != 0 {
+		w.in("channel_id", "SELECT channel_id FROM channel_group_members WHERE group_id = %s", f.GroupID)
+	}
+	if f.SearchID != 0 {
+		w.in("news_id", "SELECT news_id FROM saved_search_alerts WHERE search_id = %s", f.SearchID)
+	}
+	if f.UserID != 0 {
+		w.in("channel_id", "SELECT channel_id FROM subscriptions WHERE user_id = %s", f.UserID)
+		if f.Unread {
+			w.notIn("news_id", "SELECT news_id FROM news_states WHERE read AND user_id = %s", f.UserID)
+		}
+		if f.Starred {
+			w.in("news_id", "SELECT news_id FROM news_states WHERE starred AND user_id = %s", f.UserID)
+		}
+	}
+	if !f.From.IsZero() {
+		w.compare("pub_date", ">=", f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		w.compare("pub_date", "<", f.To.UTC())
+	}
+	if f.After != nil {
+		switch f.Order {
+		case NewsByPubDate:
+			w.compareRow(newsPubDate, "news_id", ">", f.After.PubDate.UTC(), f.After.ID)
+		case NewsByPubDateDesc:
+			w.compareRow(newsPubDate, "news_id", "<", f.After.PubDate.UTC(), f.After.ID)
+		default:
+			w.compare("news_id", ">", f.After.ID)
+		}
+	}
+	return w
+}
+
+// newsColumns are the columns of news lists.
+const newsColumns = "news_id, channel_id, title, link, description, author, category, pub_date, guid"
+
+// columns returns the columns of the list, lists of a user add the read and starred
+// state of the user.
+func (f NewsFilter) columns(w *where) string {
+	if f.UserID == 0 {
+		return newsColumns
+	}
+	user := w.arg(f.UserID)
+	return newsColumns + fmt.Sprintf(`,
+		EXISTS (SELECT 1 FROM news_states s WHERE s.news_id = channel_news.news_id AND s.user_id = %[1]s AND s.read),
+		EXISTS (SELECT 1 FROM news_states s WHERE s.news_id = channel_news.news_id AND s.user_id = %[1]s AND s.starred)`, user)
+}
+
+// order returns the ORDER BY and LIMIT clauses.
+func (f NewsFilter) order() string {
+	var order string
+	switch f.Order {
+	case NewsByPubDate:
+		order = " ORDER BY " + newsPubDate + ", news_id"
+	case NewsByPubDateDesc:
+		order = " ORDER BY " + newsPubDate + " DESC, news_id DESC"
+	default:
+		order = " ORDER BY news_id"
+	}
+	return order + limit(f.Limit)
+}
+
+// matches checks the fields of the item, GroupID, SearchID and UserID are checked by the store.
+func (f NewsFilter) matches(news data.ChannelNews) bool {
+	return (f.ID == 0 || f.ID == news.ID) &&
+		(f.ChannelID == 0 || f.ChannelID == news.ChannelID) &&
+		(f.Link == "" || f.Link == news.Link) &&
+		(f.Author == "" || f.Author == news.Author) &&
+		(f.Category == "" || f.Category == news.Category) &&
+		(f.Title == "" || strings.Contains(strings.ToLower(news.Title), strings.ToLower(f.Title))) &&
+		(f.From.IsZero() || !news.PubDate.Before(f.From)) &&
+		(f.To.IsZero() || news.PubDate.Before(f.To)) &&
+		(f.After == nil || f.compare(news, *f.After) > 0)
+}
+
+// compare orders two items the way the filter lists them.
+func (f NewsFilter) compare(news data.ChannelNews, cursor NewsCursor) int {
+	switch f.Order {
+	case NewsByPubDate:
+		return cmp.Or(news.PubDate.Compare(cursor.PubDate), cmp.Compare(news.ID, cursor.ID))
+	case NewsByPubDateDesc:
+		return -cmp.Or(news.PubDate.Compare(cursor.PubDate), cmp.Compare(news.ID, cursor.ID))
+	default:
+		return cmp.Compare(news.ID, cursor.ID)
+	}
+}
+
+func (f ChannelFilter) apply(w *where) *where {
+	if f.ID != 0 {
+		w.equal("channel_id", f.ID)
+	}
+	if f.Link != "" {
+		w.equal("link", f.Link)
+	}
+	if f.UserID != 0 {
+		w.in("channel_id", "SELECT channel_id FROM subscriptions WHERE user_id = %s", f.UserID)
+	}
+	if f.AfterID != 0 {
+		w.compare("channel_id", ">", f.AfterID)
+	}
+	return w
+}
+
+func (f ChannelFilter) order() string {
+	return " ORDER BY channel_id" + limit(f.Limit)
+}
+
+// matches checks the fields of the channel, UserID is checked by the store.
+func (f ChannelFilter) matches(channel data.Channel) bool {
+	return (f.ID == 0 || f.ID == channel.ID) &&
+		(f.Link == "" || f.Link == channel.Link) &&
+		channel.ID > f.AfterID
+}
+
+func (s DocumentScope) apply(w *where) *where {
+	if s.GroupID != 0 {
+		w.in("news_id", `SELECT n.news_id FROM channel_news n
+			JOIN channel_group_members m ON m.channel_id = n.channel_id WHERE m.group_id = %s`, s.GroupID)
+	}
+	if s.UserID != 0 {
+		w.in("news_id", `SELECT n.news_id FROM channel_news n
+			JOIN subscriptions s ON s.channel_id = n.channel_id WHERE s.user_id = %s`, s.UserID)
+	}
+	return w
+}
+
+func (f JobFilter) apply(w *where) *where {
+	if f.ID != 0 {
+		w.equal("job_id", f.ID)
+	}
+	if f.Link != "" {
+		w.equal("link", f.Link)
+	}
+	if f.Status != "" {
+		w.equal("status", f.Status)
+	}
+	if f.AfterID != 0 {
+		w.compare("job_id", ">", f.AfterID)
+	}
+	return w
+}
+
+func (f JobFilter) order() string {
+	return " ORDER BY job_id" + limit(f.Limit)
+}
+
+func (f JobFilter) matches(job data.NewsJob) bool {
+	return (f.ID == 0 || f.ID == job.ID) &&
+		(f.Link == "" || f.Link == job.Link) &&
+		(f.Status == "" || f.Status == job.Status) &&
+		job.ID > f.AfterID
+}
+
+func limit(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" LIMIT %d", n)
+}
+
+// limited truncates the items of an in-memory list the way LIMIT does.
+func limited[T any](items []T, n int) []T {
+	if n > 0 && len(items) > n {
+		return items[:n]
+	}
+	return items
+}
+
+// where builds a WHERE clause from conditions on columns. Values are always passed
+// as bind parameters, only the column names, which come from this package, are
+// written into the SQL text.
+type where struct {
+	param      string // prefix of numbered parameters, $ for Postgres and ? for SQLite
+	conditions []string
+	args       []any
+}
+
+func (w *where) equal(column string, value any) {
+	w.compare(column, "=", value)
+}
+
+func (w *where) compare(column, operator string, value any) {
+	w.conditions = append(w.conditions, fmt.Sprintf("%s %s %s", column, operator, w.arg(value)))
+}
+
+// compareRow compares two columns at once, e.g. a sort key and the id breaking its ties.
+func (w *where) compareRow(first, second, operator string, firstValue, secondValue any) {
+	w.conditions = append(w.conditions, fmt.Sprintf("(%s, %s) %s (%s, %s)", first, second, operator, w.arg(firstValue), w.arg(secondValue)))
+}
+
+// in matches the column with the values of a subquery, which has a %s verb for the parameter.
+func (w *where) in(column, subquery string, value any) {
+	w.conditions = append(w.conditions, fmt.Sprintf("%s IN (%s)", column, fmt.Sprintf(subquery, w.arg(value))))
+}
+
+// notIn excludes the values of a subquery, which has a %s verb for the parameter.
+func (w *where) notIn(column, subquery string, value any) {
+	w.conditions = append(w.conditions, fmt.Sprintf("%s NOT IN (%s)", column, fmt.Sprintf(subquery, w.arg(value))))
+}
+
+// contains matches a case-insensitive substring, wildcards in the value are escaped.
+func (w *where) contains(column, value string) {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+	w.conditions = append(w.conditions, fmt.Sprintf(`lower(%s) LIKE '%%' || lower(%s) || '%%' ESCAPE '\'`, column, w.arg(escaped)))
+}
+
+func (w *where) arg(value any) string {
+	w.args = append(w.args, value)
+	return fmt.Sprintf("%s%d", w.param, len(w.args))
+}
+
+// String returns the clause with a leading space, or nothing without conditions.
+func (w *where) String() string {
+	if len(w.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conditions, " AND ")
+}
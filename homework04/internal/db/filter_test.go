@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"rss_fetcher/internal/data"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testStores returns an empty Memory and an empty SQLite store in a temporary file,
+// the stores that run without a server. The SQLite store is closed after the test.
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	sqlite, err := NewSQLite(context.Background(), filepath.Join(t.TempDir(), "rss.db"))
+	if err != nil {
+		t.Fatalf("failed to open sqlite store: %v", err)
+	}
+	t.Cleanup(sqlite.Close)
+	return map[string]Store{
+		"memory": NewMemory(),
+		"sqlite": sqlite,
+	}
+}
+
+// hostileNews are feed items whose titles and links are made of quotes, comment
+// markers, LIKE wildcards and escape characters.
+var hostileNews = []data.ChannelNews{
+	{Title: "It's news", Link: "https://example.com/it's?q='1'"},
+	{Title: "Before -- after", Link: "https://example.com/a--b"},
+	{Title: "100% sure", Link: "https://example.com/p?x=100%"},
+	{Title: "snake_case", Link: "https://example.com/snake_case"},
+	{Title: `C:\path`, Link: `https://example.com/back\slash`},
+	{Title: `"; DROP TABLE news; --`, Link: `https://example.com/"; DROP TABLE news; --`},
+	{Title: "Plain", Link: "https://example.com/plain"},
+}
+
+func TestHostileNews(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			channelID, err := store.AddChannel(ctx, "https://example.com/feed?a='1'&b=--")
+			if err != nil {
+				t.Fatalf("AddChannel: %v", err)
+			}
+			pubDate := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+			for _, news := range hostileNews {
+				news.GUID = news.Link
+				news.PubDate = pubDate
+				added, err := store.AddNewsWithJob(ctx, channelID, news)
+				if err != nil || !added {
+					t.Fatalf("AddNewsWithJob(%q) = %v, %v, want true, nil", news.Link, added, err)
+				}
+				added, err = store.AddNewsWithJob(ctx, channelID, news)
+				if err != nil || added {
+					t.Fatalf("AddNewsWithJob(%q) again = %v, %v, want false, nil", news.Link, added, err)
+				}
+			}
+
+			for _, want := range hostileNews {
+				got, err := store.LoadNewsByLink(ctx, want.Link)
+				if err != nil {
+					t.Fatalf("LoadNewsByLink(%q): %v", want.Link, err)
+				}
+				if got == nil || got.Link != want.Link || got.Title != want.Title {
+					t.Errorf("LoadNewsByLink(%q) = %+v, want title %q", want.Link, got, want.Title)
+				}
+				jobs, err := store.LoadJobs(ctx, JobFilter{Link: want.Link})
+				if err != nil {
+					t.Fatalf("LoadJobs(%q): %v", want.Link, err)
+				}
+				if len(jobs) != 1 || jobs[0].Link != want.Link {
+					t.Errorf("LoadJobs(%q) = %+v, want one job of the link", want.Link, jobs)
+				}
+			}
+
+			all, err := store.LoadNews(ctx, NewsFilter{})
+			if err != nil {
+				t.Fatalf("LoadNews: %v", err)
+			}
+			if len(all) != len(hostileNews) {
+				t.Errorf("LoadNews returned %d items, want %d", len(all), len(hostileNews))
+			}
+		})
+	}
+}
+
+func TestNewsTitleFilter(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		title string
+		want  []string
+	}{
+		{title: "'", want: []string{"It's news"}},
+		{title: "--", want: []string{"Before -- after", `"; DROP TABLE news; --`}},
+		{title: "%", want: []string{"100% sure"}},
+		{title: "_", want: []string{"snake_case"}},
+		{title: `\`, want: []string{`C:\path`}},
+		{title: `"; DROP TABLE`, want: []string{`"; DROP TABLE news; --`}},
+		{title: "drop table", want: []string{`"; DROP TABLE news; --`}},
+		{title: "%_", want: nil},
+		{title: `\%`, want: nil},
+		{title: "' OR '1'='1", want: nil},
+	}
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			channelID, err := store.AddChannel(ctx, "https://example.com/feed")
+			if err != nil {
+				t.Fatalf("AddChannel: %v", err)
+			}
+			for _, news := range hostileNews {
+				news.GUID = news.Link
+				if _, err := store.AddNewsWithJob(ctx, channelID, news); err != nil {
+					t.Fatalf("AddNewsWithJob(%q): %v", news.Link, err)
+				}
+			}
+			for _, tt := range tests {
+				news, err := store.LoadNews(ctx, NewsFilter{Title: tt.title})
+				if err != nil {
+					t.Fatalf("LoadNews(%q): %v", tt.title, err)
+				}
+				var got []string
+				for _, item := range news {
+					got = append(got, item.Title)
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("LoadNews(Title: %q) = %q, want %q", tt.title, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"rss_fetcher/internal/data"
+)
+
+func (pg *Postgres) AddGroup(ctx context.Context, name string) (int, error) {
+	var id int
+	err := pg.pool.QueryRow(ctx, "INSERT INTO channel_groups (name) VALUES ($1) RETURNING group_id", name).Scan(&id)
+	return id, Classify(err)
+}
+
+// groupsQuery lists every group once per member, groups without members once with a NULL channel.
+const groupsQuery = `
+	SELECT g.group_id, g.name, m.channel_id
+	FROM channel_groups g LEFT JOIN channel_group_members m ON m.group_id = g.group_id`
+
+// groupsOrder keeps the rows of a group together.
+const groupsOrder = " ORDER BY g.name, g.group_id, m.channel_id"
+
+func (pg *Postgres) LoadGroups(ctx context.Context) ([]data.ChannelGroup, error) {
+	return pg.loadGroups(ctx, &where{param: "$"})
+}
+
+func (pg *Postgres) LoadGroup(ctx context.Context, id int) (*data.ChannelGroup, error) {
+	where := &where{param: "$"}
+	where.equal("g.group_id", id)
+	groups, err := pg.loadGroups(ctx, where)
+	if err != nil || len(groups) == 0 {
+		return nil, err
+	}
+	return &groups[0], nil
+}
+
+func (pg *Postgres) loadGroups(ctx context.Context, where *where) ([]data.ChannelGroup, error) {
+	rows, err := pg.pool.Query(ctx, groupsQuery+where.String()+groupsOrder, where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make([]data.ChannelGroup, 0)
+	for rows.Next() {
+		var group data.ChannelGroup
+		var channelID *int
+		if err := rows.Scan(&group.ID, &group.Name, &channelID); err != nil {
+			return nil, err
+		}
+		groups = appendGroupMember(groups, group, channelID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+func (pg *Postgres) RenameGroup(ctx context.Context, id int, name string) error {
+	tag, err := pg.pool.Exec(ctx, "UPDATE channel_groups SET name = $2 WHERE group_id = $1", id, name)
+	return rowsAffected(tag, err, "group", id)
+}
+
+func (pg *Postgres) DeleteGroup(ctx context.Context, id int) error {
+	tag, err := pg.pool.Exec(ctx, "DELETE FROM channel_groups WHERE group_id = $1", id)
+	return rowsAffected(tag, err, "group", id)
+}
+
+func (pg *Postgres) AddGroupChannel(ctx context.Context, groupID, channelID int) error {
+	_, err := pg.pool.Exec(ctx, `
+		INSERT INTO channel_group_members (group_id, channel_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, groupID, channelID)
+	return Classify(err)
+}
+
+func (pg *Postgres) DeleteGroupChannel(ctx context.Context, groupID, channelID int) error {
+	tag, err := pg.pool.Exec(ctx, "DELETE FROM channel_group_members WHERE group_id = $1 AND channel_id = $2", groupID, channelID)
+	return rowsAffected(tag, err, "member", channelID)
+}
+
+// optionalID stores an unset reference, 0, as NULL.
+func optionalID(id int) *int {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// appendGroupMember adds a row of groupsQuery to the groups, rows of a group are consecutive.
+func appendGroupMember(groups []data.ChannelGroup, group data.ChannelGroup, channelID *int) []data.ChannelGroup {
+	if len(groups) == 0 || groups[len(groups)-1].ID != group.ID {
+		group.ChannelIDs = make([]int, 0)
+		groups = append(groups, group)
+	}
+	if channelID != nil {
+		last := &groups[len(groups)-1]
+		last.ChannelIDs = append(last.ChannelIDs, *channelID)
+	}
+	return groups
+}
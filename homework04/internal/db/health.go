@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"rss_fetcher/internal/data"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// fetchLogSize is the number of fetches kept per channel, older ones are pruned.
+const fetchLogSize = 100
+
+const fetchColumns = "fetch_id, channel_id, started_at, duration_ms, status_code, bytes, items, new_items, error"
+
+func scanFetch(row pgx.Row) (data.ChannelFetch, error) {
+	var fetch data.ChannelFetch
+	var duration int64
+	err := row.Scan(&fetch.ID, &fetch.ChannelID, &fetch.StartedAt, &duration, &fetch.StatusCode, &fetch.Bytes,
+		&fetch.Items, &fetch.NewItems, &fetch.Error)
+	fetch.Duration = time.Duration(duration) * time.Millisecond
+	return fetch, err
+}
+
+func (pg *Postgres) RecordChannelFetch(ctx context.Context, fetch data.ChannelFetch, maxFailures int) (bool, error) {
+	pausedNow := false
+	err := pg.inTx(ctx, func(tx pgx.Tx) error {
+		var wasPaused bool
+		err := tx.QueryRow(ctx, "SELECT paused FROM channels WHERE channel_id = $1 FOR UPDATE", fetch.ChannelID).Scan(&wasPaused)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// the channel was deleted while its feed was fetched
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO channel_fetches (channel_id, started_at, duration_ms, status_code, bytes, items, new_items, error)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			fetch.ChannelID, fetch.StartedAt.UTC(), fetch.Duration.Milliseconds(), fetch.StatusCode, fetch.Bytes,
+			fetch.Items, fetch.NewItems, fetch.Error); err != nil {
+			return err
+		}
+
+		var paused bool
+		if err := tx.QueryRow(ctx, `
+			UPDATE channels SET
+				consecutive_failures = CASE WHEN $2::boolean THEN 0 ELSE consecutive_failures + 1 END,
+				last_success_at = CASE WHEN $2::boolean THEN $3 ELSE last_success_at END,
+				paused = paused OR (NOT $2::boolean AND $4::integer > 0 AND consecutive_failures + 1 >= $4::integer)
+			WHERE channel_id = $1
+			RETURNING paused`,
+			fetch.ChannelID, fetch.Error == "", fetch.StartedAt.UTC(), maxFailures).Scan(&paused); err != nil {
+			return err
+		}
+		pausedNow = paused && !wasPaused
+
+		_, err = tx.Exec(ctx, `
+			DELETE FROM channel_fetches WHERE channel_id = $1 AND fetch_id <= (
+				SELECT fetch_id FROM channel_fetches WHERE channel_id = $1 ORDER BY fetch_id DESC LIMIT 1 OFFSET $2)`,
+			fetch.ChannelID, fetchLogSize)
+		return err
+	})
+	return pausedNow, err
+}
+
+func (pg *Postgres) LoadChannelFetches(ctx context.Context, channelID, limit int) ([]data.ChannelFetch, error) {
+	rows, err := pg.pool.Query(ctx, "SELECT "+fetchColumns+" FROM channel_fetches WHERE channel_id = $1 ORDER BY fetch_id DESC LIMIT $2",
+		channelID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fetches := make([]data.ChannelFetch, 0)
+	for rows.Next() {
+		fetch, err := scanFetch(rows)
+		if err != nil {
+			return nil, err
+		}
+		fetches = append(fetches, fetch)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return fetches, nil
+}
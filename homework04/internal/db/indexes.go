@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rss_fetcher/internal/data"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// IndexStats describes the vector index of an embedding profile.
+type IndexStats struct {
+	Profile          data.EmbeddingProfile
+	IndexName        string
+	Exists           bool
+	Valid            bool  // false while a concurrent build is in progress or after it failed
+	SizeBytes        int64 // on-disk size of the index
+	Rows             int64 // vectors stored in the profile
+	Scans            int64 // index scans since statistics were reset
+	RecommendedLists int   // ivfflat lists recommended for the current number of rows
+}
+
+// NeedsRebuild reports whether the index is missing, invalid or, for ivfflat,
+// was built for a corpus of a very different size.
+func (s IndexStats) NeedsRebuild() bool {
+	if !s.Exists || !s.Valid {
+		return true
+	}
+	if s.Profile.Index.Type != data.IndexIVFFlat {
+		return false
+	}
+	lists := s.Profile.Index.Lists
+	return lists > 2*s.RecommendedLists || 2*lists < s.RecommendedLists
+}
+
+// RecommendedLists follows the pgvector guideline: rows / 1000 up to 1M rows, sqrt(rows) above.
+func RecommendedLists(rows int64) int {
+	var lists int
+	if rows <= 1_000_000 {
+		lists = int(rows / 1000)
+	} else {
+		lists = isqrt(rows)
+	}
+	return max(lists, 10)
+}
+
+func isqrt(n int64) int {
+	r := int64(0)
+	for (r+1)*(r+1) <= n {
+		r++
+	}
+	return int(r)
+}
+
+// profileIndexDefinition returns the CREATE INDEX statement of the profile index with the given name.
+func profileIndexDefinition(profile data.EmbeddingProfile, name string, concurrently bool) (string, error) {
+	var method, options string
+	switch profile.Index.Type {
+	case data.IndexIVFFlat:
+		method, options = "ivfflat", fmt.Sprintf("lists = %d", profile.Index.Lists)
+	case data.IndexHNSW:
+		method, options = "hnsw", fmt.Sprintf("m = %d, ef_construction = %d", profile.Index.M, profile.Index.EfConstruction)
+	default:
+		return "", fmt.Errorf("unsupported index type: %s", profile.Index.Type)
+	}
+
+	create := "CREATE INDEX IF NOT EXISTS"
+	if concurrently {
+		create = "CREATE INDEX CONCURRENTLY IF NOT EXISTS"
+	}
+	return fmt.Sprintf(`%s %s ON news_embeddings
+		USING %s ((%s) vector_cosine_ops)
+		WITH (%s)
+		WHERE profile_id = %d`, create, pgx.Identifier{name}.Sanitize(), method, profileVector(profile), options, profile.ID), nil
+}
+
+// applySearchSettings sets the search parameters of the profile index for the rest of the transaction.
+// The filter of a scoped search applies to the candidates the index returns, which are
+// only ef_search or the rows of the probed lists, so a small group or a user with few
+// subscriptions would get nothing back. Filtered searches let the index scan on until
+// enough rows pass (iterative scans of pgvector 0.8).
+func applySearchSettings(ctx context.Context, tx pgx.Tx, profile data.EmbeddingProfile, filtered bool) error {
+	var queries []string
+	switch profile.Index.Type {
+	case data.IndexIVFFlat:
+		queries = append(queries, fmt.Sprintf("SET LOCAL ivfflat.probes = %d", profile.Index.Probes))
+		if filtered {
+			// ivfflat only scans in relaxed order, the results are sorted again afterwards
+			queries = append(queries, "SET LOCAL ivfflat.iterative_scan = relaxed_order")
+		}
+	case data.IndexHNSW:
+		queries = append(queries, fmt.Sprintf("SET LOCAL hnsw.ef_search = %d", profile.Index.EfSearch))
+		if filtered {
+			queries = append(queries, "SET LOCAL hnsw.iterative_scan = strict_order")
+		}
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadIndexStats reports the state of the vector index of every profile.
+func (pg *Postgres) LoadIndexStats(ctx context.Context) ([]IndexStats, error) {
+	profiles, err := pg.LoadProfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]IndexStats, 0, len(profiles))
+	for _, profile := range profiles {
+		stats := IndexStats{Profile: profile, IndexName: profileIndexName(profile)}
+
+		if stats.Rows, err = pg.CountVectors(ctx, profile); err != nil {
+			return nil, err
+		}
+		stats.RecommendedLists = RecommendedLists(stats.Rows)
+
+		err := pg.pool.QueryRow(ctx, `
+			SELECT i.indisvalid, pg_relation_size(i.indexrelid), COALESCE(s.idx_scan, 0)
+			FROM pg_class c
+			JOIN pg_index i ON i.indexrelid = c.oid
+			LEFT JOIN pg_stat_user_indexes s ON s.indexrelid = c.oid
+			WHERE c.relname = $1`, stats.IndexName).Scan(&stats.Valid, &stats.SizeBytes, &stats.Scans)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+		case err != nil:
+			return nil, err
+		default:
+			stats.Exists = true
+		}
+		result = append(result, stats)
+	}
+	return result, nil
+}
+
+// CountVectors returns the number of vectors stored in the profile.
+func (pg *Postgres) CountVectors(ctx context.Context, profile data.EmbeddingProfile) (int64, error) {
+	var rows int64
+	err := pg.pool.QueryRow(ctx, "SELECT COUNT(*) FROM news_embeddings WHERE profile_id = $1", profile.ID).Scan(&rows)
+	return rows, err
+}
+
+// RebuildProfileIndex builds the index of the profile with new settings without blocking
+// writes: the new index is built concurrently under a temporary name and then swapped
+// with the old one. The settings are saved in the profile together with the swap.
+func (pg *Postgres) RebuildProfileIndex(ctx context.Context, profile data.EmbeddingProfile, settings data.IndexSettings) error {
+	profile.Index = settings
+	name := profileIndexName(profile)
+	tmpName := name + "_new"
+
+	// leftover of an interrupted rebuild
+	if _, err := pg.pool.Exec(ctx, "DROP INDEX CONCURRENTLY IF EXISTS "+pgx.Identifier{tmpName}.Sanitize()); err != nil {
+		return err
+	}
+	query, err := profileIndexDefinition(profile, tmpName, true)
+	if err != nil {
+		return err
+	}
+	if _, err := pg.pool.Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to build index for profile %s: %w", profile.Name, err)
+	}
+
+	tx, err := pg.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DROP INDEX IF EXISTS "+pgx.Identifier{name}.Sanitize()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf("ALTER INDEX %s RENAME TO %s", pgx.Identifier{tmpName}.Sanitize(), pgx.Identifier{name}.Sanitize())); err != nil {
+		return err
+	}
+	if err := updateIndexSettings(ctx, tx, profile.ID, settings); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit error: %w", err)
+	}
+	return nil
+}
+
+// UpdateSearchSettings changes the query-time parameters of a profile index, no rebuild is needed.
+func (pg *Postgres) UpdateSearchSettings(ctx context.Context, profile data.EmbeddingProfile, probes, efSearch int) error {
+	_, err := pg.pool.Exec(ctx, "UPDATE embedding_profiles SET ivfflat_probes = $2, hnsw_ef_search = $3 WHERE profile_id = $1",
+		profile.ID, probes, efSearch)
+	return err
+}
+
+func updateIndexSettings(ctx context.Context, tx pgx.Tx, profileID int, settings data.IndexSettings) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE embedding_profiles
+		SET index_type = $2, ivfflat_lists = $3, ivfflat_probes = $4, hnsw_m = $5, hnsw_ef_construction = $6, hnsw_ef_search = $7
+		WHERE profile_id = $1`,
+		profileID, string(settings.Type), settings.Lists, settings.Probes, settings.M, settings.EfConstruction, settings.EfSearch)
+	return err
+}
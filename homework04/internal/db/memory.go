@@ -0,0 +1,1287 @@
+package db
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"rss_fetcher/internal/data"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Memory is a Store keeping everything in process memory, for unit tests and offline
+// evaluations. It follows the semantics of Postgres, including cascading deletes and
+// unique constraints, and searches vectors exhaustively.
+type Memory struct {
+	mu     sync.Mutex
+	lastID int
+	now    func() time.Time
+
+	channels  map[int]data.Channel
+	groups    map[int]data.ChannelGroup
+	fetches   []data.ChannelFetch
+	news      map[int]data.ChannelNews
+	contents  map[int]data.NewsContent
+	summaries map[memorySummaryKey]string
+	jobs      map[int]data.NewsJob
+	profiles  map[int]data.EmbeddingProfile
+	vectors   []memoryVector
+	searches  map[int]memorySearch
+	alerts    map[int]memoryAlert
+	keys      map[int]data.APIKey
+	users     map[int]data.User
+	states    map[memoryStateKey]data.NewsState
+}
+
+type memoryVector struct {
+	profileID int
+	newsID    int
+	metadata  map[string]interface{}
+	embedding []float32
+}
+
+type memorySummaryKey struct {
+	newsID int
+	model  string
+}
+
+// memoryStateKey is the primary key of news_states.
+type memoryStateKey struct {
+	userID int
+	newsID int
+}
+
+type memorySearch struct {
+	data.SavedSearch
+	profileID int
+	embedding []float32
+}
+
+type memoryAlert struct {
+	data.SearchAlert
+	searchID      int
+	newsID        int
+	nextAttemptAt time.Time
+	lockedUntil   time.Time
+}
+
+var _ Store = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	return &Memory{
+		now:       time.Now,
+		channels:  make(map[int]data.Channel),
+		groups:    make(map[int]data.ChannelGroup),
+		news:      make(map[int]data.ChannelNews),
+		contents:  make(map[int]data.NewsContent),
+		summaries: make(map[memorySummaryKey]string),
+		jobs:      make(map[int]data.NewsJob),
+		profiles:  make(map[int]data.EmbeddingProfile),
+		searches:  make(map[int]memorySearch),
+		alerts:    make(map[int]memoryAlert),
+		keys:      make(map[int]data.APIKey),
+		users:     make(map[int]data.User),
+		states:    make(map[memoryStateKey]data.NewsState),
+	}
+}
+
+func (m *Memory) Close() {}
+
+func (m *Memory) nextID() int {
+	m.lastID++
+	return m.lastID
+}
+
+// sortedValues returns the values of the map ordered by key.
+func sortedValues[V any](items map[int]V) []V {
+	keys := make([]int, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	values := make([]V, len(keys))
+	for i, key := range keys {
+		values[i] = items[key]
+	}
+	return values
+}
+
+func (m *Memory) AddChannel(_ context.Context, link string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, channel := range m.channels {
+		if channel.Link == link {
+			return 0, uniqueViolation("channels_link_key")
+		}
+	}
+	id := m.nextID()
+	m.channels[id] = data.Channel{ID: id, Link: link, UpdatedAt: time.Unix(0, 0).UTC(), FullArticles: true}
+	return id, nil
+}
+
+func (m *Memory) UpdateChannel(_ context.Context, channel data.Channel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.channels[channel.ID]
+	if !ok {
+		return &NotFoundError{Entity: "channel", ID: channel.ID}
+	}
+	for _, other := range m.channels {
+		if other.ID != channel.ID && other.Link == channel.Link {
+			return uniqueViolation("channels_link_key")
+		}
+	}
+	if stored.Paused && !channel.Paused {
+		stored.Failures = 0
+	}
+	stored.Link = channel.Link
+	stored.Name = channel.Name
+	stored.Paused = channel.Paused
+	stored.PollInterval = channel.PollInterval.Truncate(time.Second)
+	stored.FullArticles = channel.FullArticles
+	stored.Headers = maps.Clone(channel.Headers)
+	m.channels[channel.ID] = stored
+	return nil
+}
+
+func (m *Memory) MarkChannelChecked(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if channel, ok := m.channels[id]; ok {
+		channel.UpdatedAt = m.now()
+		m.channels[id] = channel
+	}
+	return nil
+}
+
+func (m *Memory) RecordChannelFetch(_ context.Context, fetch data.ChannelFetch, maxFailures int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	channel, ok := m.channels[fetch.ChannelID]
+	if !ok {
+		return false, nil
+	}
+	fetch.ID = m.nextID()
+	fetch.StartedAt = fetch.StartedAt.UTC()
+	fetch.Duration = fetch.Duration.Truncate(time.Millisecond)
+	m.fetches = append(m.fetches, fetch)
+
+	wasPaused := channel.Paused
+	if fetch.Error == "" {
+		channel.Failures = 0
+		channel.LastSuccess = fetch.StartedAt
+	} else {
+		channel.Failures++
+		channel.Paused = channel.Paused || maxFailures > 0 && channel.Failures >= maxFailures
+	}
+	m.channels[fetch.ChannelID] = channel
+
+	kept := 0
+	for i := len(m.fetches) - 1; i >= 0; i-- {
+		if m.fetches[i].ChannelID != fetch.ChannelID {
+			continue
+		}
+		if kept++; kept > fetchLogSize {
+			m.fetches = slices.Delete(m.fetches, i, i+1)
+		}
+	}
+	return channel.Paused && !wasPaused, nil
+}
+
+func (m *Memory) LoadChannelFetches(_ context.Context, channelID, limit int) ([]data.ChannelFetch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fetches := make([]data.ChannelFetch, 0)
+	for i := len(m.fetches) - 1; i >= 0 && len(fetches) < limit; i-- {
+		if m.fetches[i].ChannelID == channelID {
+			fetches = append(fetches, m.fetches[i])
+		}
+	}
+	return fetches, nil
+}
+
+func (m *Memory) DeleteChannel(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.channels[id]; !ok {
+		return &NotFoundError{Entity: "channel", ID: id}
+	}
+	m.deleteChannel(id)
+	return nil
+}
+
+func (m *Memory) DeleteChannels(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id := range m.channels {
+		m.deleteChannel(id)
+	}
+	return nil
+}
+
+func (m *Memory) deleteChannel(id int) {
+	delete(m.channels, id)
+	m.fetches = slices.DeleteFunc(m.fetches, func(fetch data.ChannelFetch) bool { return fetch.ChannelID == id })
+	for groupID, group := range m.groups {
+		group.ChannelIDs = slices.DeleteFunc(group.ChannelIDs, func(channelID int) bool { return channelID == id })
+		m.groups[groupID] = group
+	}
+	for userID, user := range m.users {
+		user.ChannelIDs = slices.DeleteFunc(user.ChannelIDs, func(channelID int) bool { return channelID == id })
+		m.users[userID] = user
+	}
+	for newsID, news := range m.news {
+		if news.ChannelID == id {
+			m.deleteNews(newsID)
+		}
+	}
+}
+
+func (m *Memory) LoadChannels(_ context.Context, filter ChannelFilter) ([]data.Channel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	channels := make([]data.Channel, 0)
+	for _, channel := range sortedValues(m.channels) {
+		if filter.matches(channel) && (filter.UserID == 0 || slices.Contains(m.users[filter.UserID].ChannelIDs, channel.ID)) {
+			channels = append(channels, channel)
+		}
+	}
+	return limited(channels, filter.Limit), nil
+}
+
+func (m *Memory) AddGroup(_ context.Context, name string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, group := range m.groups {
+		if group.Name == name {
+			return 0, uniqueViolation("channel_groups_name_key")
+		}
+	}
+	id := m.nextID()
+	m.groups[id] = data.ChannelGroup{ID: id, Name: name, ChannelIDs: make([]int, 0)}
+	return id, nil
+}
+
+func (m *Memory) LoadGroups(_ context.Context) ([]data.ChannelGroup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	groups := make([]data.ChannelGroup, 0, len(m.groups))
+	for _, group := range m.groups {
+		group.ChannelIDs = slices.Clone(group.ChannelIDs)
+		slices.Sort(group.ChannelIDs)
+		groups = append(groups, group)
+	}
+	slices.SortFunc(groups, func(a, b data.ChannelGroup) int { return cmp.Compare(a.Name, b.Name) })
+	return groups, nil
+}
+
+func (m *Memory) LoadGroup(_ context.Context, id int) (*data.ChannelGroup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	group, ok := m.groups[id]
+	if !ok {
+		return nil, nil
+	}
+	group.ChannelIDs = slices.Clone(group.ChannelIDs)
+	slices.Sort(group.ChannelIDs)
+	return &group, nil
+}
+
+func (m *Memory) RenameGroup(_ context.Context, id int, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	group, ok := m.groups[id]
+	if !ok {
+		return &NotFoundError{Entity: "group", ID: id}
+	}
+	for _, other := range m.groups {
+		if other.ID != id && other.Name == name {
+			return uniqueViolation("channel_groups_name_key")
+		}
+	}
+	group.Name = name
+	m.groups[id] = group
+	return nil
+}
+
+func (m *Memory) DeleteGroup(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.groups[id]; !ok {
+		return &NotFoundError{Entity: "group", ID: id}
+	}
+	delete(m.groups, id)
+	for searchID, search := range m.searches {
+		if search.GroupID == id {
+			m.deleteSavedSearch(searchID)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) AddGroupChannel(_ context.Context, groupID, channelID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	group, ok := m.groups[groupID]
+	if !ok {
+		return missingReference("channel_group_members_group_id_fkey")
+	}
+	if _, ok := m.channels[channelID]; !ok {
+		return missingReference("channel_group_members_channel_id_fkey")
+	}
+	if !slices.Contains(group.ChannelIDs, channelID) {
+		group.ChannelIDs = append(group.ChannelIDs, channelID)
+		m.groups[groupID] = group
+	}
+	return nil
+}
+
+func (m *Memory) DeleteGroupChannel(_ context.Context, groupID, channelID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	group, ok := m.groups[groupID]
+	if !ok || !slices.Contains(group.ChannelIDs, channelID) {
+		return &NotFoundError{Entity: "member", ID: channelID}
+	}
+	group.ChannelIDs = slices.DeleteFunc(group.ChannelIDs, func(id int) bool { return id == channelID })
+	m.groups[groupID] = group
+	return nil
+}
+
+func (m *Memory) LoadChannel(_ context.Context, id int) (*data.Channel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	channel, ok := m.channels[id]
+	if !ok {
+		return nil, nil
+	}
+	channel.Items = m.loadNews(NewsFilter{ChannelID: id})
+	return &channel, nil
+}
+
+func (m *Memory) AddNewsWithJob(_ context.Context, channelID int, news data.ChannelNews) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.channels[channelID]; !ok {
+		return false, fmt.Errorf("channel %d not found", channelID)
+	}
+	for _, existing := range m.news {
+		if existing.Link == news.Link {
+			return false, nil
+		}
+		if existing.GUID == news.GUID {
+			return false, uniqueViolation("channel_news_guid_key")
+		}
+	}
+	news.ID = m.nextID()
+	news.ChannelID = channelID
+	m.news[news.ID] = news
+	m.addJob(news.Link)
+	return true, nil
+}
+
+func (m *Memory) DeleteNews(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.news[id]; !ok {
+		return &NotFoundError{Entity: "news", ID: id}
+	}
+	m.deleteNews(id)
+	return nil
+}
+
+func (m *Memory) deleteNews(id int) {
+	delete(m.news, id)
+	delete(m.contents, id)
+	for key := range m.summaries {
+		if key.newsID == id {
+			delete(m.summaries, key)
+		}
+	}
+	for key := range m.states {
+		if key.newsID == id {
+			delete(m.states, key)
+		}
+	}
+	m.vectors = slices.DeleteFunc(m.vectors, func(v memoryVector) bool { return v.newsID == id })
+	for alertID, alert := range m.alerts {
+		if alert.newsID == id {
+			delete(m.alerts, alertID)
+		}
+	}
+}
+
+func (m *Memory) LoadNews(_ context.Context, filter NewsFilter) ([]data.ChannelNews, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.loadNews(filter), nil
+}
+
+func (m *Memory) loadNews(filter NewsFilter) []data.ChannelNews {
+	result := make([]data.ChannelNews, 0)
+	for _, news := range sortedValues(m.news) {
+		if filter.matches(news) && m.inScope(filter, news) {
+			if filter.UserID != 0 {
+				state := m.states[memoryStateKey{userID: filter.UserID, newsID: news.ID}]
+				news.State = &state
+			}
+			result = append(result, news)
+		}
+	}
+	slices.SortStableFunc(result, func(a, b data.ChannelNews) int {
+		return filter.compare(a, NewsCursor{PubDate: b.PubDate, ID: b.ID})
+	})
+	return limited(result, filter.Limit)
+}
+
+// inScope checks the conditions of the filter on other tables: groups, subscriptions,
+// news states and alerts.
+func (m *Memory) inScope(filter NewsFilter, news data.ChannelNews) bool {
+	if filter.GroupID != 0 && !slices.Contains(m.groups[filter.GroupID].ChannelIDs, news.ChannelID) {
+		return false
+	}
+	if filter.UserID != 0 {
+		if !slices.Contains(m.users[filter.UserID].ChannelIDs, news.ChannelID) {
+			return false
+		}
+		state := m.states[memoryStateKey{userID: filter.UserID, newsID: news.ID}]
+		if filter.Unread && state.Read || filter.Starred && !state.Starred {
+			return false
+		}
+	}
+	if filter.SearchID != 0 {
+		for _, alert := range m.alerts {
+			if alert.searchID == filter.SearchID && alert.newsID == news.ID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (m *Memory) LoadNewsByID(_ context.Context, id int) (*data.ChannelNews, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	news := m.loadNews(NewsFilter{ID: id})
+	if len(news) == 0 {
+		return nil, nil
+	}
+	return &news[0], nil
+}
+
+func (m *Memory) LoadNewsByLink(_ context.Context, link string) (*data.ChannelNews, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	news := m.loadNews(NewsFilter{Link: link})
+	if len(news) == 0 {
+		return nil, nil
+	}
+	return &news[0], nil
+}
+
+func (m *Memory) SaveNewsContent(_ context.Context, content data.NewsContent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.news[content.NewsID]; !ok {
+		return fmt.Errorf("news %d not found", content.NewsID)
+	}
+	content.FetchedAt = m.now()
+	m.contents[content.NewsID] = content
+	return nil
+}
+
+func (m *Memory) LoadNewsContent(_ context.Context, newsID int) (*data.NewsContent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	content, ok := m.contents[newsID]
+	if !ok {
+		return nil, nil
+	}
+	return &content, nil
+}
+
+func (m *Memory) SaveNewsSummary(_ context.Context, newsID int, model, summary string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.news[newsID]; !ok {
+		return fmt.Errorf("news %d not found", newsID)
+	}
+	m.summaries[memorySummaryKey{newsID: newsID, model: model}] = summary
+	return nil
+}
+
+func (m *Memory) LoadNewsSummaries(_ context.Context, model string, newsIDs []int) (map[int]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	summaries := make(map[int]string)
+	for _, id := range newsIDs {
+		if summary, ok := m.summaries[memorySummaryKey{newsID: id, model: model}]; ok {
+			summaries[id] = summary
+		}
+	}
+	return summaries, nil
+}
+
+func (m *Memory) LoadChunkContext(_ context.Context, newsID int) (data.ChunkContext, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	news, ok := m.news[newsID]
+	if !ok {
+		return data.ChunkContext{}, fmt.Errorf("news %d not found", newsID)
+	}
+	return data.ChunkContext{Title: news.Title, Channel: m.channels[news.ChannelID].Title, PubDate: news.PubDate}, nil
+}
+
+func (m *Memory) AddJob(_ context.Context, link string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.addJob(link)
+	return nil
+}
+
+func (m *Memory) addJob(link string) {
+	now := m.now()
+	id := m.nextID()
+	m.jobs[id] = data.NewsJob{ID: id, Link: link, Status: data.JobPending, NextRunAt: now, CreatedAt: now, UpdatedAt: time.Unix(0, 0).UTC()}
+}
+
+func (m *Memory) DeleteJob(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.jobs[id]; !ok {
+		return &NotFoundError{Entity: "job", ID: id}
+	}
+	delete(m.jobs, id)
+	return nil
+}
+
+func (m *Memory) LoadJobs(_ context.Context, filter JobFilter) ([]data.NewsJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]data.NewsJob, 0)
+	for _, job := range sortedValues(m.jobs) {
+		if filter.matches(job) {
+			jobs = append(jobs, job)
+		}
+	}
+	return limited(jobs, filter.Limit), nil
+}
+
+func (m *Memory) ClaimJob(_ context.Context, lease time.Duration, maxAttempts int) (*data.NewsJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	var next *data.NewsJob
+	for _, job := range sortedValues(m.jobs) {
+		runnable := (job.Status == data.JobPending || job.Status == data.JobFailed) && !job.NextRunAt.After(now) ||
+			job.Status == data.JobRunning && job.LockedUntil.Before(now) && job.Attempts < maxAttempts
+		if runnable && (next == nil || job.NextRunAt.Before(next.NextRunAt)) {
+			next = &job
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+	next.Status = data.JobRunning
+	next.Attempts++
+	next.LockedUntil = now.Add(lease)
+	next.UpdatedAt = now
+	m.jobs[next.ID] = *next
+	return next, nil
+}
+
+func (m *Memory) RenewJob(_ context.Context, id int, lease time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok || job.Status != data.JobRunning {
+		return &NotFoundError{Entity: "job", ID: id}
+	}
+	job.LockedUntil, job.UpdatedAt = m.now().Add(lease), m.now()
+	m.jobs[id] = job
+	return nil
+}
+
+func (m *Memory) CompleteJob(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if job, ok := m.jobs[id]; ok {
+		job.Status, job.LastError, job.LockedUntil, job.UpdatedAt = data.JobCompleted, "", time.Time{}, m.now()
+		m.jobs[id] = job
+	}
+	return nil
+}
+
+func (m *Memory) FailJob(_ context.Context, id int, reason string, maxAttempts int, backoff time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil
+	}
+	job.Status = data.JobFailed
+	if job.Attempts >= maxAttempts {
+		job.Status = data.JobDead
+	}
+	now := m.now()
+	job.LastError = reason
+	job.NextRunAt = now.Add(backoff << max(job.Attempts-1, 0))
+	job.LockedUntil = time.Time{}
+	job.UpdatedAt = now
+	m.jobs[id] = job
+	return nil
+}
+
+func (m *Memory) ReapJobs(_ context.Context, maxAttempts int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	var reaped int64
+	for id, job := range m.jobs {
+		if job.Status == data.JobRunning && job.LockedUntil.Before(now) && job.Attempts >= maxAttempts {
+			job.Status, job.LastError, job.LockedUntil, job.UpdatedAt = data.JobDead, "lease expired", time.Time{}, now
+			m.jobs[id] = job
+			reaped++
+		}
+	}
+	return reaped, nil
+}
+
+func (m *Memory) AddProfile(_ context.Context, profile data.EmbeddingProfile) (*data.EmbeddingProfile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.profiles {
+		if existing.Name == profile.Name {
+			return nil, uniqueViolation("embedding_profiles_name_key")
+		}
+	}
+	profile.ID = m.nextID()
+	profile.CreatedAt = m.now()
+	if profile.Status == data.ProfileActive {
+		profile.ActivatedAt = profile.CreatedAt
+	}
+	m.profiles[profile.ID] = profile
+	return &profile, nil
+}
+
+func (m *Memory) LoadProfiles(_ context.Context, statuses ...data.ProfileStatus) ([]data.EmbeddingProfile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	profiles := make([]data.EmbeddingProfile, 0)
+	for _, profile := range sortedValues(m.profiles) {
+		if len(statuses) == 0 || slices.Contains(statuses, profile.Status) {
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles, nil
+}
+
+func (m *Memory) LoadActiveProfile(_ context.Context) (*data.EmbeddingProfile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, profile := range m.profiles {
+		if profile.Status == data.ProfileActive {
+			return &profile, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *Memory) LoadProfileByName(_ context.Context, name string) (*data.EmbeddingProfile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, profile := range m.profiles {
+		if profile.Name == name {
+			return &profile, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *Memory) PruneRetiredProfiles(_ context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var pruned int64
+	for id, profile := range m.profiles {
+		if profile.Status != data.ProfileRetired {
+			continue
+		}
+		delete(m.profiles, id)
+		m.vectors = slices.DeleteFunc(m.vectors, func(v memoryVector) bool { return v.profileID == id })
+		pruned++
+	}
+	return pruned, nil
+}
+
+func (m *Memory) ActivateProfile(_ context.Context, profile data.EmbeddingProfile, searchEmbeddings map[int][]float32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	target, ok := m.profiles[profile.ID]
+	if !ok {
+		return fmt.Errorf("profile %d not found", profile.ID)
+	}
+	for id, search := range m.searches {
+		embedding, ok := searchEmbeddings[id]
+		if !ok && search.profileID != profile.ID {
+			return fmt.Errorf("saved searches were added during reindexing, run it again")
+		}
+		if ok {
+			search.embedding, search.profileID = embedding, profile.ID
+			m.searches[id] = search
+		}
+	}
+	for id, existing := range m.profiles {
+		if existing.Status == data.ProfileActive {
+			existing.Status = data.ProfileRetired
+			m.profiles[id] = existing
+		}
+	}
+	target.Status, target.ActivatedAt = data.ProfileActive, m.now()
+	m.profiles[target.ID] = target
+	return nil
+}
+
+func (m *Memory) SaveEmbeddings(_ context.Context, profile data.EmbeddingProfile, docID int, embedding []float32, metadata map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.saveEmbeddings(profile, docID, embedding, metadata)
+}
+
+func (m *Memory) saveEmbeddings(profile data.EmbeddingProfile, docID int, embedding []float32, metadata map[string]interface{}) error {
+	if len(embedding) != profile.Dims {
+		return fmt.Errorf("embedding length %d does not match profile %s dimension %d", len(embedding), profile.Name, profile.Dims)
+	}
+	m.vectors = append(m.vectors, memoryVector{profileID: profile.ID, newsID: docID, metadata: metadata, embedding: embedding})
+	return nil
+}
+
+func (m *Memory) InsertDocument(ctx context.Context, profile data.EmbeddingProfile, docID int, content string, embedding []float32) error {
+	return m.SaveEmbeddings(ctx, profile, docID, embedding, map[string]interface{}{"content": content})
+}
+
+func (m *Memory) IndexArticle(_ context.Context, profile data.EmbeddingProfile, newsID int, chunks []string, embeddings [][]float32) (int64, error) {
+	if len(chunks) != len(embeddings) {
+		return 0, fmt.Errorf("got %d embeddings for %d chunks", len(embeddings), len(chunks))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, embedding := range embeddings {
+		if len(embedding) != profile.Dims {
+			return 0, fmt.Errorf("embedding length %d does not match profile %s dimension %d", len(embedding), profile.Name, profile.Dims)
+		}
+	}
+	m.vectors = slices.DeleteFunc(m.vectors, func(v memoryVector) bool { return v.profileID == profile.ID && v.newsID == newsID })
+
+	var alerts int64
+	for i, chunk := range chunks {
+		m.saveEmbeddings(profile, newsID, embeddings[i], map[string]interface{}{"content": chunk})
+		if profile.Status == data.ProfileActive {
+			alerts += m.matchSavedSearches(profile, newsID, embeddings[i], chunk)
+		}
+	}
+	return alerts, nil
+}
+
+// matchSavedSearches records an alert for every search of the profile the chunk is similar enough to,
+// at most one per search and article.
+func (m *Memory) matchSavedSearches(profile data.EmbeddingProfile, newsID int, embedding []float32, chunk string) int64 {
+	var matched int64
+	for _, search := range sortedValues(m.searches) {
+		similarity := Cosine(search.embedding, embedding)
+		if search.profileID != profile.ID || similarity < float64(search.Threshold) ||
+			!m.inScope(NewsFilter{GroupID: search.GroupID}, m.news[newsID]) {
+			continue
+		}
+		duplicate := false
+		for _, alert := range m.alerts {
+			duplicate = duplicate || alert.searchID == search.ID && alert.newsID == newsID
+		}
+		if duplicate {
+			continue
+		}
+		id := m.nextID()
+		m.alerts[id] = memoryAlert{
+			SearchAlert:   data.SearchAlert{ID: id, Similarity: float32(similarity), Chunk: chunk, CreatedAt: m.now()},
+			searchID:      search.ID,
+			newsID:        newsID,
+			nextAttemptAt: m.now(),
+		}
+		matched++
+	}
+	return matched
+}
+
+func (m *Memory) QueryRelevantDocuments(_ context.Context, profile data.EmbeddingProfile, embedding []float32, backend string, limit int, scope DocumentScope) ([]Document, error) {
+	if backend != "ollama" {
+		return nil, fmt.Errorf("unsupported backend: %s", backend)
+	}
+	if len(embedding) != profile.Dims {
+		return nil, fmt.Errorf("query embedding length %d does not match profile %s dimension %d", len(embedding), profile.Name, profile.Dims)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	docs := make([]Document, 0)
+	for _, vector := range m.vectors {
+		if vector.profileID != profile.ID || !m.inScope(NewsFilter{GroupID: scope.GroupID, UserID: scope.UserID}, m.news[vector.newsID]) {
+			continue
+		}
+		docs = append(docs, Document{
+			ID:         strconv.Itoa(vector.newsID),
+			NewsID:     vector.newsID,
+			Metadata:   vector.metadata,
+			Similarity: Cosine(vector.embedding, embedding),
+		})
+	}
+	slices.SortStableFunc(docs, func(a, b Document) int { return cmp.Compare(b.Similarity, a.Similarity) })
+	return docs[:min(limit, len(docs))], nil
+}
+
+func (m *Memory) LoadUnindexedNews(_ context.Context, source, target data.EmbeddingProfile) ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	indexed := make(map[int]bool)
+	for _, vector := range m.vectors {
+		if vector.profileID == target.ID {
+			indexed[vector.newsID] = true
+		}
+	}
+	ids := make([]int, 0)
+	for _, vector := range m.vectors {
+		if vector.profileID == source.ID && !indexed[vector.newsID] && !slices.Contains(ids, vector.newsID) {
+			ids = append(ids, vector.newsID)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+func (m *Memory) LoadChunks(_ context.Context, profile data.EmbeddingProfile, newsID int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chunks := make([]string, 0)
+	for _, vector := range m.vectors {
+		if vector.profileID == profile.ID && vector.newsID == newsID {
+			chunks = append(chunks, fmt.Sprint(vector.metadata["content"]))
+		}
+	}
+	return chunks, nil
+}
+
+func (m *Memory) CountVectors(_ context.Context, profile data.EmbeddingProfile) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.countVectors(profile.ID), nil
+}
+
+func (m *Memory) countVectors(profileID int) int64 {
+	var rows int64
+	for _, vector := range m.vectors {
+		if vector.profileID == profileID {
+			rows++
+		}
+	}
+	return rows
+}
+
+// CreateProfileIndex does nothing, vectors are always searched exhaustively.
+func (m *Memory) CreateProfileIndex(_ context.Context, _ data.EmbeddingProfile) error {
+	return nil
+}
+
+// RebuildProfileIndex only stores the settings in the profile.
+func (m *Memory) RebuildProfileIndex(_ context.Context, profile data.EmbeddingProfile, settings data.IndexSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.profiles[profile.ID]; ok {
+		stored.Index = settings
+		m.profiles[profile.ID] = stored
+	}
+	return nil
+}
+
+func (m *Memory) UpdateSearchSettings(_ context.Context, profile data.EmbeddingProfile, probes, efSearch int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.profiles[profile.ID]; ok {
+		stored.Index.Probes, stored.Index.EfSearch = probes, efSearch
+		m.profiles[profile.ID] = stored
+	}
+	return nil
+}
+
+func (m *Memory) LoadIndexStats(_ context.Context) ([]IndexStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make([]IndexStats, 0, len(m.profiles))
+	for _, profile := range sortedValues(m.profiles) {
+		rows := m.countVectors(profile.ID)
+		stats = append(stats, IndexStats{
+			Profile:          profile,
+			IndexName:        profileIndexName(profile),
+			Exists:           true,
+			Valid:            true,
+			Rows:             rows,
+			RecommendedLists: RecommendedLists(rows),
+		})
+	}
+	return stats, nil
+}
+
+func (m *Memory) AddSavedSearch(_ context.Context, search data.SavedSearch, profile data.EmbeddingProfile, embedding []float32) (int, error) {
+	if len(embedding) != profile.Dims {
+		return 0, fmt.Errorf("embedding length %d does not match profile %s dimension %d", len(embedding), profile.Name, profile.Dims)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.groups[search.GroupID]; search.GroupID != 0 && !ok {
+		return 0, missingReference("saved_searches_group_id_fkey")
+	}
+	search.ID = m.nextID()
+	search.CreatedAt = m.now()
+	m.searches[search.ID] = memorySearch{SavedSearch: search, profileID: profile.ID, embedding: embedding}
+	return search.ID, nil
+}
+
+func (m *Memory) DeleteSavedSearch(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.searches[id]; !ok {
+		return &NotFoundError{Entity: "search", ID: id}
+	}
+	m.deleteSavedSearch(id)
+	return nil
+}
+
+func (m *Memory) deleteSavedSearch(id int) {
+	delete(m.searches, id)
+	for alertID, alert := range m.alerts {
+		if alert.searchID == id {
+			delete(m.alerts, alertID)
+		}
+	}
+}
+
+func (m *Memory) LoadSavedSearches(_ context.Context) ([]data.SavedSearch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	searches := make([]data.SavedSearch, 0, len(m.searches))
+	for _, search := range sortedValues(m.searches) {
+		searches = append(searches, search.SavedSearch)
+	}
+	return searches, nil
+}
+
+func (m *Memory) ClaimAlerts(_ context.Context, lease time.Duration, limit int) ([]data.SearchAlert, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	alerts := make([]data.SearchAlert, 0)
+	for _, alert := range sortedValues(m.alerts) {
+		if len(alerts) == limit {
+			break
+		}
+		if alert.Status != 0 || alert.nextAttemptAt.After(now) || alert.lockedUntil.After(now) {
+			continue
+		}
+		alert.lockedUntil = now.Add(lease)
+		m.alerts[alert.ID] = alert
+		result := alert.SearchAlert
+		result.Search = m.searches[alert.searchID].SavedSearch
+		result.News = m.news[alert.newsID]
+		alerts = append(alerts, result)
+	}
+	return alerts, nil
+}
+
+func (m *Memory) MarkAlertDelivered(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if alert, ok := m.alerts[id]; ok {
+		alert.Status, alert.LastError, alert.lockedUntil = 1, "", time.Time{}
+		alert.Attempts++
+		m.alerts[id] = alert
+	}
+	return nil
+}
+
+func (m *Memory) MarkAlertFailed(_ context.Context, id int, reason string, retryIn time.Duration, maxAttempts int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if alert, ok := m.alerts[id]; ok {
+		alert.Attempts++
+		alert.LastError = reason
+		alert.Status = 0
+		if alert.Attempts >= maxAttempts {
+			alert.Status = 2
+		}
+		alert.nextAttemptAt = m.now().Add(retryIn)
+		alert.lockedUntil = time.Time{}
+		m.alerts[id] = alert
+	}
+	return nil
+}
+
+// Cosine returns the cosine similarity of two vectors of the same length.
+func Cosine(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+func (m *Memory) AddAPIKey(_ context.Context, key data.APIKey) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.keys {
+		if existing.Name == key.Name {
+			return 0, uniqueViolation("api_keys_name_key")
+		}
+		if bytes.Equal(existing.Hash, key.Hash) {
+			return 0, uniqueViolation("api_keys_key_hash_key")
+		}
+	}
+	if _, ok := m.users[key.UserID]; key.UserID != 0 && !ok {
+		return 0, missingReference("api_keys_user_id_fkey")
+	}
+	key.ID = m.nextID()
+	key.Hash = slices.Clone(key.Hash)
+	key.CreatedAt = m.now()
+	key.LastUsedAt = time.Time{}
+	m.keys[key.ID] = key
+	return key.ID, nil
+}
+
+func (m *Memory) LoadAPIKeys(_ context.Context) ([]data.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return sortedValues(m.keys), nil
+}
+
+func (m *Memory) LoadAPIKeyByHash(_ context.Context, hash []byte) (*data.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range m.keys {
+		if bytes.Equal(key.Hash, hash) {
+			return &key, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *Memory) DeleteAPIKey(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.keys[id]; !ok {
+		return &NotFoundError{Entity: "key", ID: id}
+	}
+	delete(m.keys, id)
+	return nil
+}
+
+func (m *Memory) MarkAPIKeyUsed(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.keys[id]
+	now := m.now()
+	if ok && (key.LastUsedAt.IsZero() || key.LastUsedAt.Before(now.Add(-keyUsageInterval))) {
+		key.LastUsedAt = now
+		m.keys[key.ID] = key
+	}
+	return nil
+}
+
+func (m *Memory) AddUser(_ context.Context, name string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if user.Name == name {
+			return 0, uniqueViolation("users_name_key")
+		}
+	}
+	id := m.nextID()
+	m.users[id] = data.User{ID: id, Name: name, ChannelIDs: make([]int, 0), CreatedAt: m.now()}
+	return id, nil
+}
+
+func (m *Memory) LoadUsers(_ context.Context) ([]data.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	users := make([]data.User, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, cloneUser(user))
+	}
+	slices.SortFunc(users, func(a, b data.User) int { return cmp.Compare(a.Name, b.Name) })
+	return users, nil
+}
+
+func (m *Memory) LoadUser(_ context.Context, id int) (*data.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, nil
+	}
+	user = cloneUser(user)
+	return &user, nil
+}
+
+func (m *Memory) LoadUserByName(_ context.Context, name string) (*data.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if user.Name == name {
+			user = cloneUser(user)
+			return &user, nil
+		}
+	}
+	return nil, nil
+}
+
+// cloneUser copies the subscriptions of a stored user, in the order of the databases.
+func cloneUser(user data.User) data.User {
+	user.ChannelIDs = slices.Clone(user.ChannelIDs)
+	slices.Sort(user.ChannelIDs)
+	return user
+}
+
+func (m *Memory) DeleteUser(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[id]; !ok {
+		return &NotFoundError{Entity: "user", ID: id}
+	}
+	delete(m.users, id)
+	for key := range m.states {
+		if key.userID == id {
+			delete(m.states, key)
+		}
+	}
+	for keyID, key := range m.keys {
+		if key.UserID == id {
+			delete(m.keys, keyID)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) AddSubscription(_ context.Context, userID, channelID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return missingReference("subscriptions_user_id_fkey")
+	}
+	if _, ok := m.channels[channelID]; !ok {
+		return missingReference("subscriptions_channel_id_fkey")
+	}
+	if !slices.Contains(user.ChannelIDs, channelID) {
+		user.ChannelIDs = append(user.ChannelIDs, channelID)
+		m.users[userID] = user
+	}
+	return nil
+}
+
+func (m *Memory) DeleteSubscription(_ context.Context, userID, channelID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok || !slices.Contains(user.ChannelIDs, channelID) {
+		return &NotFoundError{Entity: "subscription", ID: channelID}
+	}
+	user.ChannelIDs = slices.DeleteFunc(user.ChannelIDs, func(id int) bool { return id == channelID })
+	m.users[userID] = user
+	return nil
+}
+
+func (m *Memory) SaveNewsState(_ context.Context, userID, newsID int, state data.NewsState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return missingReference("news_states_user_id_fkey")
+	}
+	if _, ok := m.news[newsID]; !ok {
+		return missingReference("news_states_news_id_fkey")
+	}
+	m.states[memoryStateKey{userID: userID, newsID: newsID}] = state
+	return nil
+}
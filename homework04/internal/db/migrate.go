@@ -0,0 +1,312 @@
+package db
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"rss_fetcher/migrations"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Schema modes of the services on startup.
+const (
+	SchemaMigrate = "migrate" // apply pending migrations
+	SchemaCheck   = "check"   // fail when migrations are pending
+	SchemaIgnore  = "off"     // leave the schema alone
+)
+
+// migrationLock serializes migrations of replicas starting at the same time.
+const migrationLock = 20250614171248
+
+// Migration is a goose SQL migration. Applied versions are recorded in the
+// goose_db_version table, so databases migrated with the goose CLI keep working.
+type Migration struct {
+	Version       int64
+	Name          string
+	Up            string
+	Down          string
+	NoTransaction bool
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator is implemented by stores whose schema is versioned by migrations.
+// SQLite and Memory create their schema when they are opened.
+type Migrator interface {
+	MigrateUp(ctx context.Context) ([]Migration, error)
+	MigrateDown(ctx context.Context) (*Migration, error)
+	MigrationStatus(ctx context.Context) ([]MigrationStatus, error)
+}
+
+var _ Migrator = (*Postgres)(nil)
+
+// PrepareSchema applies or checks the migrations of the store according to mode.
+func PrepareSchema(ctx context.Context, store Store, mode string) error {
+	migrator, ok := store.(Migrator)
+	if !ok || mode == SchemaIgnore {
+		return nil
+	}
+	switch mode {
+	case SchemaMigrate:
+		_, err := migrator.MigrateUp(ctx)
+		return err
+	case SchemaCheck:
+		statuses, err := migrator.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if !status.Applied {
+				return fmt.Errorf("migration %d %s is pending, run admin migrate up", status.Version, status.Name)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown schema mode %q, use %s, %s or %s", mode, SchemaMigrate, SchemaCheck, SchemaIgnore)
+	}
+}
+
+// Migrations returns the embedded migrations ordered by version.
+func Migrations() ([]Migration, error) {
+	return loadMigrations(migrations.FS)
+}
+
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Migration, 0, len(files))
+	for _, file := range files {
+		migration, err := parseMigration(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		result = append(result, migration)
+	}
+	slices.SortFunc(result, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	for i := 1; i < len(result); i++ {
+		if result[i].Version == result[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", result[i].Version)
+		}
+	}
+	return result, nil
+}
+
+// parseMigration reads a file named <version>_<name>.sql with goose annotations.
+// The statements of a direction are executed together, so StatementBegin and
+// StatementEnd only need to be accepted.
+func parseMigration(fsys fs.FS, file string) (Migration, error) {
+	versionText, name, ok := strings.Cut(strings.TrimSuffix(path.Base(file), ".sql"), "_")
+	if !ok {
+		return Migration{}, errors.New("file name is not <version>_<name>.sql")
+	}
+	version, err := strconv.ParseInt(versionText, 10, 64)
+	if err != nil || version <= 0 {
+		return Migration{}, fmt.Errorf("invalid version %q", versionText)
+	}
+
+	content, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return Migration{}, err
+	}
+	migration := Migration{Version: version, Name: name}
+	var up, down strings.Builder
+	var section *strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		annotation, ok := strings.CutPrefix(strings.TrimSpace(line), "-- +goose ")
+		if !ok {
+			if section != nil {
+				section.WriteString(line)
+				section.WriteByte('\n')
+			}
+			continue
+		}
+		switch strings.TrimSpace(annotation) {
+		case "Up":
+			section = &up
+		case "Down":
+			section = &down
+		case "StatementBegin", "StatementEnd":
+		case "NO TRANSACTION":
+			migration.NoTransaction = true
+		default:
+			return Migration{}, fmt.Errorf("unknown annotation %q", annotation)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Migration{}, err
+	}
+	migration.Up, migration.Down = strings.TrimSpace(up.String()), strings.TrimSpace(down.String())
+	if migration.Up == "" {
+		return Migration{}, errors.New("no Up statements")
+	}
+	return migration, nil
+}
+
+// MigrateUp applies the pending migrations in version order and returns them.
+func (pg *Postgres) MigrateUp(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := pg.withMigrationLock(ctx, func(conn *pgx.Conn) error {
+		statuses, err := migrationStatus(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.Applied {
+				continue
+			}
+			if err := runMigration(ctx, conn, status.Migration, status.Up,
+				"INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, TRUE)"); err != nil {
+				return fmt.Errorf("migration %d %s failed: %w", status.Version, status.Name, err)
+			}
+			applied = append(applied, status.Migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// MigrateDown rolls back the latest applied migration, nil when none is applied.
+func (pg *Postgres) MigrateDown(ctx context.Context) (*Migration, error) {
+	var rolledBack *Migration
+	err := pg.withMigrationLock(ctx, func(conn *pgx.Conn) error {
+		statuses, err := migrationStatus(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(statuses) - 1; i >= 0; i-- {
+			status := statuses[i]
+			if !status.Applied {
+				continue
+			}
+			if err := runMigration(ctx, conn, status.Migration, status.Down,
+				"DELETE FROM goose_db_version WHERE version_id = $1"); err != nil {
+				return fmt.Errorf("rollback of migration %d %s failed: %w", status.Version, status.Name, err)
+			}
+			rolledBack = &status.Migration
+			return nil
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// MigrationStatus reports every embedded migration and whether it is applied.
+func (pg *Postgres) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := pg.withMigrationLock(ctx, func(conn *pgx.Conn) error {
+		var err error
+		statuses, err = migrationStatus(ctx, conn)
+		return err
+	})
+	return statuses, err
+}
+
+// withMigrationLock runs fn on a dedicated connection holding an advisory lock,
+// with the version table created the way goose creates it.
+func (pg *Postgres) withMigrationLock(ctx context.Context, fn func(conn *pgx.Conn) error) error {
+	conn, err := pg.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLock); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLock)
+
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS goose_db_version (
+			id SERIAL PRIMARY KEY,
+			version_id BIGINT NOT NULL,
+			is_applied BOOLEAN NOT NULL,
+			tstamp TIMESTAMP NULL DEFAULT NOW()
+		);
+		INSERT INTO goose_db_version (version_id, is_applied)
+		SELECT 0, TRUE WHERE NOT EXISTS (SELECT 1 FROM goose_db_version)`)
+	if err != nil {
+		return fmt.Errorf("failed to create version table: %w", err)
+	}
+	return fn(conn.Conn())
+}
+
+// migrationStatus matches the embedded migrations with the version table, where
+// the latest row of a version tells whether it is applied.
+func migrationStatus(ctx context.Context, conn *pgx.Conn) ([]MigrationStatus, error) {
+	embedded, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	type versionRow struct {
+		applied bool
+		tstamp  *time.Time
+	}
+	rows, err := conn.Query(ctx, "SELECT version_id, is_applied, tstamp FROM goose_db_version ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[int64]versionRow)
+	var version int64
+	var row versionRow
+	_, err = pgx.ForEachRow(rows, []any{&version, &row.applied, &row.tstamp}, func() error {
+		versions[version] = row
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(embedded))
+	for i, migration := range embedded {
+		statuses[i] = MigrationStatus{Migration: migration}
+		if row, ok := versions[migration.Version]; ok && row.applied {
+			statuses[i].Applied = true
+			if row.tstamp != nil {
+				statuses[i].AppliedAt = *row.tstamp
+			}
+		}
+	}
+	return statuses, nil
+}
+
+// runMigration executes the statements of one direction and records the version,
+// in a single transaction unless the migration opts out.
+func runMigration(ctx context.Context, conn *pgx.Conn, migration Migration, statements, record string) error {
+	if migration.NoTransaction {
+		if statements != "" {
+			if _, err := conn.Exec(ctx, statements); err != nil {
+				return err
+			}
+		}
+		_, err := conn.Exec(ctx, record, migration.Version)
+		return err
+	}
+
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if statements != "" {
+			if _, err := tx.Exec(ctx, statements); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(ctx, record, migration.Version)
+		return err
+	})
+}
@@ -0,0 +1,167 @@
+package db
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"20250102000000_second.sql": {Data: []byte(`-- +goose NO TRANSACTION
+-- +goose Up
+CREATE INDEX CONCURRENTLY b_idx ON b(id);
+
+-- +goose Down
+DROP INDEX b_idx;
+`)},
+		"20250101000000_first.sql": {Data: []byte(`-- header comment
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE a (id INTEGER);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE a;
+-- +goose StatementEnd
+`)},
+		"README.md": {Data: []byte("not a migration")},
+	}
+	got, err := loadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	want := []Migration{
+		{Version: 20250101000000, Name: "first", Up: "CREATE TABLE a (id INTEGER);", Down: "DROP TABLE a;"},
+		{Version: 20250102000000, Name: "second", Up: "CREATE INDEX CONCURRENTLY b_idx ON b(id);", Down: "DROP INDEX b_idx;", NoTransaction: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("loadMigrations returned %d migrations, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		err   string
+	}{
+		{
+			name: "unknown annotation",
+			files: fstest.MapFS{"20250101000000_a.sql": {Data: []byte(
+				"-- +goose Up\nCREATE TABLE a (id INTEGER);\n-- +goose Sideways\n")}},
+			err: `unknown annotation "Sideways"`,
+		},
+		{
+			name: "missing up",
+			files: fstest.MapFS{"20250101000000_a.sql": {Data: []byte(
+				"-- +goose Down\nDROP TABLE a;\n")}},
+			err: "no Up statements",
+		},
+		{
+			name: "empty up",
+			files: fstest.MapFS{"20250101000000_a.sql": {Data: []byte(
+				"-- +goose Up\n\n-- +goose Down\nDROP TABLE a;\n")}},
+			err: "no Up statements",
+		},
+		{
+			name: "duplicate version",
+			files: fstest.MapFS{
+				"20250101000000_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id INTEGER);\n")},
+				"20250101000000_b.sql": {Data: []byte("-- +goose Up\nCREATE TABLE b (id INTEGER);\n")},
+			},
+			err: "duplicate migration version 20250101000000",
+		},
+		{
+			name:  "file name without name",
+			files: fstest.MapFS{"20250101000000.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")}},
+			err:   "file name is not <version>_<name>.sql",
+		},
+		{
+			name:  "invalid version",
+			files: fstest.MapFS{"first_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")}},
+			err:   `invalid version "first"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadMigrations(tt.files)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("loadMigrations error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	for _, migration := range migrations {
+		if migration.Down == "" {
+			t.Errorf("migration %d %s has no Down statements", migration.Version, migration.Name)
+		}
+	}
+}
+
+// TestMigrationRoundTrip applies all migrations, rolls every one of them back and
+// applies them again. It drops all tables of the database, so it only runs against
+// the scratch database of RSS_TEST_DATABASE_URL.
+func TestMigrationRoundTrip(t *testing.T) {
+	url := os.Getenv("RSS_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("RSS_TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	pg, err := NewPostgres(ctx, url)
+	if err != nil {
+		t.Fatalf("NewPostgres: %v", err)
+	}
+	defer pg.Close()
+
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	if _, err := pg.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		rolledBack, err := pg.MigrateDown(ctx)
+		if err != nil {
+			t.Fatalf("MigrateDown: %v", err)
+		}
+		if rolledBack == nil || rolledBack.Version != migrations[i].Version {
+			t.Fatalf("MigrateDown rolled back %+v, want version %d", rolledBack, migrations[i].Version)
+		}
+	}
+	if rolledBack, err := pg.MigrateDown(ctx); err != nil || rolledBack != nil {
+		t.Fatalf("MigrateDown of an empty schema = %+v, %v, want nil, nil", rolledBack, err)
+	}
+
+	applied, err := pg.MigrateUp(ctx)
+	if err != nil {
+		t.Fatalf("MigrateUp after rollback: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("MigrateUp after rollback applied %d migrations, want %d", len(applied), len(migrations))
+	}
+	statuses, err := pg.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus: %v", err)
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			t.Errorf("migration %d %s is not applied", status.Version, status.Name)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Notification channels raised by the triggers of the schema.
+// The payload is the id of the inserted row.
+const (
+	ChannelAddedEvent = "channel_added"
+	JobAddedEvent     = "job_added"
+)
+
+const listenRetryDelay = 5 * time.Second
+
+// Listen subscribes to a Postgres notification channel on a dedicated connection
+// and forwards payloads to notifications until ctx is done. The connection is
+// re-established after failures. Payloads are dropped when the receiver is busy,
+// so callers must keep polling as a fallback.
+func Listen(ctx context.Context, connectionUrl, channel string, notifications chan<- string) {
+	for ctx.Err() == nil {
+		if err := listen(ctx, connectionUrl, channel, notifications); err != nil && ctx.Err() == nil {
+			log.Printf("Listening to %s failed, reconnecting in %v: %v", channel, listenRetryDelay, err)
+			select {
+			case <-time.After(listenRetryDelay):
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+func listen(ctx context.Context, connectionUrl, channel string, notifications chan<- string) error {
+	conn, err := pgx.Connect(ctx, connectionUrl)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+	log.Printf("Listening to %s notifications", channel)
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		select {
+		case notifications <- notification.Payload:
+		default:
+		}
+	}
+}
+
+// Listener is implemented by stores which raise notifications, SQLite does not.
+type Listener interface {
+	Listen(ctx context.Context, channel string, notifications chan<- string)
+}
+
+// Listen subscribes to a notification channel of the database, see Listen.
+func (pg *Postgres) Listen(ctx context.Context, channel string, notifications chan<- string) {
+	Listen(ctx, pg.pool.Config().ConnString(), channel, notifications)
+}
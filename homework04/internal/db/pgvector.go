@@ -20,71 +20,97 @@
 package db
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"rss_fetcher/internal/data"
+	"slices"
 	"strings"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pgvector/pgvector-go"
 )
 
-// PGVector represents a connection to a PostgreSQL database with pgvector extension.
-// It provides methods for storing and querying vector embeddings.
-type PGVector struct {
-	conn *pgxpool.Pool
-}
-
-// Close closes the PostgreSQL connection pool.
-func (pg *PGVector) Close() {
-	pg.conn.Close()
-}
-
-// NewPGVector creates a new PGVector instance with a connection to the PostgreSQL database.
+// SaveEmbeddings stores a document embedding and associated metadata in the database.
 //
 // Parameters:
-//   - connString: A string containing the connection details for the PostgreSQL database.
+//   - ctx: The context for the database operation.
+//   - profile: The embedding profile which produced the embedding.
+//   - docID: A unique identifier for the document.
+//   - embedding: A slice of float32 values representing the document's embedding.
+//   - metadata: A map of additional information associated with the document.
 //
 // Returns:
-//   - A pointer to a new PGVector instance.
-//   - An error if the connection fails, nil otherwise.
-func NewPGVector(connString string) (*PGVector, error) {
-	pool, err := pgxpool.New(context.Background(), connString)
+//   - An error if the saving operation fails, nil otherwise.
+func (pg *Postgres) SaveEmbeddings(ctx context.Context, profile data.EmbeddingProfile, docID int, embedding []float32, metadata map[string]interface{}) error {
+	return saveEmbeddings(ctx, pg.pool, profile, docID, embedding, metadata)
+}
+
+func saveEmbeddings(ctx context.Context, conn QueryInterface, profile data.EmbeddingProfile, docID int, embedding []float32, metadata map[string]interface{}) error {
+	// Vectors of other dimensions would not be comparable with the rest of the profile
+	if len(embedding) != profile.Dims {
+		return fmt.Errorf("embedding length %d does not match profile %s dimension %d", len(embedding), profile.Name, profile.Dims)
+	}
+
+	_, err := conn.Exec(ctx, `INSERT INTO news_embeddings (news_id, embedding, metadata, profile_id, model, dims, chunker) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		docID, pgvector.NewVector(embedding), metadata, profile.ID, profile.Model, profile.Dims, profile.Chunker)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return fmt.Errorf("failed to insert document: %w", err)
 	}
-	return &PGVector{conn: pool}, nil
+	return nil
 }
 
-// SaveEmbedding stores a document embedding and associated metadata in the database.
+// IndexArticle atomically replaces the embeddings of an article in a profile. Previous chunks of
+// the article are deleted and the new ones are inserted in a single transaction, so readers
+// see either the old or the new version of the article but never a part of it.
+// Saved searches are matched in the same transaction when the profile is active.
 //
 // Parameters:
 //   - ctx: The context for the database operation.
-//   - docID: A unique identifier for the document.
-//   - embedding: A slice of float32 values representing the document's embedding.
-//   - metadata: A map of additional information associated with the document.
+//   - profile: The embedding profile which produced the embeddings.
+//   - newsID: The identifier of the article.
+//   - chunks: The text chunks of the article.
+//   - embeddings: The embeddings of the chunks, in the same order.
 //
 // Returns:
-//   - An error if the saving operation fails, nil otherwise.
-//
-// SaveEmbeddings stores a document embedding and associated metadata in the PostgreSQL database, implementing the VectorDatabase interface.
-func (pg *PGVector) SaveEmbeddings(ctx context.Context, docID int, embedding []float32, metadata map[string]interface{}) error {
-	vector := pgvector.NewVector(embedding)
-
-	// Determine the table based on the embedding length
-	var query string
-	switch len(embedding) {
-	case 1024:
-		query = `INSERT INTO news_embeddings (news_id, embedding, metadata) VALUES ($1, $2, $3)`
-	default:
-		return fmt.Errorf("unsupported embedding length: %d", len(embedding))
+//   - The number of new saved search alerts.
+//   - An error if the article could not be indexed, nil otherwise.
+func (pg *Postgres) IndexArticle(ctx context.Context, profile data.EmbeddingProfile, newsID int, chunks []string, embeddings [][]float32) (int64, error) {
+	if len(chunks) != len(embeddings) {
+		return 0, fmt.Errorf("got %d embeddings for %d chunks", len(embeddings), len(chunks))
 	}
 
-	// Execute the query to insert the vector into the database
-	_, err := pg.conn.Exec(ctx, query, docID, vector, metadata)
+	tx, err := pg.pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to insert document: %w", err)
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
 	}
-	return nil
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM news_embeddings WHERE news_id = $1 AND profile_id = $2`, newsID, profile.ID); err != nil {
+		return 0, fmt.Errorf("failed to delete previous embeddings: %w", err)
+	}
+
+	var alerts int64
+	for i, chunk := range chunks {
+		metadata := map[string]interface{}{
+			"content": chunk,
+		}
+		if err := saveEmbeddings(ctx, tx, profile, newsID, embeddings[i], metadata); err != nil {
+			return 0, err
+		}
+		if profile.Status != data.ProfileActive {
+			continue
+		}
+		matched, err := matchSavedSearches(ctx, tx, profile, newsID, pgvector.NewVector(embeddings[i]), chunk)
+		if err != nil {
+			return 0, err
+		}
+		alerts += matched
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit error: %w", err)
+	}
+	return alerts, nil
 }
 
 // QueryRelevantDocuments retrieves the most relevant documents from the database based on the given embedding.
@@ -92,29 +118,50 @@ func (pg *PGVector) SaveEmbeddings(ctx context.Context, docID int, embedding []f
 //
 // Parameters:
 //   - ctx: The context for the database query.
+//   - profile: The embedding profile which produced the query embedding, only its vectors are searched.
 //   - embedding: A slice of float32 values representing the query embedding.
+//   - limit: The maximum number of chunks to return.
+//   - scope: Restricts the search to the chunks of some news, e.g. of the channels in a group.
 //
 // Returns:
-//   - A slice of Document structs containing the most relevant documents.
+//   - A slice of Document structs containing the most relevant documents, most similar first.
 //   - An error if the query fails or if there's an issue scanning the results.
-func (pg *PGVector) QueryRelevantDocuments(ctx context.Context, embedding []float32, backend string) ([]Document, error) {
+func (pg *Postgres) QueryRelevantDocuments(ctx context.Context, profile data.EmbeddingProfile, embedding []float32, backend string, limit int, scope DocumentScope) ([]Document, error) {
+	if len(embedding) != profile.Dims {
+		return nil, fmt.Errorf("query embedding length %d does not match profile %s dimension %d", len(embedding), profile.Name, profile.Dims)
+	}
+
 	// Convert embedding to the required format
 	vector := pgvector.NewVector(embedding)
 
-	// Query similar vectors based on cosine similarity or any distance metric supported by pgvector.
+	// Query similar vectors based on cosine distance, the expression matches the partial index of the profile.
+	where := &where{param: "$", args: []any{vector, limit}}
+	where.equal("profile_id", profile.ID)
+	scope.apply(where)
 	var query string
 	switch backend {
 	case "ollama":
-		query = `
-			SELECT news_id, metadata
-			FROM news_embeddings
-			ORDER BY embedding <-> $1
-			LIMIT 1
-		`
+		query = fmt.Sprintf(`
+			SELECT news_id::text, news_id, metadata, 1 - (%[1]s <=> $1)
+			FROM news_embeddings%[2]s
+			ORDER BY %[1]s <=> $1
+			LIMIT $2
+		`, profileVector(profile), where)
 	default:
 		return nil, fmt.Errorf("unsupported backend: %s", backend)
 	}
-	rows, err := pg.conn.Query(ctx, query, vector)
+	// Search parameters of the index are set for this query only
+	tx, err := pg.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := applySearchSettings(ctx, tx, profile, scope != DocumentScope{}); err != nil {
+		return nil, fmt.Errorf("failed to apply search settings: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, query, where.args...)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to query relevant documents: %w", err)
@@ -124,12 +171,16 @@ func (pg *PGVector) QueryRelevantDocuments(ctx context.Context, embedding []floa
 	var docs []Document
 	for rows.Next() {
 		var doc Document
-		if err := rows.Scan(&doc.ID, &doc.Metadata); err != nil {
+		if err := rows.Scan(&doc.ID, &doc.NewsID, &doc.Metadata, &doc.Similarity); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 		docs = append(docs, doc)
 	}
-
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+	// an iterative ivfflat scan may return the rows slightly out of order
+	slices.SortStableFunc(docs, func(a, b Document) int { return cmp.Compare(b.Similarity, a.Similarity) })
 	return docs, nil
 }
 
@@ -155,8 +206,8 @@ func ConvertEmbeddingToPGVector(embedding []float32) string {
 	return fmt.Sprintf("{%s}", strings.Join(strValues, ","))
 }
 
-// InsertDocument inserts a document into the PGVector store, implementing the VectorDatabase interface.
-func (pg *PGVector) InsertDocument(ctx context.Context, docID int, content string, embedding []float32) error {
+// InsertDocument inserts a document into the pgvector table, implementing the VectorDatabase interface.
+func (pg *Postgres) InsertDocument(ctx context.Context, profile data.EmbeddingProfile, docID int, content string, embedding []float32) error {
 	// Generate a unique document ID (for simplicity, using UUID)
 	// docID := fmt.Sprintf("doc-%s", uuid.New().String())
 
@@ -166,7 +217,7 @@ func (pg *PGVector) InsertDocument(ctx context.Context, docID int, content strin
 	}
 
 	// Save the document and its embedding into the vector store
-	err := pg.SaveEmbeddings(ctx, docID, embedding, metadata)
+	err := pg.SaveEmbeddings(ctx, profile, docID, embedding, metadata)
 	if err != nil {
 		return fmt.Errorf("error saving embedding: %v", err)
 	}
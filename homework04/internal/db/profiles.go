@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rss_fetcher/internal/data"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pgvector/pgvector-go"
+)
+
+const profileColumns = "profile_id, name, model, dims, status, chunker, contextual, created_at, activated_at, " +
+	"index_type, ivfflat_lists, ivfflat_probes, hnsw_m, hnsw_ef_construction, hnsw_ef_search"
+
+func scanProfile(row pgx.Row) (data.EmbeddingProfile, error) {
+	var profile data.EmbeddingProfile
+	var activatedAt *time.Time
+	index := &profile.Index
+
+	if err := row.Scan(&profile.ID, &profile.Name, &profile.Model, &profile.Dims, &profile.Status, &profile.Chunker, &profile.Contextual, &profile.CreatedAt, &activatedAt,
+		&index.Type, &index.Lists, &index.Probes, &index.M, &index.EfConstruction, &index.EfSearch); err != nil {
+		return profile, err
+	}
+	if activatedAt != nil {
+		profile.ActivatedAt = *activatedAt
+	}
+	return profile, nil
+}
+
+func (pg *Postgres) AddProfile(ctx context.Context, profile data.EmbeddingProfile) (*data.EmbeddingProfile, error) {
+	index := profile.Index
+	added, err := scanProfile(pg.pool.QueryRow(ctx, `
+		INSERT INTO embedding_profiles (name, model, dims, status, chunker, contextual, activated_at,
+			index_type, ivfflat_lists, ivfflat_probes, hnsw_m, hnsw_ef_construction, hnsw_ef_search)
+		VALUES ($1, $2, $3, $4, $5, $6, CASE WHEN $4 = 'active' THEN NOW() END, $7, $8, $9, $10, $11, $12)
+		RETURNING `+profileColumns,
+		profile.Name, profile.Model, profile.Dims, string(profile.Status), profile.Chunker, profile.Contextual,
+		string(index.Type), index.Lists, index.Probes, index.M, index.EfConstruction, index.EfSearch))
+	if err != nil {
+		return nil, Classify(err)
+	}
+	return &added, nil
+}
+
+// LoadProfiles returns the profiles with any of the given statuses, or all profiles when none is given.
+func (pg *Postgres) LoadProfiles(ctx context.Context, statuses ...data.ProfileStatus) ([]data.EmbeddingProfile, error) {
+	filter := make([]string, len(statuses))
+	for i, status := range statuses {
+		filter[i] = string(status)
+	}
+
+	rows, err := pg.pool.Query(ctx, "SELECT "+profileColumns+" FROM embedding_profiles WHERE cardinality($1::text[]) = 0 OR status = ANY($1) ORDER BY profile_id", filter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := make([]data.EmbeddingProfile, 0)
+
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+// LoadActiveProfile returns the profile serving queries, nil if there is none.
+func (pg *Postgres) LoadActiveProfile(ctx context.Context) (*data.EmbeddingProfile, error) {
+	profile, err := scanProfile(pg.pool.QueryRow(ctx, "SELECT "+profileColumns+" FROM embedding_profiles WHERE status = 'active'"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// LoadProfileByName returns the profile with the name, nil if there is none.
+func (pg *Postgres) LoadProfileByName(ctx context.Context, name string) (*data.EmbeddingProfile, error) {
+	profile, err := scanProfile(pg.pool.QueryRow(ctx, "SELECT "+profileColumns+" FROM embedding_profiles WHERE name = $1", name))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// PruneRetiredProfiles deletes retired profiles together with their vectors.
+func (pg *Postgres) PruneRetiredProfiles(ctx context.Context) (int64, error) {
+	tag, err := pg.pool.Exec(ctx, "DELETE FROM embedding_profiles WHERE status = 'retired'")
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// profileIndexName is the name of the vector index of a profile.
+func profileIndexName(profile data.EmbeddingProfile) string {
+	return fmt.Sprintf("news_embeddings_profile_%d_idx", profile.ID)
+}
+
+// profileVector is the indexed expression of a profile. The embedding column keeps vectors of
+// any dimension, so it is cast to the profile dimension for the partial index to be usable.
+func profileVector(profile data.EmbeddingProfile) string {
+	return fmt.Sprintf("embedding::vector(%d)", profile.Dims)
+}
+
+// CreateProfileIndex builds the vector index of a profile if it does not exist yet.
+func (pg *Postgres) CreateProfileIndex(ctx context.Context, profile data.EmbeddingProfile) error {
+	query, err := profileIndexDefinition(profile, profileIndexName(profile), false)
+	if err != nil {
+		return err
+	}
+	if _, err := pg.pool.Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to create index for profile %s: %w", profile.Name, err)
+	}
+	return nil
+}
+
+// LoadUnindexedNews returns ids of articles indexed in the source profile
+// which have no vectors in the target profile yet.
+func (pg *Postgres) LoadUnindexedNews(ctx context.Context, source, target data.EmbeddingProfile) ([]int, error) {
+	rows, err := pg.pool.Query(ctx, `
+		SELECT DISTINCT news_id FROM news_embeddings s
+		WHERE s.profile_id = $1
+			AND NOT EXISTS (SELECT 1 FROM news_embeddings t WHERE t.profile_id = $2 AND t.news_id = s.news_id)
+		ORDER BY news_id`, source.ID, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[int])
+}
+
+// LoadChunks returns the text chunks of an article in the order they were indexed in the profile.
+func (pg *Postgres) LoadChunks(ctx context.Context, profile data.EmbeddingProfile, newsID int) ([]string, error) {
+	rows, err := pg.pool.Query(ctx, `
+		SELECT metadata->>'content' FROM news_embeddings
+		WHERE profile_id = $1 AND news_id = $2
+		ORDER BY embedding_id`, profile.ID, newsID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// ActivateProfile atomically switches query traffic to the profile. The previously active
+// profile is retired and saved searches are moved to the new profile with the given
+// embeddings of their queries, keyed by search id. Every saved search must be provided.
+func (pg *Postgres) ActivateProfile(ctx context.Context, profile data.EmbeddingProfile, searchEmbeddings map[int][]float32) error {
+	tx, err := pg.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "UPDATE embedding_profiles SET status = 'retired' WHERE status = 'active'"); err != nil {
+		return fmt.Errorf("failed to retire active profile: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "UPDATE embedding_profiles SET status = 'active', activated_at = NOW() WHERE profile_id = $1", profile.ID); err != nil {
+		return fmt.Errorf("failed to activate profile: %w", err)
+	}
+
+	for searchID, embedding := range searchEmbeddings {
+		if _, err := tx.Exec(ctx, "UPDATE saved_searches SET embedding = $2, profile_id = $3 WHERE search_id = $1",
+			searchID, pgvector.NewVector(embedding), profile.ID); err != nil {
+			return fmt.Errorf("failed to update saved search %d: %w", searchID, err)
+		}
+	}
+	var stale int
+	if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM saved_searches WHERE profile_id <> $1", profile.ID).Scan(&stale); err != nil {
+		return err
+	}
+	if stale > 0 {
+		return fmt.Errorf("%d saved searches were added during reindexing, run it again", stale)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit error: %w", err)
+	}
+	return nil
+}
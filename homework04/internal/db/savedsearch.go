@@ -0,0 +1,156 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"rss_fetcher/internal/data"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pgvector/pgvector-go"
+)
+
+// AddSavedSearch stores a search with the embedding of its query produced by the profile.
+func (pg *Postgres) AddSavedSearch(ctx context.Context, search data.SavedSearch, profile data.EmbeddingProfile, embedding []float32) (int, error) {
+	if len(embedding) != profile.Dims {
+		return 0, fmt.Errorf("embedding length %d does not match profile %s dimension %d", len(embedding), profile.Name, profile.Dims)
+	}
+	var id int
+	err := pg.pool.QueryRow(ctx, "INSERT INTO saved_searches (name, query, embedding, threshold, webhook_url, secret, profile_id, group_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING search_id",
+		search.Name, search.Query, pgvector.NewVector(embedding), search.Threshold, search.WebhookURL, search.Secret, profile.ID, optionalID(search.GroupID)).Scan(&id)
+	return id, Classify(err)
+}
+
+func (pg *Postgres) DeleteSavedSearch(ctx context.Context, id int) error {
+	tag, err := pg.pool.Exec(ctx, "DELETE FROM saved_searches WHERE search_id = $1", id)
+	return rowsAffected(tag, err, "search", id)
+}
+
+func (pg *Postgres) LoadSavedSearches(ctx context.Context) ([]data.SavedSearch, error) {
+	rows, err := pg.pool.Query(ctx, "SELECT search_id, name, query, threshold, webhook_url, secret, COALESCE(group_id, 0), created_at FROM saved_searches ORDER BY search_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := make([]data.SavedSearch, 0)
+
+	for rows.Next() {
+		var search data.SavedSearch
+		if err := rows.Scan(&search.ID, &search.Name, &search.Query, &search.Threshold, &search.WebhookURL, &search.Secret, &search.GroupID, &search.CreatedAt); err != nil {
+			return nil, err
+		}
+		searches = append(searches, search)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return searches, nil
+}
+
+// ClaimAlerts leases up to limit alerts which are waiting for delivery and whose
+// retry time has come, together with the search and the news they refer to.
+// Alerts leased by another replica are skipped until their lease expires, rows
+// locked by concurrent claims are skipped, so each alert is posted once.
+func (pg *Postgres) ClaimAlerts(ctx context.Context, lease time.Duration, limit int) ([]data.SearchAlert, error) {
+	rows, err := pg.pool.Query(ctx, `
+		UPDATE saved_search_alerts a
+		SET locked_until = NOW() + make_interval(secs => $1)
+		FROM saved_searches s, channel_news n
+		WHERE s.search_id = a.search_id AND n.news_id = a.news_id
+			AND a.alert_id IN (
+				SELECT alert_id FROM saved_search_alerts
+				WHERE status = 0 AND next_attempt_at <= NOW()
+					AND (locked_until IS NULL OR locked_until < NOW())
+				ORDER BY alert_id
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+		RETURNING a.alert_id, a.similarity, a.chunk, a.status, a.attempts, a.last_error, a.created_at,
+			s.search_id, s.name, s.query, s.threshold, s.webhook_url, s.secret, s.created_at,
+			n.news_id, n.title, n.link, n.description, n.author, n.category, n.pub_date, n.guid`, lease.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts, err := scanAlerts(rows)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING has no order
+	slices.SortFunc(alerts, func(a, b data.SearchAlert) int { return cmp.Compare(a.ID, b.ID) })
+	return alerts, nil
+}
+
+// scanAlerts reads alerts joined with their search and news, Postgres and SQLite
+// return the columns the same way.
+func scanAlerts(rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+}) ([]data.SearchAlert, error) {
+	alerts := make([]data.SearchAlert, 0)
+
+	for rows.Next() {
+		var alert data.SearchAlert
+		var pubDate *time.Time
+		if err := rows.Scan(&alert.ID, &alert.Similarity, &alert.Chunk, &alert.Status, &alert.Attempts, &alert.LastError, &alert.CreatedAt,
+			&alert.Search.ID, &alert.Search.Name, &alert.Search.Query, &alert.Search.Threshold, &alert.Search.WebhookURL, &alert.Search.Secret, &alert.Search.CreatedAt,
+			&alert.News.ID, &alert.News.Title, &alert.News.Link, &alert.News.Description, &alert.News.Author, &alert.News.Category, &pubDate, &alert.News.GUID); err != nil {
+			return nil, err
+		}
+		if pubDate != nil {
+			alert.News.PubDate = *pubDate
+		}
+		alerts = append(alerts, alert)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return alerts, nil
+}
+
+func (pg *Postgres) MarkAlertDelivered(ctx context.Context, id int) error {
+	_, err := pg.pool.Exec(ctx, "UPDATE saved_search_alerts SET status = 1, attempts = attempts + 1, last_error = '', locked_until = NULL WHERE alert_id = $1", id)
+	return err
+}
+
+// MarkAlertFailed records a failed delivery attempt. The alert is scheduled
+// for another attempt after retryIn, or given up when maxAttempts is reached.
+func (pg *Postgres) MarkAlertFailed(ctx context.Context, id int, reason string, retryIn time.Duration, maxAttempts int) error {
+	_, err := pg.pool.Exec(ctx, `
+		UPDATE saved_search_alerts
+		SET attempts = attempts + 1,
+			last_error = $2,
+			status = CASE WHEN attempts + 1 >= $3 THEN 2 ELSE 0 END,
+			next_attempt_at = NOW() + make_interval(secs => $4),
+			locked_until = NULL
+		WHERE alert_id = $1`, id, reason, maxAttempts, retryIn.Seconds())
+	return err
+}
+
+// matchSavedSearches compares the embedding of a chunk against every saved search
+// of the profile and records an alert for each search whose threshold is reached.
+// An article raises at most one alert per saved search.
+func matchSavedSearches(ctx context.Context, tx pgx.Tx, profile data.EmbeddingProfile, newsID int, vector pgvector.Vector, chunk string) (int64, error) {
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO saved_search_alerts (search_id, news_id, similarity, chunk)
+		SELECT search_id, $1, 1 - (embedding <=> $2), $3
+		FROM saved_searches
+		WHERE profile_id = $4 AND 1 - (embedding <=> $2) >= threshold
+			AND (group_id IS NULL OR group_id IN (
+				SELECT m.group_id FROM channel_group_members m
+				JOIN channel_news n ON n.channel_id = m.channel_id WHERE n.news_id = $1))
+		ON CONFLICT (search_id, news_id) DO NOTHING`, newsID, vector, chunk, profile.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to match saved searches: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
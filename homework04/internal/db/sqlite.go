@@ -0,0 +1,1208 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"rss_fetcher/internal/data"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLitePrefix selects the SQLite store in a database URL, e.g. sqlite://rss.db.
+const SQLitePrefix = "sqlite://"
+
+// sqliteSchema holds the schema versions, applied in the order of their names.
+//
+//go:embed sqlite/*.sql
+var sqliteSchema embed.FS
+
+// SQLite is the Store kept in a single SQLite file, for laptops and CI where Postgres
+// is not available. Vectors are searched exhaustively, which is fine for a few hundred
+// thousand chunks. Several services may share the file, but there are no notifications,
+// so they rely on polling.
+type SQLite struct {
+	db *sql.DB
+}
+
+var _ Store = (*SQLite)(nil)
+
+// sqliteQuery is implemented by the database and a transaction.
+type sqliteQuery interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// NewSQLite opens the database file, creating it and its schema when missing.
+func NewSQLite(ctx context.Context, path string) (*SQLite, error) {
+	// writers wait for each other instead of failing, transactions take the write lock
+	// up front, so a read followed by a write in one transaction cannot deadlock
+	dsn := "file:" + path + "?_journal_mode=WAL&_busy_timeout=10000&_foreign_keys=on&_txlock=immediate"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := upgradeSQLite(ctx, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+// upgradeSQLite applies the schema versions newer than the user_version of the file.
+// The first version creates the tables only if they do not exist, so files created
+// before the schema was versioned are upgraded as well.
+func upgradeSQLite(ctx context.Context, db *sql.DB) error {
+	files, err := fs.Glob(sqliteSchema, "sqlite/*.sql")
+	if err != nil {
+		return err
+	}
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > len(files) {
+		return fmt.Errorf("schema version %d of the file is newer than this build", version)
+	}
+
+	for i := version; i < len(files); i++ {
+		statements, err := fs.ReadFile(sqliteSchema, files[i])
+		if err != nil {
+			return err
+		}
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, string(statements)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", files[i], err)
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLite) Close() {
+	s.db.Close()
+}
+
+// now returns the current time in UTC, every timestamp is stored in UTC to compare as text.
+func (s *SQLite) now() time.Time {
+	return time.Now().UTC()
+}
+
+func (s *SQLite) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit error: %w", err)
+	}
+	return nil
+}
+
+// sqliteError reports unique constraint failures the way Postgres does, SQLite does not
+// name the violated constraint.
+func sqliteError(err error, constraint string) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return uniqueViolation(constraint)
+	}
+	return Classify(err)
+}
+
+// sqliteAffected returns a *NotFoundError when the statement changed no row.
+func sqliteAffected(result sql.Result, err error, entity string, id int) error {
+	if err != nil {
+		return Classify(err)
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return &NotFoundError{Entity: entity, ID: id}
+	}
+	return nil
+}
+
+// encodeVector stores a vector as little-endian float32 values.
+func encodeVector(vector []float32) []byte {
+	buf := make([]byte, 4*len(vector))
+	for i, value := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(value))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	vector := make([]float32, len(buf)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return vector
+}
+
+func (s *SQLite) AddChannel(ctx context.Context, link string) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, "INSERT INTO channels (link) VALUES (?) RETURNING channel_id", link).Scan(&id)
+	return id, sqliteError(err, "channels_link_key")
+}
+
+func (s *SQLite) DeleteChannel(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM channels WHERE channel_id = ?", id)
+	return sqliteAffected(result, err, "channel", id)
+}
+
+func (s *SQLite) DeleteChannels(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM channels")
+	return err
+}
+
+func (s *SQLite) UpdateChannel(ctx context.Context, channel data.Channel) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE channels SET link = ?2, name = ?3, paused = ?4, poll_interval = ?5, full_articles = ?6, headers = ?7,
+			consecutive_failures = CASE WHEN paused AND NOT ?4 THEN 0 ELSE consecutive_failures END
+		WHERE channel_id = ?1`,
+		channel.ID, channel.Link, channel.Name, channel.Paused, int64(channel.PollInterval/time.Second), channel.FullArticles,
+		encodeHeaders(channel.Headers))
+	return sqliteAffected(result, sqliteError(err, "channels_link_key"), "channel", channel.ID)
+}
+
+func (s *SQLite) MarkChannelChecked(ctx context.Context, id int) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE channels SET last_updated = ? WHERE channel_id = ?", s.now(), id)
+	return err
+}
+
+func (s *SQLite) RecordChannelFetch(ctx context.Context, fetch data.ChannelFetch, maxFailures int) (bool, error) {
+	pausedNow := false
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var wasPaused bool
+		err := tx.QueryRowContext(ctx, "SELECT paused FROM channels WHERE channel_id = ?", fetch.ChannelID).Scan(&wasPaused)
+		if errors.Is(err, sql.ErrNoRows) {
+			// the channel was deleted while its feed was fetched
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO channel_fetches (channel_id, started_at, duration_ms, status_code, bytes, items, new_items, error)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			fetch.ChannelID, fetch.StartedAt.UTC(), fetch.Duration.Milliseconds(), fetch.StatusCode, fetch.Bytes,
+			fetch.Items, fetch.NewItems, fetch.Error); err != nil {
+			return err
+		}
+
+		var paused bool
+		if err := tx.QueryRowContext(ctx, `
+			UPDATE channels SET
+				consecutive_failures = CASE WHEN ?2 THEN 0 ELSE consecutive_failures + 1 END,
+				last_success_at = CASE WHEN ?2 THEN ?3 ELSE last_success_at END,
+				paused = paused OR (NOT ?2 AND ?4 > 0 AND consecutive_failures + 1 >= ?4)
+			WHERE channel_id = ?1
+			RETURNING paused`,
+			fetch.ChannelID, fetch.Error == "", fetch.StartedAt.UTC(), maxFailures).Scan(&paused); err != nil {
+			return err
+		}
+		pausedNow = paused && !wasPaused
+
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM channel_fetches WHERE channel_id = ?1 AND fetch_id <= (
+				SELECT fetch_id FROM channel_fetches WHERE channel_id = ?1 ORDER BY fetch_id DESC LIMIT 1 OFFSET ?2)`,
+			fetch.ChannelID, fetchLogSize)
+		return err
+	})
+	return pausedNow, err
+}
+
+func (s *SQLite) LoadChannelFetches(ctx context.Context, channelID, limit int) ([]data.ChannelFetch, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+fetchColumns+" FROM channel_fetches WHERE channel_id = ? ORDER BY fetch_id DESC LIMIT ?",
+		channelID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fetches := make([]data.ChannelFetch, 0)
+	for rows.Next() {
+		fetch, err := scanFetch(rows)
+		if err != nil {
+			return nil, err
+		}
+		fetches = append(fetches, fetch)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return fetches, nil
+}
+
+func (s *SQLite) AddGroup(ctx context.Context, name string) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, "INSERT INTO channel_groups (name, created_at) VALUES (?, ?) RETURNING group_id",
+		name, s.now()).Scan(&id)
+	return id, sqliteError(err, "channel_groups_name_key")
+}
+
+func (s *SQLite) LoadGroups(ctx context.Context) ([]data.ChannelGroup, error) {
+	return s.loadGroups(ctx, &where{param: "?"})
+}
+
+func (s *SQLite) LoadGroup(ctx context.Context, id int) (*data.ChannelGroup, error) {
+	where := &where{param: "?"}
+	where.equal("g.group_id", id)
+	groups, err := s.loadGroups(ctx, where)
+	if err != nil || len(groups) == 0 {
+		return nil, err
+	}
+	return &groups[0], nil
+}
+
+func (s *SQLite) loadGroups(ctx context.Context, where *where) ([]data.ChannelGroup, error) {
+	rows, err := s.db.QueryContext(ctx, groupsQuery+where.String()+groupsOrder, where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make([]data.ChannelGroup, 0)
+	for rows.Next() {
+		var group data.ChannelGroup
+		var channelID *int
+		if err := rows.Scan(&group.ID, &group.Name, &channelID); err != nil {
+			return nil, err
+		}
+		groups = appendGroupMember(groups, group, channelID)
+	}
+	return groups, rows.Err()
+}
+
+func (s *SQLite) RenameGroup(ctx context.Context, id int, name string) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE channel_groups SET name = ? WHERE group_id = ?", name, id)
+	return sqliteAffected(result, sqliteError(err, "channel_groups_name_key"), "group", id)
+}
+
+func (s *SQLite) DeleteGroup(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM channel_groups WHERE group_id = ?", id)
+	return sqliteAffected(result, err, "group", id)
+}
+
+func (s *SQLite) AddGroupChannel(ctx context.Context, groupID, channelID int) error {
+	_, err := s.db.ExecContext(ctx, "INSERT OR IGNORE INTO channel_group_members (group_id, channel_id) VALUES (?, ?)",
+		groupID, channelID)
+	return Classify(err)
+}
+
+func (s *SQLite) DeleteGroupChannel(ctx context.Context, groupID, channelID int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM channel_group_members WHERE group_id = ? AND channel_id = ?", groupID, channelID)
+	return sqliteAffected(result, err, "member", channelID)
+}
+
+func (s *SQLite) LoadChannels(ctx context.Context, filter ChannelFilter) ([]data.Channel, error) {
+	where := filter.apply(&where{param: "?"})
+	rows, err := s.db.QueryContext(ctx, "SELECT "+channelColumns+" FROM channels"+where.String()+filter.order(), where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := make([]data.Channel, 0)
+
+	for rows.Next() {
+		channel, err := scanChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return channels, nil
+}
+
+func (s *SQLite) LoadChannel(ctx context.Context, id int) (*data.Channel, error) {
+	result, err := scanChannel(s.db.QueryRowContext(ctx, "SELECT "+channelColumns+" FROM channels WHERE channel_id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.loadNews(ctx, s.db, NewsFilter{ChannelID: result.ID})
+	if err != nil {
+		return nil, err
+	}
+	result.Items = items
+	return &result, nil
+}
+
+func (s *SQLite) AddNewsWithJob(ctx context.Context, channelID int, news data.ChannelNews) (bool, error) {
+	added := false
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if rows, err := s.loadNews(ctx, tx, NewsFilter{Link: news.Link}); err != nil {
+			return fmt.Errorf("failed to load news by link %s: %w", news.Link, err)
+		} else if len(rows) > 0 {
+			return nil
+		}
+		_, err := tx.ExecContext(ctx, "INSERT INTO channel_news (channel_id, title, link, description, author, category, pub_date, guid) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			channelID, news.Title, news.Link, news.Description, news.Author, news.Category, news.PubDate.UTC(), news.GUID)
+		if err != nil {
+			return fmt.Errorf("failed to save news item %s: %w", news.Link, sqliteError(err, "channel_news_guid_key"))
+		}
+		if err := s.addJob(ctx, tx, news.Link); err != nil {
+			return fmt.Errorf("failed to add job for news item %s: %w", news.Link, err)
+		}
+		added = true
+		return nil
+	})
+	return added, err
+}
+
+func (s *SQLite) DeleteNews(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM channel_news WHERE news_id = ?", id)
+	return sqliteAffected(result, err, "news", id)
+}
+
+func (s *SQLite) loadNews(ctx context.Context, db sqliteQuery, filter NewsFilter) ([]data.ChannelNews, error) {
+	where := &where{param: "?"}
+	query := "SELECT " + filter.columns(where) + " FROM channel_news"
+	filter.apply(where)
+	rows, err := db.QueryContext(ctx, query+where.String()+filter.order(), where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]data.ChannelNews, 0)
+
+	for rows.Next() {
+		var item data.ChannelNews
+		var pubDate *time.Time
+
+		dest := []any{&item.ID, &item.ChannelID, &item.Title, &item.Link, &item.Description, &item.Author, &item.Category, &pubDate, &item.GUID}
+		if filter.UserID != 0 {
+			item.State = &data.NewsState{}
+			dest = append(dest, &item.State.Read, &item.State.Starred)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if pubDate != nil {
+			item.PubDate = *pubDate
+		}
+		result = append(result, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *SQLite) LoadNews(ctx context.Context, filter NewsFilter) ([]data.ChannelNews, error) {
+	return s.loadNews(ctx, s.db, filter)
+}
+
+func (s *SQLite) LoadNewsByID(ctx context.Context, id int) (*data.ChannelNews, error) {
+	news, err := s.loadNews(ctx, s.db, NewsFilter{ID: id})
+	if err != nil || len(news) == 0 {
+		return nil, err
+	}
+	return &news[0], nil
+}
+
+func (s *SQLite) LoadNewsByLink(ctx context.Context, link string) (*data.ChannelNews, error) {
+	news, err := s.loadNews(ctx, s.db, NewsFilter{Link: link})
+	if err != nil || len(news) == 0 {
+		return nil, err
+	}
+	return &news[0], nil
+}
+
+func (s *SQLite) SaveNewsContent(ctx context.Context, content data.NewsContent) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO news_contents (news_id, title, byline, excerpt, site_name, image, length, html, text, fetched_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (news_id) DO UPDATE SET
+			title = excluded.title, byline = excluded.byline, excerpt = excluded.excerpt,
+			site_name = excluded.site_name, image = excluded.image, length = excluded.length,
+			html = excluded.html, text = excluded.text, fetched_at = excluded.fetched_at`,
+		content.NewsID, content.Title, content.Byline, content.Excerpt, content.SiteName, content.Image, content.Length, content.HTML, content.Text, s.now())
+	return err
+}
+
+func (s *SQLite) LoadNewsContent(ctx context.Context, newsID int) (*data.NewsContent, error) {
+	var content data.NewsContent
+	err := s.db.QueryRowContext(ctx, `
+		SELECT news_id, title, byline, excerpt, site_name, image, length, html, text, fetched_at
+		FROM news_contents WHERE news_id = ?`, newsID).Scan(
+		&content.NewsID, &content.Title, &content.Byline, &content.Excerpt, &content.SiteName, &content.Image, &content.Length, &content.HTML, &content.Text, &content.FetchedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+func (s *SQLite) SaveNewsSummary(ctx context.Context, newsID int, model, summary string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO news_summaries (news_id, model, summary, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (news_id, model) DO UPDATE SET summary = excluded.summary, created_at = excluded.created_at`,
+		newsID, model, summary, s.now())
+	return err
+}
+
+func (s *SQLite) LoadNewsSummaries(ctx context.Context, model string, newsIDs []int) (map[int]string, error) {
+	summaries := make(map[int]string)
+	if len(newsIDs) == 0 {
+		return summaries, nil
+	}
+	args := []any{model}
+	for _, id := range newsIDs {
+		args = append(args, id)
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT news_id, summary FROM news_summaries WHERE model = ? AND news_id IN (?"+
+		strings.Repeat(", ?", len(newsIDs)-1)+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var newsID int
+		var summary string
+		if err := rows.Scan(&newsID, &summary); err != nil {
+			return nil, err
+		}
+		summaries[newsID] = summary
+	}
+	return summaries, rows.Err()
+}
+
+func (s *SQLite) LoadChunkContext(ctx context.Context, newsID int) (data.ChunkContext, error) {
+	var chunkContext data.ChunkContext
+	var pubDate *time.Time
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(n.title, ''), COALESCE(c.title, ''), n.pub_date
+		FROM channel_news n JOIN channels c ON c.channel_id = n.channel_id
+		WHERE n.news_id = ?`, newsID).Scan(&chunkContext.Title, &chunkContext.Channel, &pubDate)
+	if err != nil {
+		return chunkContext, err
+	}
+	if pubDate != nil {
+		chunkContext.PubDate = *pubDate
+	}
+	return chunkContext, nil
+}
+
+func (s *SQLite) AddJob(ctx context.Context, link string) error {
+	return s.addJob(ctx, s.db, link)
+}
+
+func (s *SQLite) addJob(ctx context.Context, db sqliteQuery, link string) error {
+	now := s.now()
+	_, err := db.ExecContext(ctx, "INSERT INTO news_jobs (link, next_run_at, created_at) VALUES (?, ?, ?)", link, now, now)
+	return err
+}
+
+func (s *SQLite) DeleteJob(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM news_jobs WHERE job_id = ?", id)
+	return sqliteAffected(result, err, "job", id)
+}
+
+func (s *SQLite) LoadJobs(ctx context.Context, filter JobFilter) ([]data.NewsJob, error) {
+	where := filter.apply(&where{param: "?"})
+	rows, err := s.db.QueryContext(ctx, "SELECT "+jobColumns+" FROM news_jobs"+where.String()+filter.order(), where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]data.NewsJob, 0)
+
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// ClaimJob takes the next runnable job like Postgres does. The transaction holds the
+// write lock of the database, so concurrent claims are serialized.
+func (s *SQLite) ClaimJob(ctx context.Context, lease time.Duration, maxAttempts int) (*data.NewsJob, error) {
+	var job *data.NewsJob
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		now := s.now()
+		var id int
+		err := tx.QueryRowContext(ctx, `
+			SELECT job_id FROM news_jobs
+			WHERE (status IN ('pending', 'failed') AND next_run_at <= ?1)
+				OR (status = 'running' AND locked_until < ?1 AND attempts < ?2)
+			ORDER BY next_run_at
+			LIMIT 1`, now, maxAttempts).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		claimed, err := scanJob(tx.QueryRowContext(ctx, `
+			UPDATE news_jobs
+			SET status = 'running', attempts = attempts + 1, locked_until = ?, updated_at = ?
+			WHERE job_id = ?
+			RETURNING `+jobColumns, now.Add(lease), now, id))
+		if err != nil {
+			return err
+		}
+		job = &claimed
+		return nil
+	})
+	return job, err
+}
+
+func (s *SQLite) RenewJob(ctx context.Context, id int, lease time.Duration) error {
+	now := s.now()
+	result, err := s.db.ExecContext(ctx, "UPDATE news_jobs SET locked_until = ?, updated_at = ? WHERE job_id = ? AND status = 'running'", now.Add(lease), now, id)
+	return sqliteAffected(result, err, "job", id)
+}
+
+func (s *SQLite) CompleteJob(ctx context.Context, id int) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE news_jobs SET status = 'completed', last_error = '', locked_until = NULL, updated_at = ? WHERE job_id = ?", s.now(), id)
+	return err
+}
+
+func (s *SQLite) FailJob(ctx context.Context, id int, reason string, maxAttempts int, backoff time.Duration) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		var attempts int
+		err := tx.QueryRowContext(ctx, "SELECT attempts FROM news_jobs WHERE job_id = ?", id).Scan(&attempts)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		status := data.JobFailed
+		if attempts >= maxAttempts {
+			status = data.JobDead
+		}
+		now := s.now()
+		_, err = tx.ExecContext(ctx, `
+			UPDATE news_jobs
+			SET status = ?, last_error = ?, next_run_at = ?, locked_until = NULL, updated_at = ?
+			WHERE job_id = ?`, status, reason, now.Add(backoff<<max(attempts-1, 0)), now, id)
+		return err
+	})
+}
+
+func (s *SQLite) ReapJobs(ctx context.Context, maxAttempts int) (int64, error) {
+	now := s.now()
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE news_jobs
+		SET status = 'dead', last_error = 'lease expired', locked_until = NULL, updated_at = ?
+		WHERE status = 'running' AND locked_until < ? AND attempts >= ?`, now, now, maxAttempts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *SQLite) AddProfile(ctx context.Context, profile data.EmbeddingProfile) (*data.EmbeddingProfile, error) {
+	now := s.now()
+	var activatedAt *time.Time
+	if profile.Status == data.ProfileActive {
+		activatedAt = &now
+	}
+	index := profile.Index
+	added, err := scanProfile(s.db.QueryRowContext(ctx, `
+		INSERT INTO embedding_profiles (name, model, dims, status, chunker, contextual, created_at, activated_at,
+			index_type, ivfflat_lists, ivfflat_probes, hnsw_m, hnsw_ef_construction, hnsw_ef_search)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING `+profileColumns,
+		profile.Name, profile.Model, profile.Dims, string(profile.Status), profile.Chunker, profile.Contextual, now, activatedAt,
+		string(index.Type), index.Lists, index.Probes, index.M, index.EfConstruction, index.EfSearch))
+	if err != nil {
+		return nil, sqliteError(err, "embedding_profiles_name_key")
+	}
+	return &added, nil
+}
+
+func (s *SQLite) LoadProfiles(ctx context.Context, statuses ...data.ProfileStatus) ([]data.EmbeddingProfile, error) {
+	query := "SELECT " + profileColumns + " FROM embedding_profiles"
+	args := make([]any, len(statuses))
+	if len(statuses) > 0 {
+		for i, status := range statuses {
+			args[i] = string(status)
+		}
+		query += " WHERE status IN (?" + strings.Repeat(", ?", len(statuses)-1) + ")"
+	}
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY profile_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := make([]data.EmbeddingProfile, 0)
+
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+func (s *SQLite) LoadActiveProfile(ctx context.Context) (*data.EmbeddingProfile, error) {
+	profile, err := scanProfile(s.db.QueryRowContext(ctx, "SELECT "+profileColumns+" FROM embedding_profiles WHERE status = 'active'"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (s *SQLite) LoadProfileByName(ctx context.Context, name string) (*data.EmbeddingProfile, error) {
+	profile, err := scanProfile(s.db.QueryRowContext(ctx, "SELECT "+profileColumns+" FROM embedding_profiles WHERE name = ?", name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (s *SQLite) PruneRetiredProfiles(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM embedding_profiles WHERE status = 'retired'")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *SQLite) ActivateProfile(ctx context.Context, profile data.EmbeddingProfile, searchEmbeddings map[int][]float32) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "UPDATE embedding_profiles SET status = 'retired' WHERE status = 'active'"); err != nil {
+			return fmt.Errorf("failed to retire active profile: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE embedding_profiles SET status = 'active', activated_at = ? WHERE profile_id = ?", s.now(), profile.ID); err != nil {
+			return fmt.Errorf("failed to activate profile: %w", err)
+		}
+
+		for searchID, embedding := range searchEmbeddings {
+			if _, err := tx.ExecContext(ctx, "UPDATE saved_searches SET embedding = ?, profile_id = ? WHERE search_id = ?",
+				encodeVector(embedding), profile.ID, searchID); err != nil {
+				return fmt.Errorf("failed to update saved search %d: %w", searchID, err)
+			}
+		}
+		var stale int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM saved_searches WHERE profile_id <> ?", profile.ID).Scan(&stale); err != nil {
+			return err
+		}
+		if stale > 0 {
+			return fmt.Errorf("%d saved searches were added during reindexing, run it again", stale)
+		}
+		return nil
+	})
+}
+
+func (s *SQLite) SaveEmbeddings(ctx context.Context, profile data.EmbeddingProfile, docID int, embedding []float32, metadata map[string]interface{}) error {
+	content, _ := metadata["content"].(string)
+	return s.saveEmbedding(ctx, s.db, profile, docID, embedding, content)
+}
+
+func (s *SQLite) saveEmbedding(ctx context.Context, db sqliteQuery, profile data.EmbeddingProfile, newsID int, embedding []float32, content string) error {
+	if len(embedding) != profile.Dims {
+		return fmt.Errorf("embedding length %d does not match profile %s dimension %d", len(embedding), profile.Name, profile.Dims)
+	}
+	_, err := db.ExecContext(ctx, "INSERT INTO news_embeddings (news_id, profile_id, model, dims, chunker, embedding, content) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		newsID, profile.ID, profile.Model, profile.Dims, profile.Chunker, encodeVector(embedding), content)
+	if err != nil {
+		return fmt.Errorf("failed to insert document: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLite) InsertDocument(ctx context.Context, profile data.EmbeddingProfile, docID int, content string, embedding []float32) error {
+	return s.saveEmbedding(ctx, s.db, profile, docID, embedding, content)
+}
+
+// IndexArticle atomically replaces the chunks of an article in the profile. Saved searches of
+// an active profile are compared with every chunk in Go, as SQLite has no vector functions.
+func (s *SQLite) IndexArticle(ctx context.Context, profile data.EmbeddingProfile, newsID int, chunks []string, embeddings [][]float32) (int64, error) {
+	if len(chunks) != len(embeddings) {
+		return 0, fmt.Errorf("got %d embeddings for %d chunks", len(embeddings), len(chunks))
+	}
+
+	var alerts int64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM news_embeddings WHERE news_id = ? AND profile_id = ?", newsID, profile.ID); err != nil {
+			return fmt.Errorf("failed to delete previous embeddings: %w", err)
+		}
+		for i, chunk := range chunks {
+			if err := s.saveEmbedding(ctx, tx, profile, newsID, embeddings[i], chunk); err != nil {
+				return err
+			}
+		}
+		if profile.Status != data.ProfileActive {
+			return nil
+		}
+		matched, err := s.matchSavedSearches(ctx, tx, profile, newsID, chunks, embeddings)
+		alerts = matched
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return alerts, nil
+}
+
+// matchSavedSearches records an alert for every search of the profile the article reaches
+// the threshold of, with the most similar chunk. An article raises at most one alert per search.
+func (s *SQLite) matchSavedSearches(ctx context.Context, tx *sql.Tx, profile data.EmbeddingProfile, newsID int, chunks []string, embeddings [][]float32) (int64, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT search_id, embedding, threshold FROM saved_searches
+		WHERE profile_id = ? AND (group_id IS NULL OR group_id IN (
+			SELECT m.group_id FROM channel_group_members m
+			JOIN channel_news n ON n.channel_id = m.channel_id WHERE n.news_id = ?))`, profile.ID, newsID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to match saved searches: %w", err)
+	}
+	defer rows.Close()
+
+	type match struct {
+		searchID   int
+		similarity float64
+		chunk      string
+	}
+	matches := make([]match, 0)
+	for rows.Next() {
+		var searchID int
+		var embedding []byte
+		var threshold float64
+		if err := rows.Scan(&searchID, &embedding, &threshold); err != nil {
+			return 0, err
+		}
+		search := decodeVector(embedding)
+		best := match{searchID: searchID, similarity: -1}
+		for i, chunk := range chunks {
+			if similarity := Cosine(search, embeddings[i]); similarity > best.similarity {
+				best.similarity, best.chunk = similarity, chunk
+			}
+		}
+		if best.similarity >= threshold {
+			matches = append(matches, best)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	var alerts int64
+	now := s.now()
+	for _, m := range matches {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO saved_search_alerts (search_id, news_id, similarity, chunk, next_attempt_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (search_id, news_id) DO NOTHING`, m.searchID, newsID, m.similarity, m.chunk, now, now)
+		if err != nil {
+			return 0, fmt.Errorf("failed to match saved searches: %w", err)
+		}
+		added, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		alerts += added
+	}
+	return alerts, nil
+}
+
+// QueryRelevantDocuments compares the query with every vector of the profile.
+func (s *SQLite) QueryRelevantDocuments(ctx context.Context, profile data.EmbeddingProfile, embedding []float32, backend string, limit int, scope DocumentScope) ([]Document, error) {
+	if backend != "ollama" {
+		return nil, fmt.Errorf("unsupported backend: %s", backend)
+	}
+	if len(embedding) != profile.Dims {
+		return nil, fmt.Errorf("query embedding length %d does not match profile %s dimension %d", len(embedding), profile.Name, profile.Dims)
+	}
+
+	where := &where{param: "?"}
+	where.equal("profile_id", profile.ID)
+	scope.apply(where)
+	rows, err := s.db.QueryContext(ctx, "SELECT news_id, embedding, content FROM news_embeddings"+where.String(), where.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query relevant documents: %w", err)
+	}
+	defer rows.Close()
+
+	docs := make([]Document, 0)
+	for rows.Next() {
+		var newsID int
+		var vector []byte
+		var content string
+		if err := rows.Scan(&newsID, &vector, &content); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		docs = append(docs, Document{
+			ID:         strconv.Itoa(newsID),
+			NewsID:     newsID,
+			Metadata:   map[string]interface{}{"content": content},
+			Similarity: Cosine(decodeVector(vector), embedding),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(docs, func(a, b Document) int { return cmp.Compare(b.Similarity, a.Similarity) })
+	return docs[:min(limit, len(docs))], nil
+}
+
+func (s *SQLite) LoadUnindexedNews(ctx context.Context, source, target data.EmbeddingProfile) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT news_id FROM news_embeddings s
+		WHERE s.profile_id = ?
+			AND NOT EXISTS (SELECT 1 FROM news_embeddings t WHERE t.profile_id = ? AND t.news_id = s.news_id)
+		ORDER BY news_id`, source.ID, target.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (s *SQLite) LoadChunks(ctx context.Context, profile data.EmbeddingProfile, newsID int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT content FROM news_embeddings WHERE profile_id = ? AND news_id = ? ORDER BY embedding_id", profile.ID, newsID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chunks := make([]string, 0)
+	for rows.Next() {
+		var chunk string
+		if err := rows.Scan(&chunk); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, rows.Err()
+}
+
+func (s *SQLite) CountVectors(ctx context.Context, profile data.EmbeddingProfile) (int64, error) {
+	var rows int64
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM news_embeddings WHERE profile_id = ?", profile.ID).Scan(&rows)
+	return rows, err
+}
+
+// CreateProfileIndex does nothing, vectors are always searched exhaustively.
+func (s *SQLite) CreateProfileIndex(_ context.Context, _ data.EmbeddingProfile) error {
+	return nil
+}
+
+// RebuildProfileIndex only stores the settings in the profile.
+func (s *SQLite) RebuildProfileIndex(ctx context.Context, profile data.EmbeddingProfile, settings data.IndexSettings) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE embedding_profiles
+		SET index_type = ?, ivfflat_lists = ?, ivfflat_probes = ?, hnsw_m = ?, hnsw_ef_construction = ?, hnsw_ef_search = ?
+		WHERE profile_id = ?`,
+		string(settings.Type), settings.Lists, settings.Probes, settings.M, settings.EfConstruction, settings.EfSearch, profile.ID)
+	return err
+}
+
+func (s *SQLite) UpdateSearchSettings(ctx context.Context, profile data.EmbeddingProfile, probes, efSearch int) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE embedding_profiles SET ivfflat_probes = ?, hnsw_ef_search = ? WHERE profile_id = ?", probes, efSearch, profile.ID)
+	return err
+}
+
+func (s *SQLite) LoadIndexStats(ctx context.Context) ([]IndexStats, error) {
+	profiles, err := s.LoadProfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]IndexStats, 0, len(profiles))
+	for _, profile := range profiles {
+		rows, err := s.CountVectors(ctx, profile)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, IndexStats{
+			Profile:          profile,
+			IndexName:        profileIndexName(profile),
+			Exists:           true,
+			Valid:            true,
+			Rows:             rows,
+			RecommendedLists: RecommendedLists(rows),
+		})
+	}
+	return stats, nil
+}
+
+func (s *SQLite) AddSavedSearch(ctx context.Context, search data.SavedSearch, profile data.EmbeddingProfile, embedding []float32) (int, error) {
+	if len(embedding) != profile.Dims {
+		return 0, fmt.Errorf("embedding length %d does not match profile %s dimension %d", len(embedding), profile.Name, profile.Dims)
+	}
+	var id int
+	err := s.db.QueryRowContext(ctx, "INSERT INTO saved_searches (name, query, embedding, threshold, webhook_url, secret, profile_id, group_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING search_id",
+		search.Name, search.Query, encodeVector(embedding), search.Threshold, search.WebhookURL, search.Secret, profile.ID, optionalID(search.GroupID), s.now()).Scan(&id)
+	return id, Classify(err)
+}
+
+func (s *SQLite) DeleteSavedSearch(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM saved_searches WHERE search_id = ?", id)
+	return sqliteAffected(result, err, "search", id)
+}
+
+func (s *SQLite) LoadSavedSearches(ctx context.Context) ([]data.SavedSearch, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT search_id, name, query, threshold, webhook_url, secret, COALESCE(group_id, 0), created_at FROM saved_searches ORDER BY search_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := make([]data.SavedSearch, 0)
+
+	for rows.Next() {
+		var search data.SavedSearch
+		if err := rows.Scan(&search.ID, &search.Name, &search.Query, &search.Threshold, &search.WebhookURL, &search.Secret, &search.GroupID, &search.CreatedAt); err != nil {
+			return nil, err
+		}
+		searches = append(searches, search)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return searches, nil
+}
+
+// ClaimAlerts leases alerts like Postgres does. The transaction holds the write
+// lock of the database, so concurrent claims are serialized.
+func (s *SQLite) ClaimAlerts(ctx context.Context, lease time.Duration, limit int) ([]data.SearchAlert, error) {
+	var alerts []data.SearchAlert
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		now := s.now()
+		rows, err := tx.QueryContext(ctx, `
+			SELECT a.alert_id, a.similarity, a.chunk, a.status, a.attempts, a.last_error, a.created_at,
+				s.search_id, s.name, s.query, s.threshold, s.webhook_url, s.secret, s.created_at,
+				n.news_id, n.title, n.link, n.description, n.author, n.category, n.pub_date, n.guid
+			FROM saved_search_alerts a
+			JOIN saved_searches s ON s.search_id = a.search_id
+			JOIN channel_news n ON n.news_id = a.news_id
+			WHERE a.status = 0 AND a.next_attempt_at <= ?1
+				AND (a.locked_until IS NULL OR a.locked_until < ?1)
+			ORDER BY a.alert_id
+			LIMIT ?2`, now, limit)
+		if err != nil {
+			return err
+		}
+		alerts, err = scanAlerts(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		for _, alert := range alerts {
+			if _, err := tx.ExecContext(ctx, "UPDATE saved_search_alerts SET locked_until = ? WHERE alert_id = ?", now.Add(lease), alert.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return alerts, err
+}
+
+func (s *SQLite) MarkAlertDelivered(ctx context.Context, id int) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE saved_search_alerts SET status = 1, attempts = attempts + 1, last_error = '', locked_until = NULL WHERE alert_id = ?", id)
+	return err
+}
+
+func (s *SQLite) MarkAlertFailed(ctx context.Context, id int, reason string, retryIn time.Duration, maxAttempts int) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE saved_search_alerts
+		SET attempts = attempts + 1,
+			last_error = ?,
+			status = CASE WHEN attempts + 1 >= ? THEN 2 ELSE 0 END,
+			next_attempt_at = ?,
+			locked_until = NULL
+		WHERE alert_id = ?`, reason, maxAttempts, s.now().Add(retryIn), id)
+	return err
+}
+
+func (s *SQLite) AddAPIKey(ctx context.Context, key data.APIKey) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, "INSERT INTO api_keys (name, prefix, key_hash, scope, user_id, created_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING key_id",
+		key.Name, key.Prefix, key.Hash, key.Scope, optionalID(key.UserID), s.now()).Scan(&id)
+	return id, sqliteError(err, "api_keys_name_key")
+}
+
+func (s *SQLite) LoadAPIKeys(ctx context.Context) ([]data.APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY key_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]data.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (s *SQLite) LoadAPIKeyByHash(ctx context.Context, hash []byte) (*data.APIKey, error) {
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = ?", hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *SQLite) DeleteAPIKey(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM api_keys WHERE key_id = ?", id)
+	return sqliteAffected(result, err, "key", id)
+}
+
+func (s *SQLite) MarkAPIKeyUsed(ctx context.Context, id int) error {
+	now := s.now()
+	_, err := s.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = ? WHERE key_id = ? AND (last_used_at IS NULL OR last_used_at < ?)",
+		now, id, now.Add(-keyUsageInterval))
+	return err
+}
+
+func (s *SQLite) AddUser(ctx context.Context, name string) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, "INSERT INTO users (name, created_at) VALUES (?, ?) RETURNING user_id", name, s.now()).Scan(&id)
+	return id, sqliteError(err, "users_name_key")
+}
+
+func (s *SQLite) LoadUsers(ctx context.Context) ([]data.User, error) {
+	return s.loadUsers(ctx, &where{param: "?"})
+}
+
+func (s *SQLite) LoadUser(ctx context.Context, id int) (*data.User, error) {
+	where := &where{param: "?"}
+	where.equal("u.user_id", id)
+	return firstUser(s.loadUsers(ctx, where))
+}
+
+func (s *SQLite) LoadUserByName(ctx context.Context, name string) (*data.User, error) {
+	where := &where{param: "?"}
+	where.equal("u.name", name)
+	return firstUser(s.loadUsers(ctx, where))
+}
+
+func (s *SQLite) loadUsers(ctx context.Context, where *where) ([]data.User, error) {
+	rows, err := s.db.QueryContext(ctx, usersQuery+where.String()+usersOrder, where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]data.User, 0)
+	for rows.Next() {
+		var user data.User
+		var channelID *int
+		if err := rows.Scan(&user.ID, &user.Name, &user.CreatedAt, &channelID); err != nil {
+			return nil, err
+		}
+		users = appendSubscription(users, user, channelID)
+	}
+	return users, rows.Err()
+}
+
+func (s *SQLite) DeleteUser(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM users WHERE user_id = ?", id)
+	return sqliteAffected(result, err, "user", id)
+}
+
+func (s *SQLite) AddSubscription(ctx context.Context, userID, channelID int) error {
+	_, err := s.db.ExecContext(ctx, "INSERT OR IGNORE INTO subscriptions (user_id, channel_id, created_at) VALUES (?, ?, ?)",
+		userID, channelID, s.now())
+	return Classify(err)
+}
+
+func (s *SQLite) DeleteSubscription(ctx context.Context, userID, channelID int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM subscriptions WHERE user_id = ? AND channel_id = ?", userID, channelID)
+	return sqliteAffected(result, err, "subscription", channelID)
+}
+
+func (s *SQLite) SaveNewsState(ctx context.Context, userID, newsID int, state data.NewsState) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO news_states (user_id, news_id, read, starred, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, news_id) DO UPDATE SET
+			read = excluded.read, starred = excluded.starred, updated_at = excluded.updated_at`,
+		userID, newsID, state.Read, state.Starred, s.now())
+	return Classify(err)
+}
@@ -0,0 +1,111 @@
+-- Schema of the SQLite store, it follows the Postgres migrations. Later changes are
+-- separate files applied in order, the version is kept in PRAGMA user_version.
+-- Timestamps are written by the store in UTC, so they compare as text.
+-- Vectors are little-endian float32 blobs searched exhaustively.
+CREATE TABLE IF NOT EXISTS channels (
+    channel_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    link TEXT NOT NULL UNIQUE,
+    title TEXT DEFAULT '',
+    description TEXT DEFAULT '',
+    rss_link TEXT DEFAULT '',
+    last_updated timestamp NOT NULL DEFAULT '1970-01-01 00:00:00+00:00'
+);
+
+CREATE TABLE IF NOT EXISTS channel_news (
+    news_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    channel_id INTEGER NOT NULL REFERENCES channels(channel_id) ON DELETE CASCADE,
+    link TEXT NOT NULL UNIQUE,
+    title TEXT DEFAULT '',
+    description TEXT DEFAULT '',
+    author TEXT DEFAULT '',
+    category TEXT DEFAULT '',
+    pub_date timestamp,
+    guid TEXT NOT NULL UNIQUE
+);
+CREATE INDEX IF NOT EXISTS channel_news_pub_date_idx ON channel_news(pub_date);
+
+CREATE TABLE IF NOT EXISTS news_contents (
+    news_id INTEGER PRIMARY KEY REFERENCES channel_news(news_id) ON DELETE CASCADE,
+    title TEXT NOT NULL DEFAULT '',
+    byline TEXT NOT NULL DEFAULT '',
+    excerpt TEXT NOT NULL DEFAULT '',
+    site_name TEXT NOT NULL DEFAULT '',
+    image TEXT NOT NULL DEFAULT '',
+    length INTEGER NOT NULL DEFAULT 0,
+    html TEXT NOT NULL DEFAULT '',
+    text TEXT NOT NULL DEFAULT '',
+    fetched_at timestamp NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS news_jobs (
+    job_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    link TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_run_at timestamp NOT NULL,
+    locked_until timestamp,
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL DEFAULT '1970-01-01 00:00:00+00:00'
+);
+CREATE INDEX IF NOT EXISTS news_jobs_runnable_idx ON news_jobs(next_run_at) WHERE status IN ('pending', 'failed');
+CREATE INDEX IF NOT EXISTS news_jobs_running_idx ON news_jobs(locked_until) WHERE status = 'running';
+
+CREATE TABLE IF NOT EXISTS embedding_profiles (
+    profile_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    model TEXT NOT NULL,
+    dims INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'building' CHECK (status IN ('building', 'active', 'retired')),
+    chunker TEXT NOT NULL,
+    contextual BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at timestamp NOT NULL,
+    activated_at timestamp,
+    index_type TEXT NOT NULL DEFAULT 'hnsw' CHECK (index_type IN ('ivfflat', 'hnsw')),
+    ivfflat_lists INTEGER NOT NULL DEFAULT 100,
+    ivfflat_probes INTEGER NOT NULL DEFAULT 10,
+    hnsw_m INTEGER NOT NULL DEFAULT 16,
+    hnsw_ef_construction INTEGER NOT NULL DEFAULT 64,
+    hnsw_ef_search INTEGER NOT NULL DEFAULT 40
+);
+-- only one profile serves queries at a time
+CREATE UNIQUE INDEX IF NOT EXISTS embedding_profiles_active_idx ON embedding_profiles(status) WHERE status = 'active';
+
+CREATE TABLE IF NOT EXISTS news_embeddings (
+    embedding_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    news_id INTEGER NOT NULL REFERENCES channel_news(news_id) ON DELETE CASCADE,
+    profile_id INTEGER NOT NULL REFERENCES embedding_profiles(profile_id) ON DELETE CASCADE,
+    model TEXT NOT NULL,
+    dims INTEGER NOT NULL,
+    chunker TEXT NOT NULL,
+    embedding BLOB NOT NULL CHECK (length(embedding) = 4 * dims),
+    content TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS news_embeddings_profile_news_idx ON news_embeddings(profile_id, news_id);
+
+CREATE TABLE IF NOT EXISTS saved_searches (
+    search_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL DEFAULT '',
+    query TEXT NOT NULL,
+    embedding BLOB NOT NULL,
+    threshold REAL NOT NULL DEFAULT 0.75,
+    webhook_url TEXT NOT NULL,
+    secret TEXT NOT NULL DEFAULT '',
+    profile_id INTEGER NOT NULL REFERENCES embedding_profiles(profile_id),
+    created_at timestamp NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS saved_search_alerts (
+    alert_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    search_id INTEGER NOT NULL REFERENCES saved_searches(search_id) ON DELETE CASCADE,
+    news_id INTEGER NOT NULL REFERENCES channel_news(news_id) ON DELETE CASCADE,
+    similarity REAL NOT NULL,
+    chunk TEXT NOT NULL DEFAULT '',
+    status INTEGER NOT NULL DEFAULT 0, -- 0: pending, 1: delivered, 2: failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at timestamp NOT NULL,
+    created_at timestamp NOT NULL,
+    UNIQUE (search_id, news_id)
+);
+CREATE INDEX IF NOT EXISTS saved_search_alerts_pending_idx ON saved_search_alerts(next_attempt_at) WHERE status = 0;
@@ -0,0 +1,5 @@
+ALTER TABLE channels ADD COLUMN name TEXT NOT NULL DEFAULT '';
+ALTER TABLE channels ADD COLUMN paused BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE channels ADD COLUMN poll_interval INTEGER NOT NULL DEFAULT 0; -- seconds, 0 uses the interval of channel-service
+ALTER TABLE channels ADD COLUMN full_articles BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE channels ADD COLUMN headers TEXT NOT NULL DEFAULT '{}'; -- JSON object
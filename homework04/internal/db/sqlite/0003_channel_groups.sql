@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS channel_groups (
+    group_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    created_at timestamp NOT NULL
+);
+CREATE TABLE IF NOT EXISTS channel_group_members (
+    group_id INTEGER NOT NULL REFERENCES channel_groups(group_id) ON DELETE CASCADE,
+    channel_id INTEGER NOT NULL REFERENCES channels(channel_id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, channel_id)
+);
+CREATE INDEX IF NOT EXISTS channel_group_members_channel_idx ON channel_group_members(channel_id);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS news_summaries (
+    news_id INTEGER NOT NULL REFERENCES channel_news(news_id) ON DELETE CASCADE,
+    model TEXT NOT NULL,
+    summary TEXT NOT NULL,
+    created_at timestamp NOT NULL,
+    PRIMARY KEY (news_id, model)
+);
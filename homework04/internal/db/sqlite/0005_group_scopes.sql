@@ -0,0 +1 @@
+ALTER TABLE saved_searches ADD COLUMN group_id INTEGER REFERENCES channel_groups(group_id) ON DELETE CASCADE;
@@ -0,0 +1,14 @@
+ALTER TABLE channels ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE channels ADD COLUMN last_success_at timestamp NULL;
+CREATE TABLE IF NOT EXISTS channel_fetches (
+    fetch_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    channel_id INTEGER NOT NULL REFERENCES channels(channel_id) ON DELETE CASCADE,
+    started_at timestamp NOT NULL,
+    duration_ms INTEGER NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0, -- 0 when no response was received
+    bytes INTEGER NOT NULL DEFAULT 0,
+    items INTEGER NOT NULL DEFAULT 0,
+    new_items INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '' -- empty on success
+);
+CREATE INDEX IF NOT EXISTS channel_fetches_channel_idx ON channel_fetches(channel_id, fetch_id);
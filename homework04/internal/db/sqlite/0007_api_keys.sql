@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    key_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    prefix TEXT NOT NULL,
+    key_hash BLOB NOT NULL UNIQUE,
+    scope TEXT NOT NULL CHECK (scope IN ('read', 'admin')),
+    created_at timestamp NOT NULL,
+    last_used_at timestamp NULL
+);
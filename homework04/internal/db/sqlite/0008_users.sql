@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS users (
+    user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    created_at timestamp NOT NULL
+);
+CREATE TABLE IF NOT EXISTS subscriptions (
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    channel_id INTEGER NOT NULL REFERENCES channels(channel_id) ON DELETE CASCADE,
+    created_at timestamp NOT NULL,
+    PRIMARY KEY (user_id, channel_id)
+);
+CREATE INDEX IF NOT EXISTS subscriptions_channel_idx ON subscriptions(channel_id);
+CREATE TABLE IF NOT EXISTS news_states (
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    news_id INTEGER NOT NULL REFERENCES channel_news(news_id) ON DELETE CASCADE,
+    read BOOLEAN NOT NULL DEFAULT FALSE,
+    starred BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at timestamp NOT NULL,
+    PRIMARY KEY (user_id, news_id)
+);
+CREATE INDEX IF NOT EXISTS news_states_news_idx ON news_states(news_id);
+ALTER TABLE api_keys ADD COLUMN user_id INTEGER REFERENCES users(user_id) ON DELETE CASCADE;
@@ -0,0 +1 @@
+ALTER TABLE saved_search_alerts ADD COLUMN locked_until timestamp NULL;
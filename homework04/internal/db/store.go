@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"rss_fetcher/internal/data"
+	"strings"
+	"time"
+)
+
+// Open connects to the store of the database URL: sqlite://path opens a SQLite file,
+// anything else is a Postgres connection string.
+func Open(ctx context.Context, url string) (Store, error) {
+	if path, ok := strings.CutPrefix(url, SQLitePrefix); ok {
+		store, err := NewSQLite(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+	store, err := NewPostgres(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Store is the data access layer of the services. Every method takes a context, so a
+// cancelled HTTP request or a service shutdown stops the queries it started.
+// Postgres is the production implementation, SQLite serves single-node setups and
+// Memory keeps everything in process.
+//
+// All of them return the errors of errors.go: updates and deletes of missing rows fail
+// with a *NotFoundError, violated constraints with a *ConstraintError.
+type Store interface {
+	ChannelStore
+	GroupStore
+	NewsStore
+	JobStore
+	EmbeddingStore
+	SearchStore
+	KeyStore
+	UserStore
+	Close()
+}
+
+type ChannelStore interface {
+	// AddChannel stores a new channel and returns its id, ErrConflict when the link is stored already.
+	AddChannel(ctx context.Context, link string) (int, error)
+	// DeleteChannel deletes the channel with its news, ErrNotFound when there is none.
+	DeleteChannel(ctx context.Context, id int) error
+	DeleteChannels(ctx context.Context) error
+	LoadChannels(ctx context.Context, filter ChannelFilter) ([]data.Channel, error)
+	// LoadChannel returns the channel with its news, nil if there is none.
+	LoadChannel(ctx context.Context, id int) (*data.Channel, error)
+	// UpdateChannel stores the link and the settings of the channel (name, paused,
+	// poll interval, full articles and headers). Resuming a channel resets its failures.
+	UpdateChannel(ctx context.Context, channel data.Channel) error
+	// MarkChannelChecked records that the feed of the channel was just checked.
+	MarkChannelChecked(ctx context.Context, id int) error
+	// RecordChannelFetch logs a fetch attempt and updates the failures of the channel, which
+	// is paused after maxFailures consecutive failures (never when 0). Returns true when the
+	// channel was paused by this fetch.
+	RecordChannelFetch(ctx context.Context, fetch data.ChannelFetch, maxFailures int) (bool, error)
+	// LoadChannelFetches returns the latest fetch attempts of the channel, newest first.
+	LoadChannelFetches(ctx context.Context, channelID, limit int) ([]data.ChannelFetch, error)
+}
+
+type GroupStore interface {
+	// AddGroup creates an empty group and returns its id.
+	AddGroup(ctx context.Context, name string) (int, error)
+	// LoadGroups returns all groups with their members, ordered by name.
+	LoadGroups(ctx context.Context) ([]data.ChannelGroup, error)
+	// LoadGroup returns the group with its members, nil if there is none.
+	LoadGroup(ctx context.Context, id int) (*data.ChannelGroup, error)
+	// RenameGroup changes the name of the group, members are kept.
+	RenameGroup(ctx context.Context, id int, name string) error
+	// DeleteGroup deletes the group and the saved searches scoped to it, its channels are kept.
+	DeleteGroup(ctx context.Context, id int) error
+	// AddGroupChannel makes the channel a member of the group, members are added once.
+	// ErrReference when the group or the channel does not exist.
+	AddGroupChannel(ctx context.Context, groupID, channelID int) error
+	// DeleteGroupChannel takes the channel out of the group, ErrNotFound when it is not a member.
+	DeleteGroupChannel(ctx context.Context, groupID, channelID int) error
+}
+
+type NewsStore interface {
+	// AddNewsWithJob atomically stores a news item of the channel together with the job
+	// indexing it. Returns false when a news item with the same link already exists.
+	AddNewsWithJob(ctx context.Context, channelID int, news data.ChannelNews) (bool, error)
+	DeleteNews(ctx context.Context, id int) error
+	// LoadNews returns the news matching the filter, in the order and page it asks for.
+	LoadNews(ctx context.Context, filter NewsFilter) ([]data.ChannelNews, error)
+	// LoadNewsByID returns the news with the id, nil if there is none.
+	LoadNewsByID(ctx context.Context, id int) (*data.ChannelNews, error)
+	// LoadNewsByLink returns the news with the link, nil if there is none.
+	LoadNewsByLink(ctx context.Context, link string) (*data.ChannelNews, error)
+	SaveNewsContent(ctx context.Context, content data.NewsContent) error
+	// LoadNewsContent returns the stored readable version of an article, nil if it was not fetched yet.
+	LoadNewsContent(ctx context.Context, newsID int) (*data.NewsContent, error)
+	// SaveNewsSummary stores the summary of an article written by the model, replacing the previous one.
+	SaveNewsSummary(ctx context.Context, newsID int, model, summary string) error
+	// LoadNewsSummaries returns the summaries written by the model for the news, by news id.
+	LoadNewsSummaries(ctx context.Context, model string, newsIDs []int) (map[int]string, error)
+	LoadChunkContext(ctx context.Context, newsID int) (data.ChunkContext, error)
+}
+
+type JobStore interface {
+	AddJob(ctx context.Context, link string) error
+	// DeleteJob deletes the job, ErrNotFound when there is none.
+	DeleteJob(ctx context.Context, id int) error
+	LoadJobs(ctx context.Context, filter JobFilter) ([]data.NewsJob, error)
+	// ClaimJob leases the next runnable job to the caller, nil when there is nothing to do.
+	// Jobs whose lease expired are taken over only while they have attempts left.
+	ClaimJob(ctx context.Context, lease time.Duration, maxAttempts int) (*data.NewsJob, error)
+	// RenewJob extends the lease of a running job, ErrNotFound when the job is not running.
+	RenewJob(ctx context.Context, id int, lease time.Duration) error
+	CompleteJob(ctx context.Context, id int) error
+	// FailJob records a failed attempt and schedules a retry after an exponential
+	// backoff, or declares the job dead once maxAttempts attempts have been made.
+	FailJob(ctx context.Context, id int, reason string, maxAttempts int, backoff time.Duration) error
+	// ReapJobs buries running jobs whose lease expired after the last allowed attempt.
+	ReapJobs(ctx context.Context, maxAttempts int) (int64, error)
+}
+
+type EmbeddingStore interface {
+	AddProfile(ctx context.Context, profile data.EmbeddingProfile) (*data.EmbeddingProfile, error)
+	// LoadProfiles returns the profiles with any of the given statuses, or all profiles when none is given.
+	LoadProfiles(ctx context.Context, statuses ...data.ProfileStatus) ([]data.EmbeddingProfile, error)
+	// LoadActiveProfile returns the profile serving queries, nil if there is none.
+	LoadActiveProfile(ctx context.Context) (*data.EmbeddingProfile, error)
+	// LoadProfileByName returns the profile with the name, nil if there is none.
+	LoadProfileByName(ctx context.Context, name string) (*data.EmbeddingProfile, error)
+	PruneRetiredProfiles(ctx context.Context) (int64, error)
+	// ActivateProfile switches queries and saved searches to the profile and retires the active one.
+	ActivateProfile(ctx context.Context, profile data.EmbeddingProfile, searchEmbeddings map[int][]float32) error
+
+	// IndexArticle atomically replaces the chunks of an article in the profile and
+	// matches them against saved searches when the profile is active.
+	IndexArticle(ctx context.Context, profile data.EmbeddingProfile, newsID int, chunks []string, embeddings [][]float32) (int64, error)
+	QueryRelevantDocuments(ctx context.Context, profile data.EmbeddingProfile, embedding []float32, backend string, limit int, scope DocumentScope) ([]Document, error)
+	LoadUnindexedNews(ctx context.Context, source, target data.EmbeddingProfile) ([]int, error)
+	LoadChunks(ctx context.Context, profile data.EmbeddingProfile, newsID int) ([]string, error)
+	CountVectors(ctx context.Context, profile data.EmbeddingProfile) (int64, error)
+
+	CreateProfileIndex(ctx context.Context, profile data.EmbeddingProfile) error
+	RebuildProfileIndex(ctx context.Context, profile data.EmbeddingProfile, settings data.IndexSettings) error
+	UpdateSearchSettings(ctx context.Context, profile data.EmbeddingProfile, probes, efSearch int) error
+	LoadIndexStats(ctx context.Context) ([]IndexStats, error)
+}
+
+type SearchStore interface {
+	// AddSavedSearch stores the search and returns its id, ErrReference when its group does not exist.
+	AddSavedSearch(ctx context.Context, search data.SavedSearch, profile data.EmbeddingProfile, embedding []float32) (int, error)
+	DeleteSavedSearch(ctx context.Context, id int) error
+	LoadSavedSearches(ctx context.Context) ([]data.SavedSearch, error)
+	// ClaimAlerts leases up to limit alerts waiting for delivery whose retry time has come.
+	// Leased alerts are not claimed again until they are marked or their lease expires.
+	ClaimAlerts(ctx context.Context, lease time.Duration, limit int) ([]data.SearchAlert, error)
+	MarkAlertDelivered(ctx context.Context, id int) error
+	// MarkAlertFailed schedules another delivery after retryIn, or gives up at maxAttempts.
+	MarkAlertFailed(ctx context.Context, id int, reason string, retryIn time.Duration, maxAttempts int) error
+}
+
+type KeyStore interface {
+	// AddAPIKey stores the key and returns its id, ErrConflict when the name or the hash is stored already.
+	AddAPIKey(ctx context.Context, key data.APIKey) (int, error)
+	LoadAPIKeys(ctx context.Context) ([]data.APIKey, error)
+	// LoadAPIKeyByHash returns the key with the SHA-256 hash, nil if there is none.
+	LoadAPIKeyByHash(ctx context.Context, hash []byte) (*data.APIKey, error)
+	// DeleteAPIKey revokes the key, ErrNotFound when there is none.
+	DeleteAPIKey(ctx context.Context, id int) error
+	// MarkAPIKeyUsed records the use of the key, at most once a minute.
+	MarkAPIKeyUsed(ctx context.Context, id int) error
+}
+
+type UserStore interface {
+	// AddUser stores a user without subscriptions and returns its id, ErrConflict when the name is taken.
+	AddUser(ctx context.Context, name string) (int, error)
+	// LoadUsers returns all users with their subscriptions, ordered by name.
+	LoadUsers(ctx context.Context) ([]data.User, error)
+	// LoadUser returns the user with the subscriptions, nil if there is none.
+	LoadUser(ctx context.Context, id int) (*data.User, error)
+	// LoadUserByName returns the user with the name, nil if there is none.
+	LoadUserByName(ctx context.Context, name string) (*data.User, error)
+	// DeleteUser deletes the user with the subscriptions, the state of the news and the
+	// API keys of the user. The channels are kept.
+	DeleteUser(ctx context.Context, id int) error
+	// AddSubscription subscribes the user to the channel, once. ErrReference when the user
+	// or the channel does not exist.
+	AddSubscription(ctx context.Context, userID, channelID int) error
+	// DeleteSubscription unsubscribes the user, ErrNotFound when the user does not follow the channel.
+	DeleteSubscription(ctx context.Context, userID, channelID int) error
+	// SaveNewsState stores the read and starred state of the news for the user.
+	SaveNewsState(ctx context.Context, userID, newsID int, state data.NewsState) error
+}
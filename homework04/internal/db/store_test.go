@@ -0,0 +1,389 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rss_fetcher/internal/data"
+	"sync"
+	"testing"
+	"time"
+)
+
+// storeFixture holds the rows a contract case refers to, missing is an id no row has.
+type storeFixture struct {
+	channelID int
+	groupID   int
+	userID    int
+	newsID    int
+	jobID     int
+	profile   data.EmbeddingProfile
+}
+
+const missing = 1_000_000
+
+func newStoreFixture(t *testing.T, ctx context.Context, store Store) storeFixture {
+	t.Helper()
+	var f storeFixture
+	var err error
+	if f.channelID, err = store.AddChannel(ctx, "https://example.com/feed"); err != nil {
+		t.Fatalf("AddChannel: %v", err)
+	}
+	if f.groupID, err = store.AddGroup(ctx, "tech"); err != nil {
+		t.Fatalf("AddGroup: %v", err)
+	}
+	if f.userID, err = store.AddUser(ctx, "alice"); err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+	news := data.ChannelNews{Title: "First", Link: "https://example.com/first", GUID: "first"}
+	if _, err := store.AddNewsWithJob(ctx, f.channelID, news); err != nil {
+		t.Fatalf("AddNewsWithJob: %v", err)
+	}
+	stored, err := store.LoadNewsByLink(ctx, news.Link)
+	if err != nil || stored == nil {
+		t.Fatalf("LoadNewsByLink = %v, %v", stored, err)
+	}
+	f.newsID = stored.ID
+	jobs, err := store.LoadJobs(ctx, JobFilter{Link: news.Link})
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("LoadJobs = %v, %v", jobs, err)
+	}
+	f.jobID = jobs[0].ID
+	profile, err := store.AddProfile(ctx, data.EmbeddingProfile{
+		Name: "test", Model: "test", Dims: 3, Status: data.ProfileActive, Chunker: "sentence",
+		Index: data.IndexSettings{Type: "hnsw", M: 16, EfConstruction: 64, EfSearch: 40},
+	})
+	if err != nil {
+		t.Fatalf("AddProfile: %v", err)
+	}
+	f.profile = *profile
+	if _, err := store.AddAPIKey(ctx, data.APIKey{Name: "ci", Prefix: "rss_ci", Hash: []byte("hash"), Scope: data.ScopeRead}); err != nil {
+		t.Fatalf("AddAPIKey: %v", err)
+	}
+	return f
+}
+
+// TestStoreErrors checks the error contract documented on Store: updates and deletes
+// of missing rows fail with ErrNotFound, duplicates with ErrConflict and references
+// to missing rows with ErrReference.
+func TestStoreErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(ctx context.Context, store Store, f storeFixture) error
+		want error
+	}{
+		// not found
+		{"delete missing channel", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.DeleteChannel(ctx, missing)
+		}, ErrNotFound},
+		{"update missing channel", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.UpdateChannel(ctx, data.Channel{ID: missing, Link: "https://example.com/other"})
+		}, ErrNotFound},
+		{"delete missing news", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.DeleteNews(ctx, missing)
+		}, ErrNotFound},
+		{"delete missing job", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.DeleteJob(ctx, missing)
+		}, ErrNotFound},
+		{"renew missing job", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.RenewJob(ctx, missing, time.Minute)
+		}, ErrNotFound},
+		{"rename missing group", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.RenameGroup(ctx, missing, "other")
+		}, ErrNotFound},
+		{"delete missing group", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.DeleteGroup(ctx, missing)
+		}, ErrNotFound},
+		{"delete channel outside the group", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.DeleteGroupChannel(ctx, f.groupID, f.channelID)
+		}, ErrNotFound},
+		{"delete missing saved search", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.DeleteSavedSearch(ctx, missing)
+		}, ErrNotFound},
+		{"delete missing api key", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.DeleteAPIKey(ctx, missing)
+		}, ErrNotFound},
+		{"delete missing user", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.DeleteUser(ctx, missing)
+		}, ErrNotFound},
+		{"delete missing subscription", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.DeleteSubscription(ctx, f.userID, f.channelID)
+		}, ErrNotFound},
+
+		// conflicts
+		{"add channel twice", func(ctx context.Context, s Store, f storeFixture) error {
+			_, err := s.AddChannel(ctx, "https://example.com/feed")
+			return err
+		}, ErrConflict},
+		{"add news with a taken guid", func(ctx context.Context, s Store, f storeFixture) error {
+			_, err := s.AddNewsWithJob(ctx, f.channelID, data.ChannelNews{Link: "https://example.com/second", GUID: "first"})
+			return err
+		}, ErrConflict},
+		{"add user twice", func(ctx context.Context, s Store, f storeFixture) error {
+			_, err := s.AddUser(ctx, "alice")
+			return err
+		}, ErrConflict},
+		{"add api key twice", func(ctx context.Context, s Store, f storeFixture) error {
+			_, err := s.AddAPIKey(ctx, data.APIKey{Name: "ci", Prefix: "rss_ci2", Hash: []byte("other"), Scope: data.ScopeRead})
+			return err
+		}, ErrConflict},
+
+		// references
+		{"add channel to missing group", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.AddGroupChannel(ctx, missing, f.channelID)
+		}, ErrReference},
+		{"add missing channel to group", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.AddGroupChannel(ctx, f.groupID, missing)
+		}, ErrReference},
+		{"subscribe missing user", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.AddSubscription(ctx, missing, f.channelID)
+		}, ErrReference},
+		{"subscribe to missing channel", func(ctx context.Context, s Store, f storeFixture) error {
+			return s.AddSubscription(ctx, f.userID, missing)
+		}, ErrReference},
+		{"save search of missing group", func(ctx context.Context, s Store, f storeFixture) error {
+			_, err := s.AddSavedSearch(ctx, data.SavedSearch{Name: "s", Query: "q", GroupID: missing}, f.profile, []float32{1, 0, 0})
+			return err
+		}, ErrReference},
+
+		// repeated additions of members are no errors
+		{"add group member twice", func(ctx context.Context, s Store, f storeFixture) error {
+			if err := s.AddGroupChannel(ctx, f.groupID, f.channelID); err != nil {
+				return err
+			}
+			return s.AddGroupChannel(ctx, f.groupID, f.channelID)
+		}, nil},
+		{"subscribe twice", func(ctx context.Context, s Store, f storeFixture) error {
+			if err := s.AddSubscription(ctx, f.userID, f.channelID); err != nil {
+				return err
+			}
+			return s.AddSubscription(ctx, f.userID, f.channelID)
+		}, nil},
+	}
+
+	ctx := context.Background()
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			f := newStoreFixture(t, ctx, store)
+			for _, tt := range tests {
+				err := tt.run(ctx, store, f)
+				switch {
+				case tt.want == nil && err != nil:
+					t.Errorf("%s: %v, want no error", tt.name, err)
+				case tt.want != nil && !errors.Is(err, tt.want):
+					t.Errorf("%s: %v, want %v", tt.name, err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreNotFoundError(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			err := store.DeleteChannel(ctx, missing)
+			var notFound *NotFoundError
+			if !errors.As(err, &notFound) || notFound.Entity != "channel" || notFound.ID != missing {
+				t.Errorf("DeleteChannel(%d) = %v, want *NotFoundError of channel %d", missing, err, missing)
+			}
+		})
+	}
+}
+
+// TestStoreMissingRows checks that loads of a single missing row return nil without an error.
+func TestStoreMissingRows(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			newStoreFixture(t, ctx, store)
+			check := func(what string, isNil bool, err error) {
+				t.Helper()
+				if err != nil || !isNil {
+					t.Errorf("%s: %v, want nil, nil", what, err)
+				}
+			}
+			channel, err := store.LoadChannel(ctx, missing)
+			check("LoadChannel", channel == nil, err)
+			group, err := store.LoadGroup(ctx, missing)
+			check("LoadGroup", group == nil, err)
+			news, err := store.LoadNewsByID(ctx, missing)
+			check("LoadNewsByID", news == nil, err)
+			news, err = store.LoadNewsByLink(ctx, "https://example.com/missing")
+			check("LoadNewsByLink", news == nil, err)
+			content, err := store.LoadNewsContent(ctx, missing)
+			check("LoadNewsContent", content == nil, err)
+			profile, err := store.LoadProfileByName(ctx, "missing")
+			check("LoadProfileByName", profile == nil, err)
+			key, err := store.LoadAPIKeyByHash(ctx, []byte("missing"))
+			check("LoadAPIKeyByHash", key == nil, err)
+			user, err := store.LoadUser(ctx, missing)
+			check("LoadUser", user == nil, err)
+			user, err = store.LoadUserByName(ctx, "missing")
+			check("LoadUserByName", user == nil, err)
+		})
+	}
+}
+
+// TestClaimJobAttempts checks that a job whose worker keeps dying is taken over only
+// while it has attempts left, and is buried by ReapJobs afterwards.
+func TestClaimJobAttempts(t *testing.T) {
+	ctx := context.Background()
+	const maxAttempts = 2
+	// a negative lease has expired as soon as the job is claimed
+	const expired = -time.Second
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.AddJob(ctx, "https://example.com/crash"); err != nil {
+				t.Fatalf("AddJob: %v", err)
+			}
+			for attempt := 1; attempt <= maxAttempts; attempt++ {
+				job, err := store.ClaimJob(ctx, expired, maxAttempts)
+				if err != nil || job == nil || job.Attempts != attempt {
+					t.Fatalf("ClaimJob attempt %d = %+v, %v, want the job", attempt, job, err)
+				}
+			}
+			if job, err := store.ClaimJob(ctx, expired, maxAttempts); err != nil || job != nil {
+				t.Fatalf("ClaimJob after the last attempt = %+v, %v, want nil, nil", job, err)
+			}
+			dead, err := store.ReapJobs(ctx, maxAttempts)
+			if err != nil || dead != 1 {
+				t.Fatalf("ReapJobs = %d, %v, want 1", dead, err)
+			}
+			jobs, err := store.LoadJobs(ctx, JobFilter{Status: data.JobDead})
+			if err != nil || len(jobs) != 1 || jobs[0].Attempts != maxAttempts {
+				t.Errorf("dead jobs = %+v, %v, want the job after %d attempts", jobs, err, maxAttempts)
+			}
+		})
+	}
+}
+
+// TestClaimAlertsConcurrently checks that replicas claiming alerts at the same time
+// never get the same alert, and that every alert is claimed once.
+func TestClaimAlertsConcurrently(t *testing.T) {
+	ctx := context.Background()
+	const count = 30
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			f := newStoreFixture(t, ctx, store)
+			search := data.SavedSearch{Name: "all", Query: "q", Threshold: 0.5, WebhookURL: "http://localhost:9090"}
+			if _, err := store.AddSavedSearch(ctx, search, f.profile, []float32{1, 0, 0}); err != nil {
+				t.Fatalf("AddSavedSearch: %v", err)
+			}
+			for i := range count {
+				link := fmt.Sprintf("https://example.com/alert-%d", i)
+				if _, err := store.AddNewsWithJob(ctx, f.channelID, data.ChannelNews{Title: "Alert", Link: link, GUID: link}); err != nil {
+					t.Fatalf("AddNewsWithJob: %v", err)
+				}
+				news, err := store.LoadNewsByLink(ctx, link)
+				if err != nil || news == nil {
+					t.Fatalf("LoadNewsByLink = %v, %v", news, err)
+				}
+				if _, err := store.IndexArticle(ctx, f.profile, news.ID, []string{"chunk"}, [][]float32{{1, 0, 0}}); err != nil {
+					t.Fatalf("IndexArticle: %v", err)
+				}
+			}
+
+			claims := make([][]data.SearchAlert, 2)
+			var wg sync.WaitGroup
+			for replica := range claims {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						alerts, err := store.ClaimAlerts(ctx, time.Minute, 4)
+						if err != nil {
+							t.Errorf("ClaimAlerts: %v", err)
+							return
+						}
+						if len(alerts) == 0 {
+							return
+						}
+						claims[replica] = append(claims[replica], alerts...)
+					}
+				}()
+			}
+			wg.Wait()
+
+			claimed := make(map[int]int)
+			for replica, alerts := range claims {
+				for _, alert := range alerts {
+					if other, ok := claimed[alert.ID]; ok {
+						t.Errorf("alert %d claimed by replicas %d and %d", alert.ID, other, replica)
+					}
+					claimed[alert.ID] = replica
+				}
+			}
+			if len(claimed) != count {
+				t.Errorf("claimed %d alerts, want %d", len(claimed), count)
+			}
+		})
+	}
+}
+
+// TestClaimAlertsLease checks that a claimed alert is claimed again once its lease
+// expires, and not at all after it was delivered.
+func TestClaimAlertsLease(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			f := newStoreFixture(t, ctx, store)
+			search := data.SavedSearch{Name: "all", Query: "q", Threshold: 0.5, WebhookURL: "http://localhost:9090"}
+			if _, err := store.AddSavedSearch(ctx, search, f.profile, []float32{1, 0, 0}); err != nil {
+				t.Fatalf("AddSavedSearch: %v", err)
+			}
+			if _, err := store.IndexArticle(ctx, f.profile, f.newsID, []string{"chunk"}, [][]float32{{1, 0, 0}}); err != nil {
+				t.Fatalf("IndexArticle: %v", err)
+			}
+
+			// a negative lease has expired as soon as the alert is claimed
+			alerts, err := store.ClaimAlerts(ctx, -time.Second, 10)
+			if err != nil || len(alerts) != 1 || alerts[0].News.ID != f.newsID || alerts[0].Search.Name != "all" {
+				t.Fatalf("ClaimAlerts = %+v, %v, want the alert", alerts, err)
+			}
+			alerts, err = store.ClaimAlerts(ctx, time.Minute, 10)
+			if err != nil || len(alerts) != 1 {
+				t.Fatalf("ClaimAlerts after the lease expired = %+v, %v, want the alert", alerts, err)
+			}
+			if again, err := store.ClaimAlerts(ctx, time.Minute, 10); err != nil || len(again) != 0 {
+				t.Fatalf("ClaimAlerts of a leased alert = %+v, %v, want none", again, err)
+			}
+			if err := store.MarkAlertDelivered(ctx, alerts[0].ID); err != nil {
+				t.Fatalf("MarkAlertDelivered: %v", err)
+			}
+			if again, err := store.ClaimAlerts(ctx, -time.Second, 10); err != nil || len(again) != 0 {
+				t.Errorf("ClaimAlerts of a delivered alert = %+v, %v, want none", again, err)
+			}
+		})
+	}
+}
+
+// TestRenewJob checks that a renewed lease keeps a running job from other workers
+// and that only running jobs are renewed.
+func TestRenewJob(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.AddJob(ctx, "https://example.com/slow"); err != nil {
+				t.Fatalf("AddJob: %v", err)
+			}
+			// a negative lease has expired as soon as the job is claimed
+			job, err := store.ClaimJob(ctx, -time.Second, 5)
+			if err != nil || job == nil {
+				t.Fatalf("ClaimJob = %+v, %v, want the job", job, err)
+			}
+			if err := store.RenewJob(ctx, job.ID, time.Minute); err != nil {
+				t.Fatalf("RenewJob: %v", err)
+			}
+			if other, err := store.ClaimJob(ctx, time.Minute, 5); err != nil || other != nil {
+				t.Fatalf("ClaimJob of a renewed job = %+v, %v, want nil, nil", other, err)
+			}
+			if err := store.CompleteJob(ctx, job.ID); err != nil {
+				t.Fatalf("CompleteJob: %v", err)
+			}
+			if err := store.RenewJob(ctx, job.ID, time.Minute); !errors.Is(err, ErrNotFound) {
+				t.Errorf("RenewJob of a completed job = %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"rss_fetcher/internal/data"
+)
+
+func (pg *Postgres) AddUser(ctx context.Context, name string) (int, error) {
+	var id int
+	err := pg.pool.QueryRow(ctx, "INSERT INTO users (name) VALUES ($1) RETURNING user_id", name).Scan(&id)
+	return id, Classify(err)
+}
+
+// usersQuery lists every user once per subscription, users without subscriptions once with a NULL channel.
+const usersQuery = `
+	SELECT u.user_id, u.name, u.created_at, s.channel_id
+	FROM users u LEFT JOIN subscriptions s ON s.user_id = u.user_id`
+
+// usersOrder keeps the rows of a user together.
+const usersOrder = " ORDER BY u.name, u.user_id, s.channel_id"
+
+func (pg *Postgres) LoadUsers(ctx context.Context) ([]data.User, error) {
+	return pg.loadUsers(ctx, &where{param: "$"})
+}
+
+func (pg *Postgres) LoadUser(ctx context.Context, id int) (*data.User, error) {
+	where := &where{param: "$"}
+	where.equal("u.user_id", id)
+	return firstUser(pg.loadUsers(ctx, where))
+}
+
+func (pg *Postgres) LoadUserByName(ctx context.Context, name string) (*data.User, error) {
+	where := &where{param: "$"}
+	where.equal("u.name", name)
+	return firstUser(pg.loadUsers(ctx, where))
+}
+
+func (pg *Postgres) loadUsers(ctx context.Context, where *where) ([]data.User, error) {
+	rows, err := pg.pool.Query(ctx, usersQuery+where.String()+usersOrder, where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]data.User, 0)
+	for rows.Next() {
+		var user data.User
+		var channelID *int
+		if err := rows.Scan(&user.ID, &user.Name, &user.CreatedAt, &channelID); err != nil {
+			return nil, err
+		}
+		users = appendSubscription(users, user, channelID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (pg *Postgres) DeleteUser(ctx context.Context, id int) error {
+	tag, err := pg.pool.Exec(ctx, "DELETE FROM users WHERE user_id = $1", id)
+	return rowsAffected(tag, err, "user", id)
+}
+
+func (pg *Postgres) AddSubscription(ctx context.Context, userID, channelID int) error {
+	_, err := pg.pool.Exec(ctx, `
+		INSERT INTO subscriptions (user_id, channel_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, userID, channelID)
+	return Classify(err)
+}
+
+func (pg *Postgres) DeleteSubscription(ctx context.Context, userID, channelID int) error {
+	tag, err := pg.pool.Exec(ctx, "DELETE FROM subscriptions WHERE user_id = $1 AND channel_id = $2", userID, channelID)
+	return rowsAffected(tag, err, "subscription", channelID)
+}
+
+func (pg *Postgres) SaveNewsState(ctx context.Context, userID, newsID int, state data.NewsState) error {
+	_, err := pg.pool.Exec(ctx, `
+		INSERT INTO news_states (user_id, news_id, read, starred) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, news_id) DO UPDATE SET
+			read = excluded.read, starred = excluded.starred, updated_at = NOW()`,
+		userID, newsID, state.Read, state.Starred)
+	return Classify(err)
+}
+
+// appendSubscription adds a row of usersQuery to the users, rows of a user are consecutive.
+func appendSubscription(users []data.User, user data.User, channelID *int) []data.User {
+	if len(users) == 0 || users[len(users)-1].ID != user.ID {
+		user.ChannelIDs = make([]int, 0)
+		users = append(users, user)
+	}
+	if channelID != nil {
+		last := &users[len(users)-1]
+		last.ChannelIDs = append(last.ChannelIDs, *channelID)
+	}
+	return users
+}
+
+// firstUser returns the only user of a lookup, nil if there is none.
+func firstUser(users []data.User, err error) (*data.User, error) {
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+	return &users[0], nil
+}
@@ -22,20 +22,23 @@ package db
 import (
 	"context"
 	"fmt"
+	"rss_fetcher/internal/data"
 )
 
 // Document represents a single document in the vector database.
 // It contains a unique identifier and associated metadata.
 type Document struct {
-	ID       string
-	Metadata map[string]interface{}
+	ID         string
+	NewsID     int
+	Metadata   map[string]interface{}
+	Similarity float64 // cosine similarity to the query
 }
 
-// VectorDatabase is the interface that both QdrantVector and PGVector implement
+// VectorDatabase is the interface that both Postgres and Memory implement
 type VectorDatabase interface {
-	InsertDocument(ctx context.Context, content string, embedding []float32) error
-	QueryRelevantDocuments(ctx context.Context, embedding []float32, backend string) ([]Document, error)
-	SaveEmbeddings(ctx context.Context, docID string, embedding []float32, metadata map[string]interface{}) error
+	InsertDocument(ctx context.Context, profile data.EmbeddingProfile, docID int, content string, embedding []float32) error
+	QueryRelevantDocuments(ctx context.Context, profile data.EmbeddingProfile, embedding []float32, backend string, limit int, scope DocumentScope) ([]Document, error)
+	SaveEmbeddings(ctx context.Context, profile data.EmbeddingProfile, docID int, embedding []float32, metadata map[string]interface{}) error
 }
 
 // CombineQueryWithContext combines the user's query with the relevant retrieved documents' content
@@ -0,0 +1,130 @@
+// Package embedding turns text into embedding vectors.
+// Requests are batched and the number of concurrent requests to the
+// embedding server is limited, so parallel workers cannot overload it.
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Embedder produces one embedding vector per input text.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Provider returns embedders for the models it serves.
+type Provider interface {
+	Embedder(model string) Embedder
+}
+
+type embedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// Ollama calls the batch /api/embed endpoint of an Ollama server.
+type Ollama struct {
+	host      string
+	batchSize int
+	client    *http.Client
+	slots     chan struct{} // limits the number of in-flight requests
+}
+
+// OllamaEmbedder embeds texts with one model of an Ollama server.
+type OllamaEmbedder struct {
+	*Ollama
+	model string
+}
+
+// NewOllama creates a provider for the models served by host.
+// At most concurrency requests are sent at the same time across all models,
+// each carrying at most batchSize texts; callers block until a slot is free.
+func NewOllama(host string, timeout time.Duration, concurrency, batchSize int) *Ollama {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &Ollama{
+		host:      host,
+		batchSize: batchSize,
+		client:    &http.Client{Timeout: timeout},
+		slots:     make(chan struct{}, concurrency),
+	}
+}
+
+func (o *Ollama) Embedder(model string) Embedder {
+	return &OllamaEmbedder{Ollama: o, model: model}
+}
+
+// Dimension embeds a probe text to find out the size of the vectors produced by the embedder.
+func Dimension(ctx context.Context, embedder Embedder) (int, error) {
+	embeddings, err := embedder.Embed(ctx, []string{"dimension probe"})
+	if err != nil {
+		return 0, err
+	}
+	return len(embeddings[0]), nil
+}
+
+func (e *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	result := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += e.batchSize {
+		end := min(start+e.batchSize, len(texts))
+		embeddings, err := e.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, embeddings...)
+	}
+	return result, nil
+}
+
+func (e *OllamaEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	select {
+	case e.slots <- struct{}{}:
+		defer func() { <-e.slots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	body, err := json.Marshal(embedRequest{Model: e.model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.host+"/api/embed", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("embedding server responded with %s: %s", resp.Status, message)
+	}
+
+	var result embedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode embeddings: %w", err)
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(result.Embeddings))
+	}
+	return result.Embeddings, nil
+}
@@ -0,0 +1,64 @@
+package embedding
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// HashingModel is the model name of the vectors produced by Hashing.
+const HashingModel = "hashing"
+
+// Hashing is an offline embedder for evaluation runs without an embedding server.
+// Every word is hashed into one of dims buckets, so texts sharing words get similar
+// vectors. It captures no meaning beyond word overlap, but it is deterministic and
+// shows how chunking and retrieval changes affect results.
+type Hashing struct {
+	dims int
+}
+
+func NewHashing(dims int) *Hashing {
+	return &Hashing{dims: max(dims, 1)}
+}
+
+// Embedder returns the same embedder for every model.
+func (h *Hashing) Embedder(string) Embedder {
+	return h
+}
+
+func (h *Hashing) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	result := make([][]float32, len(texts))
+	for i, text := range texts {
+		result[i] = h.embed(text)
+	}
+	return result, nil
+}
+
+func (h *Hashing) embed(text string) []float32 {
+	vector := make([]float32, h.dims)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if len(word) < 3 {
+			continue // articles and prepositions would dominate the overlap
+		}
+		hash := fnv.New32a()
+		hash.Write([]byte(word))
+		vector[hash.Sum32()%uint32(h.dims)]++
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v * v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+	return vector
+}
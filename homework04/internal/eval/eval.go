@@ -0,0 +1,226 @@
+// Package eval measures how well the retrieval finds the articles which answer a set
+// of questions, so that chunkers, models and profiles can be compared on the same data.
+// Questions go through the same retrieval path as the /query endpoint.
+package eval
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/internal/db"
+	"rss_fetcher/internal/rag"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// chunksPerArticle is how many chunks are retrieved per scored article,
+// several chunks of one article often rank next to each other.
+const chunksPerArticle = 4
+
+// Question is one entry of an evaluation set.
+type Question struct {
+	Question string   `json:"question" yaml:"question"`
+	Links    []string `json:"links" yaml:"links"` // links of the articles which answer the question
+}
+
+// LinkResolver returns the link of an article.
+type LinkResolver interface {
+	Link(ctx context.Context, newsID int) (string, error)
+}
+
+// Result is the outcome of one question.
+type Result struct {
+	Question     Question
+	Retrieved    []string // links of the top k articles, best first
+	Found        int      // expected links among the retrieved ones
+	Rank         int      // position of the first expected link, 0 when none was retrieved
+	NDCG         float64
+	Answer       string  // generated answer, empty when answers are not judged
+	Faithfulness float64 // share of the answer supported by the context, as judged by the model
+}
+
+// Report summarizes an evaluation of one profile.
+type Report struct {
+	Profile      data.EmbeddingProfile
+	K            int
+	Results      []Result
+	Recall       float64 // mean share of the expected links found in the top k
+	MRR          float64 // mean reciprocal rank of the first expected link
+	NDCG         float64 // mean normalized discounted cumulative gain at k
+	Faithfulness float64 // mean judged faithfulness, when answers are judged
+	Judged       bool
+}
+
+// Evaluator runs an evaluation set against one profile.
+type Evaluator struct {
+	Pipeline      *rag.Pipeline
+	Links         LinkResolver
+	K             int // articles scored per question
+	ContextChunks int // chunks passed to the generator, as configured for the API
+
+	// Answers are generated and judged only when both are set.
+	Generator rag.Generator
+	Judge     rag.Generator
+}
+
+// LoadQuestions reads an evaluation set, a YAML list for .yaml and .yml files and one
+// JSON object per line otherwise. Empty lines and lines starting with # are skipped in JSONL.
+func LoadQuestions(path string) ([]Question, error) {
+	var questions []Question
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(content, &questions); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		var err error
+		if questions, err = loadJSONL(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, question := range questions {
+		if question.Question == "" || len(question.Links) == 0 {
+			return nil, fmt.Errorf("%s: question %d: question and links are required", path, i+1)
+		}
+	}
+	return questions, nil
+}
+
+func loadJSONL(path string) ([]Question, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var questions []Question
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var question Question
+		if err := json.Unmarshal([]byte(text), &question); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		questions = append(questions, question)
+	}
+	return questions, scanner.Err()
+}
+
+// Run asks every question and scores the retrieved articles.
+func (e *Evaluator) Run(ctx context.Context, profile data.EmbeddingProfile, questions []Question) (Report, error) {
+	report := Report{Profile: profile, K: e.K, Results: make([]Result, 0, len(questions)), Judged: e.Generator != nil && e.Judge != nil}
+	if len(questions) == 0 {
+		return report, nil
+	}
+
+	for _, question := range questions {
+		result, err := e.evaluate(ctx, profile, question)
+		if err != nil {
+			return report, fmt.Errorf("question %q: %w", question.Question, err)
+		}
+		report.Recall += float64(result.Found) / float64(len(question.Links))
+		if result.Rank > 0 {
+			report.MRR += 1 / float64(result.Rank)
+		}
+		report.NDCG += result.NDCG
+		report.Faithfulness += result.Faithfulness
+		report.Results = append(report.Results, result)
+	}
+
+	n := float64(len(questions))
+	report.Recall /= n
+	report.MRR /= n
+	report.NDCG /= n
+	report.Faithfulness /= n
+	return report, nil
+}
+
+func (e *Evaluator) evaluate(ctx context.Context, profile data.EmbeddingProfile, question Question) (Result, error) {
+	result := Result{Question: question}
+
+	docs, err := e.Pipeline.Retrieve(ctx, profile, question.Question, max(e.K*chunksPerArticle, e.ContextChunks), db.DocumentScope{})
+	if err != nil {
+		return result, err
+	}
+
+	seen := make(map[int]bool)
+	for _, doc := range docs {
+		if len(result.Retrieved) == e.K {
+			break
+		}
+		if seen[doc.NewsID] {
+			continue
+		}
+		seen[doc.NewsID] = true
+		link, err := e.Links.Link(ctx, doc.NewsID)
+		if err != nil {
+			return result, err
+		}
+		result.Retrieved = append(result.Retrieved, link)
+	}
+
+	var dcg, idcg float64
+	for i, link := range result.Retrieved {
+		if !slices.Contains(question.Links, link) {
+			continue
+		}
+		result.Found++
+		if result.Rank == 0 {
+			result.Rank = i + 1
+		}
+		dcg += 1 / math.Log2(float64(i+2))
+	}
+	for i := range min(len(question.Links), e.K) {
+		idcg += 1 / math.Log2(float64(i+2))
+	}
+	if idcg > 0 {
+		result.NDCG = dcg / idcg
+	}
+
+	if e.Generator == nil || e.Judge == nil {
+		return result, nil
+	}
+	context := docs[:min(e.ContextChunks, len(docs))]
+	if result.Answer, err = rag.Answer(ctx, e.Generator, question.Question, context); err != nil {
+		return result, fmt.Errorf("failed to generate answer: %w", err)
+	}
+	if result.Faithfulness, err = judgeFaithfulness(ctx, e.Judge, question.Question, result.Answer, context); err != nil {
+		return result, fmt.Errorf("failed to judge answer: %w", err)
+	}
+	return result, nil
+}
+
+// StoreLinks resolves links of articles kept in a store.
+type StoreLinks struct {
+	store db.NewsStore
+}
+
+func NewStoreLinks(store db.NewsStore) *StoreLinks {
+	return &StoreLinks{store: store}
+}
+
+func (l *StoreLinks) Link(ctx context.Context, newsID int) (string, error) {
+	news, err := l.store.LoadNewsByID(ctx, newsID)
+	if err != nil {
+		return "", err
+	}
+	if news == nil {
+		return "", fmt.Errorf("news %d not found", newsID)
+	}
+	return news.Link, nil
+}
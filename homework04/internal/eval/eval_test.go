@@ -0,0 +1,145 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/internal/db"
+	"rss_fetcher/internal/embedding"
+	"rss_fetcher/internal/parser"
+	"rss_fetcher/internal/rag"
+	"slices"
+	"testing"
+)
+
+// Quality floors of the fixture, a drop below them means a chunking or retrieval
+// change made the answers harder to find.
+const (
+	fixtureMinRecall = 0.95
+	fixtureMinMRR    = 0.8
+	fixtureMinNDCG   = 0.8
+)
+
+func TestFixtureQuality(t *testing.T) {
+	ctx := context.Background()
+	fixture, err := LoadFixture("../../testdata/eval/fixture.yaml")
+	if err != nil {
+		t.Fatalf("LoadFixture: %v", err)
+	}
+	questions, err := LoadQuestions("../../testdata/eval/questions.yaml")
+	if err != nil {
+		t.Fatalf("LoadQuestions: %v", err)
+	}
+	embedders := embedding.NewHashing(512)
+	store, err := fixture.Store(ctx)
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	evaluator := &Evaluator{Pipeline: rag.New(store, embedders), Links: NewStoreLinks(store), K: 3, ContextChunks: 1}
+
+	specs := []string{parser.DefaultChunker, "sentence:size=20,overlap=5", "paragraph:size=40"}
+	for i, spec := range specs {
+		for _, contextual := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/contextual=%t", spec, contextual), func(t *testing.T) {
+				chunker, err := parser.NewChunker(spec)
+				if err != nil {
+					t.Fatalf("NewChunker(%q): %v", spec, err)
+				}
+				profile, err := fixture.Index(ctx, store, embedders, data.EmbeddingProfile{
+					Name:       fmt.Sprintf("fixture-%d-%t", i+1, contextual),
+					Model:      embedding.HashingModel,
+					Dims:       512,
+					Status:     data.ProfileBuilding,
+					Chunker:    chunker.Name(),
+					Contextual: contextual,
+				})
+				if err != nil {
+					t.Fatalf("Index: %v", err)
+				}
+				report, err := evaluator.Run(ctx, *profile, questions)
+				if err != nil {
+					t.Fatalf("Run: %v", err)
+				}
+				if report.Recall < fixtureMinRecall || report.MRR < fixtureMinMRR || report.NDCG < fixtureMinNDCG {
+					t.Errorf("recall %.3f, MRR %.3f, nDCG %.3f, want at least %.2f, %.2f, %.2f",
+						report.Recall, report.MRR, report.NDCG, fixtureMinRecall, fixtureMinMRR, fixtureMinNDCG)
+				}
+			})
+		}
+	}
+}
+
+// stubDocuments returns the same chunks for every question.
+type stubDocuments []db.Document
+
+func (s stubDocuments) QueryRelevantDocuments(_ context.Context, _ data.EmbeddingProfile, _ []float32, _ string, limit int, _ db.DocumentScope) ([]db.Document, error) {
+	return s[:min(limit, len(s))], nil
+}
+
+// stubLinks resolves news ids to links of a map.
+type stubLinks map[int]string
+
+func (s stubLinks) Link(_ context.Context, newsID int) (string, error) {
+	link, ok := s[newsID]
+	if !ok {
+		return "", fmt.Errorf("news %d not found", newsID)
+	}
+	return link, nil
+}
+
+func TestEvaluateScores(t *testing.T) {
+	// the chunks of a are ranked twice, so the top 3 articles are a, b and c
+	docs := stubDocuments{{NewsID: 1}, {NewsID: 1}, {NewsID: 2}, {NewsID: 3}, {NewsID: 4}}
+	links := stubLinks{1: "a", 2: "b", 3: "c", 4: "d"}
+	evaluator := &Evaluator{Pipeline: rag.New(docs, embedding.NewHashing(8)), Links: links, K: 3, ContextChunks: 1}
+
+	gain := func(rank int) float64 { return 1 / math.Log2(float64(rank+1)) }
+	tests := []struct {
+		links []string
+		found int
+		rank  int
+		ndcg  float64
+	}{
+		{links: []string{"a"}, found: 1, rank: 1, ndcg: 1},
+		{links: []string{"b", "d"}, found: 1, rank: 2, ndcg: gain(2) / (gain(1) + gain(2))},
+		{links: []string{"c", "a"}, found: 2, rank: 1, ndcg: (gain(1) + gain(3)) / (gain(1) + gain(2))},
+		{links: []string{"d"}, found: 0, rank: 0, ndcg: 0},
+		// more expected links than k: the ideal ranking is cut at k
+		{links: []string{"c", "x", "y", "z"}, found: 1, rank: 3, ndcg: gain(3) / (gain(1) + gain(2) + gain(3))},
+	}
+	questions := make([]Question, len(tests))
+	var recall, mrr, ndcg float64
+	for i, tt := range tests {
+		questions[i] = Question{Question: fmt.Sprintf("question %d", i+1), Links: tt.links}
+		recall += float64(tt.found) / float64(len(tt.links))
+		if tt.rank > 0 {
+			mrr += 1 / float64(tt.rank)
+		}
+		ndcg += tt.ndcg
+	}
+
+	report, err := evaluator.Run(context.Background(), data.EmbeddingProfile{Name: "stub"}, questions)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	for i, tt := range tests {
+		result := report.Results[i]
+		if want := []string{"a", "b", "c"}; !slices.Equal(result.Retrieved, want) {
+			t.Errorf("question %d retrieved %v, want %v", i+1, result.Retrieved, want)
+		}
+		if result.Found != tt.found || result.Rank != tt.rank || !closeTo(result.NDCG, tt.ndcg) {
+			t.Errorf("question %d: found %d, rank %d, nDCG %.4f, want %d, %d, %.4f",
+				i+1, result.Found, result.Rank, result.NDCG, tt.found, tt.rank, tt.ndcg)
+		}
+	}
+	n := float64(len(tests))
+	if !closeTo(report.Recall, recall/n) || !closeTo(report.MRR, mrr/n) || !closeTo(report.NDCG, ndcg/n) {
+		t.Errorf("report recall %.4f, MRR %.4f, nDCG %.4f, want %.4f, %.4f, %.4f",
+			report.Recall, report.MRR, report.NDCG, recall/n, mrr/n, ndcg/n)
+	}
+}
+
+func closeTo(got, want float64) bool {
+	return math.Abs(got-want) < 1e-9
+}
@@ -0,0 +1,115 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/internal/db"
+	"rss_fetcher/internal/embedding"
+	"rss_fetcher/internal/parser"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fixture is a small corpus which is indexed in memory, so that evaluations run
+// offline, e.g. in CI, without Postgres and without an embedding server.
+type Fixture struct {
+	Articles []FixtureArticle `json:"articles" yaml:"articles"`
+}
+
+type FixtureArticle struct {
+	Link    string    `json:"link" yaml:"link"`
+	Title   string    `json:"title" yaml:"title"`
+	Channel string    `json:"channel" yaml:"channel"`
+	PubDate time.Time `json:"pub_date" yaml:"pub_date"`
+	HTML    string    `json:"html" yaml:"html"` // readability markup, used by the paragraph chunker
+	Text    string    `json:"text" yaml:"text"`
+}
+
+// LoadFixture reads a fixture from a YAML (.yaml, .yml) or JSON file.
+func LoadFixture(path string) (*Fixture, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixture Fixture
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &fixture)
+	default:
+		err = json.Unmarshal(content, &fixture)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &fixture, nil
+}
+
+// Store loads the articles into an in-memory store, one channel per channel name.
+func (f *Fixture) Store(ctx context.Context) (*db.Memory, error) {
+	store := db.NewMemory()
+	channels := make(map[string]int)
+
+	for _, article := range f.Articles {
+		channelID, ok := channels[article.Channel]
+		if !ok {
+			added, err := store.AddChannel(ctx, article.Channel)
+			if err != nil {
+				return nil, err
+			}
+			channelID = added
+			channels[article.Channel] = channelID
+		}
+		news := data.ChannelNews{Title: article.Title, Link: article.Link, PubDate: article.PubDate, GUID: article.Link}
+		if _, err := store.AddNewsWithJob(ctx, channelID, news); err != nil {
+			return nil, fmt.Errorf("failed to add %s: %w", article.Link, err)
+		}
+	}
+	return store, nil
+}
+
+// Index adds the profile to the store and chunks and embeds the articles the way
+// news-service does for it.
+func (f *Fixture) Index(ctx context.Context, store *db.Memory, embedders embedding.Provider, spec data.EmbeddingProfile) (*data.EmbeddingProfile, error) {
+	chunker, err := parser.NewChunker(spec.Chunker)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := store.AddProfile(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	embedder := embedders.Embedder(profile.Model)
+
+	for _, article := range f.Articles {
+		news, err := store.LoadNewsByLink(ctx, article.Link)
+		if err != nil {
+			return nil, err
+		}
+		if news == nil {
+			return nil, fmt.Errorf("article %s is not in the store", article.Link)
+		}
+
+		chunks, err := chunker.Chunk(data.NewsContent{NewsID: news.ID, Title: article.Title, HTML: article.HTML, Text: article.Text})
+		if err != nil {
+			return nil, fmt.Errorf("failed to chunk %s: %w", article.Link, err)
+		}
+		texts := chunks
+		if profile.Contextual {
+			texts = parser.ContextualChunks(data.ChunkContext{Title: article.Title, Channel: article.Channel, PubDate: article.PubDate}, chunks)
+		}
+		embeddings, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed %s: %w", article.Link, err)
+		}
+		if _, err := store.IndexArticle(ctx, *profile, news.ID, chunks, embeddings); err != nil {
+			return nil, fmt.Errorf("failed to index %s: %w", article.Link, err)
+		}
+	}
+	return profile, nil
+}
@@ -0,0 +1,45 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"rss_fetcher/internal/db"
+	"rss_fetcher/internal/rag"
+	"strconv"
+
+	backend "rss_fetcher/internal/ollama"
+)
+
+const judgeInstructions = `You grade answers of a question answering system.
+Given the context the system received and its answer, estimate which share of the claims
+made in the answer is supported by the context. Reply with a single number between 0 and 1
+and nothing else: 1 when everything is supported, 0 when nothing is.`
+
+var scorePattern = regexp.MustCompile(`[01](?:\.\d+)?|\.\d+`)
+
+// judgeFaithfulness asks the judge model how much of the answer is grounded in the context.
+func judgeFaithfulness(ctx context.Context, judge rag.Generator, question, answer string, docs []db.Document) (float64, error) {
+	prompt := backend.NewPrompt().
+		AddMessage("system", judgeInstructions).
+		AddMessage("user", fmt.Sprintf("%s\n\nAnswer: %s", db.CombineQueryWithContext(question, docs), answer)).
+		SetParameters(backend.Parameters{
+			MaxTokens:   10,
+			Temperature: 0,
+			TopP:        1,
+		})
+
+	response, err := judge.Generate(ctx, prompt)
+	if err != nil {
+		return 0, err
+	}
+	match := scorePattern.FindString(response)
+	if match == "" {
+		return 0, fmt.Errorf("judge replied without a score: %q", response)
+	}
+	score, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return 0, err
+	}
+	return min(max(score, 0), 1), nil
+}
@@ -1,59 +1,87 @@
 package parser
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	nurl "net/url"
+	"rss_fetcher/internal/data"
 	"strings"
-	"time"
-
-	prose "github.com/jdkato/prose/v2"
 
 	readability "github.com/go-shiori/go-readability"
+	"golang.org/x/net/html"
 )
 
 func countTokens(text string) int {
 	return len(strings.Fields(text))
 }
 
-func chunkTextByTokens(text string, maxTokens int) ([]string, error) {
-	doc, err := prose.NewDocument(text)
+// FetchArticle downloads the page with the custom headers of its channel and
+// extracts its readable content. The download is cancelled with the context.
+func FetchArticle(ctx context.Context, url string, headers map[string]string) (*data.NewsContent, error) {
+	pageURL, err := nurl.ParseRequestURI(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-
-	var chunks []string
-	var currentChunk []string
-	var currentLen int
-
-	for _, sent := range doc.Sentences() {
-		sentText := sent.Text
-		sentTokens := countTokens(sentText)
-
-		if currentLen+sentTokens > maxTokens {
-			chunks = append(chunks, strings.Join(currentChunk, " "))
-			currentChunk = []string{}
-			currentLen = 0
-		}
-
-		currentChunk = append(currentChunk, sentText)
-		currentLen += sentTokens
+	setHeaders(req, headers)
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the page: %w", err)
 	}
+	defer resp.Body.Close()
 
-	if len(currentChunk) > 0 {
-		chunks = append(chunks, strings.Join(currentChunk, " "))
+	if contentType := resp.Header.Get("Content-Type"); !strings.Contains(contentType, "text/html") {
+		return nil, fmt.Errorf("URL is not a HTML document: %s", contentType)
+	}
+	article, err := readability.FromReader(resp.Body, pageURL)
+	if err != nil {
+		return nil, err
 	}
 
-	return chunks, nil
+	return &data.NewsContent{
+		Title:    article.Title,
+		Byline:   article.Byline,
+		Excerpt:  article.Excerpt,
+		SiteName: article.SiteName,
+		Image:    article.Image,
+		Length:   article.Length,
+		HTML:     article.Content,
+		Text:     article.TextContent,
+	}, nil
 }
 
-func ExtractArticle(url string, chunkSize int) ([]string, error) {
-	article, err := readability.FromURL(url, 30*time.Second)
+// ChannelArticle returns the content of a news item the way its channel asks for:
+// the downloaded page, or the feed description when full articles are turned off.
+func ChannelArticle(ctx context.Context, channel data.Channel, news data.ChannelNews) (*data.NewsContent, error) {
+	if !channel.FullArticles {
+		return FeedArticle(news)
+	}
+	content, err := FetchArticle(ctx, news.Link, channel.Headers)
 	if err != nil {
 		return nil, err
 	}
+	content.NewsID = news.ID
+	return content, nil
+}
 
-	chunks, err := chunkTextByTokens(article.TextContent, chunkSize)
+// FeedArticle builds the content of a news item from its feed description, for
+// channels whose articles are not downloaded.
+func FeedArticle(news data.ChannelNews) (*data.NewsContent, error) {
+	doc, err := html.Parse(strings.NewReader(news.Description))
 	if err != nil {
 		return nil, err
 	}
-
-	return chunks, nil
+	text := nodeText(doc)
+	return &data.NewsContent{
+		NewsID: news.ID,
+		Title:  news.Title,
+		Byline: news.Author,
+		Length: len([]rune(text)),
+		HTML:   news.Description,
+		Text:   text,
+	}, nil
 }
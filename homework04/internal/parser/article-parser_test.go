@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const articlePage = `<html><head><title>Release notes</title></head><body><article>
+<h1>Release notes</h1>
+<p>The new release makes vector searches faster and adds iterative index scans to filtered queries.</p>
+<p>Upgrading needs no migration, the indexes of earlier releases keep working after a restart.</p>
+</article></body></html>`
+
+func TestFetchArticle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "missing header", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(articlePage))
+	}))
+	defer server.Close()
+
+	content, err := FetchArticle(context.Background(), server.URL, map[string]string{"X-Token": "secret"})
+	if err != nil {
+		t.Fatalf("FetchArticle: %v", err)
+	}
+	if content.Title != "Release notes" || !strings.Contains(content.Text, "iterative index scans") {
+		t.Errorf("FetchArticle = %+v, want the release notes", content)
+	}
+}
+
+// TestFetchArticleCancel checks that a download is given up as soon as the context
+// is cancelled, not after fetchTimeout.
+func TestFetchArticleCancel(t *testing.T) {
+	stalled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-stalled:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(stalled)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := FetchArticle(ctx, server.URL, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("FetchArticle of a cancelled download = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > fetchTimeout/2 {
+		t.Errorf("FetchArticle returned after %s", elapsed)
+	}
+}
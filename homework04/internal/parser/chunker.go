@@ -0,0 +1,352 @@
+package parser
+
+import (
+	"fmt"
+	"rss_fetcher/internal/data"
+	"strconv"
+	"strings"
+
+	prose "github.com/jdkato/prose/v2"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DefaultChunker is the chunker spec used when a deployment does not configure one.
+const DefaultChunker = "sentence:size=400,overlap=50,tokenizer=words"
+
+const headingSeparator = " > "
+
+// Chunker splits an article into the chunks which are embedded.
+type Chunker interface {
+	// Name is the normalized spec of the chunker, it is recorded with every embedding.
+	Name() string
+	Chunk(content data.NewsContent) ([]string, error)
+}
+
+// chunkOptions are shared by all strategies.
+type chunkOptions struct {
+	size      int // maximum chunk size in tokens
+	overlap   int // tokens repeated from the end of the previous chunk
+	tokenizer Tokenizer
+}
+
+// NewChunker creates a chunker from a spec of the form strategy:key=value,...
+//
+// Strategies:
+//   - sentence: windows of whole sentences
+//   - paragraph: paragraphs of the readability HTML packed within their section,
+//     every chunk starts with the headings of its section
+//
+// Options:
+//   - size: maximum chunk size in tokens, 400 by default
+//   - overlap: tokens of the previous chunk repeated at the start of the next one, 0 by default
+//   - tokenizer: "words" to count whitespace separated words, or the path to the
+//     vocab.txt of a WordPiece embedding model to count the tokens the model sees
+func NewChunker(spec string) (Chunker, error) {
+	strategy, params, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	options := chunkOptions{size: 400, tokenizer: WordTokenizer{}}
+
+	if params != "" {
+		for _, param := range strings.Split(params, ",") {
+			key, value, ok := strings.Cut(param, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid chunker option %q", param)
+			}
+			var err error
+			switch strings.TrimSpace(key) {
+			case "size":
+				options.size, err = strconv.Atoi(value)
+			case "overlap":
+				options.overlap, err = strconv.Atoi(value)
+			case "tokenizer":
+				if value != "words" {
+					options.tokenizer, err = NewWordPieceTokenizer(value)
+				}
+			default:
+				err = fmt.Errorf("unknown option")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid chunker option %q: %w", param, err)
+			}
+		}
+	}
+	if options.size < 1 {
+		return nil, fmt.Errorf("chunk size must be positive, got %d", options.size)
+	}
+	if options.overlap < 0 || options.overlap >= options.size {
+		return nil, fmt.Errorf("chunk overlap must be between 0 and the chunk size, got %d", options.overlap)
+	}
+
+	switch strategy {
+	case "sentence":
+		return &SentenceChunker{options}, nil
+	case "paragraph":
+		return &ParagraphChunker{options}, nil
+	default:
+		return nil, fmt.Errorf("unknown chunking strategy %q", strategy)
+	}
+}
+
+func (o chunkOptions) spec(strategy string) string {
+	return fmt.Sprintf("%s:size=%d,overlap=%d,tokenizer=%s", strategy, o.size, o.overlap, o.tokenizer.Name())
+}
+
+// SentenceChunker packs consecutive sentences of the article text into chunks.
+type SentenceChunker struct {
+	chunkOptions
+}
+
+func (c *SentenceChunker) Name() string {
+	return c.spec("sentence")
+}
+
+func (c *SentenceChunker) Chunk(content data.NewsContent) ([]string, error) {
+	sentences, err := splitSentences(content.Text)
+	if err != nil {
+		return nil, err
+	}
+	return c.pack(sentences, "", " "), nil
+}
+
+// ParagraphChunker keeps the structure of the article: a chunk never spans two sections,
+// paragraphs are split only when they do not fit in a chunk, and the headings of the
+// section are repeated at the start of every chunk. Articles without usable markup
+// are chunked by sentences.
+type ParagraphChunker struct {
+	chunkOptions
+}
+
+func (c *ParagraphChunker) Name() string {
+	return c.spec("paragraph")
+}
+
+func (c *ParagraphChunker) Chunk(content data.NewsContent) ([]string, error) {
+	sections, err := htmlSections(content.HTML)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse article markup: %w", err)
+	}
+	if len(sections) == 0 {
+		sentences, err := splitSentences(content.Text)
+		if err != nil {
+			return nil, err
+		}
+		return c.pack(sentences, "", " "), nil
+	}
+
+	var chunks []string
+	for _, section := range sections {
+		var segments []string
+		for _, paragraph := range section.paragraphs {
+			if c.tokenizer.Count(paragraph) <= c.size {
+				segments = append(segments, paragraph)
+				continue
+			}
+			sentences, err := splitSentences(paragraph)
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, sentences...)
+		}
+		chunks = append(chunks, c.pack(segments, section.heading, "\n")...)
+	}
+	return chunks, nil
+}
+
+// pack joins segments into chunks of at most size tokens. When a chunk is full, the
+// next one starts with the last segments of it which fit into overlap tokens.
+// Segments larger than a chunk are split by words. A non-empty header starts every chunk.
+func (o chunkOptions) pack(segments []string, header, separator string) []string {
+	budget := o.size
+	if header != "" {
+		budget -= o.tokenizer.Count(header)
+		// a header taking most of the chunk would crowd out the content
+		if budget < o.size/2 {
+			header, budget = "", o.size
+		}
+	}
+
+	var pieces []string
+	var sizes []int
+	for _, segment := range segments {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			continue
+		}
+		n := o.tokenizer.Count(segment)
+		if n <= budget {
+			pieces, sizes = append(pieces, segment), append(sizes, n)
+			continue
+		}
+		for _, piece := range o.splitWords(segment, budget) {
+			pieces, sizes = append(pieces, piece), append(sizes, o.tokenizer.Count(piece))
+		}
+	}
+
+	var chunks []string
+	emit := func(window []string) {
+		chunk := strings.Join(window, separator)
+		if header != "" {
+			chunk = header + "\n\n" + chunk
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	var window []string
+	var windowSizes []int
+	total, fresh := 0, 0 // tokens in the window and tokens not emitted yet
+	for i, piece := range pieces {
+		n := sizes[i]
+		if total+n > budget && fresh > 0 {
+			emit(window)
+
+			keep, kept := 0, 0
+			for j := len(window) - 1; j >= 0; j-- {
+				if kept+windowSizes[j] > o.overlap || kept+windowSizes[j]+n > budget {
+					break
+				}
+				kept += windowSizes[j]
+				keep++
+			}
+			window = append([]string(nil), window[len(window)-keep:]...)
+			windowSizes = append([]int(nil), windowSizes[len(windowSizes)-keep:]...)
+			total, fresh = kept, 0
+		}
+		window, windowSizes = append(window, piece), append(windowSizes, n)
+		total += n
+		fresh += n
+	}
+	if fresh > 0 {
+		emit(window)
+	}
+	return chunks
+}
+
+// splitWords splits a text which does not fit into a chunk into pieces of at most budget tokens.
+func (o chunkOptions) splitWords(text string, budget int) []string {
+	var pieces []string
+	var piece []string
+	total := 0
+	for _, word := range strings.Fields(text) {
+		n := o.tokenizer.Count(word)
+		if total+n > budget && len(piece) > 0 {
+			pieces = append(pieces, strings.Join(piece, " "))
+			piece, total = nil, 0
+		}
+		piece = append(piece, word)
+		total += n
+	}
+	if len(piece) > 0 {
+		pieces = append(pieces, strings.Join(piece, " "))
+	}
+	return pieces
+}
+
+func splitSentences(text string) ([]string, error) {
+	doc, err := prose.NewDocument(text, prose.WithTagging(false), prose.WithExtraction(false))
+	if err != nil {
+		return nil, err
+	}
+	sentences := make([]string, 0, len(doc.Sentences()))
+	for _, sent := range doc.Sentences() {
+		sentences = append(sentences, sent.Text)
+	}
+	return sentences, nil
+}
+
+// section is a part of an article under one heading.
+type section struct {
+	heading    string // headings of all levels leading to the section, joined
+	paragraphs []string
+}
+
+// htmlSections splits the article markup into sections of paragraphs. Text outside
+// of block elements, e.g. directly in a div, forms a paragraph of its own.
+func htmlSections(markup string) ([]section, error) {
+	if strings.TrimSpace(markup) == "" {
+		return nil, nil
+	}
+	doc, err := html.Parse(strings.NewReader(markup))
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []section
+	var headings [6]string
+	current := section{}
+	var loose strings.Builder
+
+	addParagraph := func(text string) {
+		if text = strings.Join(strings.Fields(text), " "); text != "" {
+			current.paragraphs = append(current.paragraphs, text)
+		}
+	}
+	flushLoose := func() {
+		addParagraph(loose.String())
+		loose.Reset()
+	}
+	startSection := func(level int, title string) {
+		flushLoose()
+		if len(current.paragraphs) > 0 {
+			sections = append(sections, current)
+		}
+		headings[level] = title
+		for i := level + 1; i < len(headings); i++ {
+			headings[i] = ""
+		}
+		var path []string
+		for _, heading := range headings[:level+1] {
+			if heading != "" {
+				path = append(path, heading)
+			}
+		}
+		current = section{heading: strings.Join(path, headingSeparator)}
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			loose.WriteString(n.Data)
+			loose.WriteString(" ")
+			return
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript:
+				return
+			case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+				startSection(int(n.Data[1]-'1'), nodeText(n))
+				return
+			case atom.P, atom.Li, atom.Blockquote, atom.Pre, atom.Figcaption, atom.Td, atom.Th, atom.Dt, atom.Dd:
+				flushLoose()
+				addParagraph(nodeText(n))
+				return
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	flushLoose()
+	if len(current.paragraphs) > 0 {
+		sections = append(sections, current)
+	}
+	return sections, nil
+}
+
+// nodeText returns the text of the node with collapsed whitespace.
+func nodeText(n *html.Node) string {
+	var text strings.Builder
+	var collect func(n *html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			text.WriteString(n.Data)
+			text.WriteString(" ")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(text.String()), " ")
+}
@@ -0,0 +1,36 @@
+package parser
+
+import (
+	"rss_fetcher/internal/data"
+	"strings"
+	"time"
+)
+
+// ContextHeader returns the header prepended to the chunks of the article before embedding.
+func ContextHeader(context data.ChunkContext) string {
+	var header strings.Builder
+	if context.Title != "" {
+		header.WriteString("Title: " + context.Title + "\n")
+	}
+	if context.Channel != "" {
+		header.WriteString("Source: " + context.Channel + "\n")
+	}
+	if !context.PubDate.IsZero() {
+		header.WriteString("Date: " + context.PubDate.Format(time.DateOnly) + "\n")
+	}
+	return header.String()
+}
+
+// ContextualChunks returns the texts embedded for the chunks: every chunk with the
+// context header of the article. The chunks themselves are stored without it.
+func ContextualChunks(context data.ChunkContext, chunks []string) []string {
+	header := ContextHeader(context)
+	if header == "" {
+		return chunks
+	}
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = header + "\n" + chunk
+	}
+	return texts
+}
@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Feed is an output feed republishing stored news. Updated should come from the
+// items, so the same items always give the same document.
+type Feed struct {
+	Title       string
+	Link        string // URL of the feed itself
+	Description string
+	Updated     time.Time
+	Items       []FeedItem
+}
+
+type FeedItem struct {
+	GUID        string
+	Title       string
+	Link        string
+	Description string
+	Author      string
+	Category    string
+	PubDate     time.Time
+}
+
+type xmlOutputRSS struct {
+	XMLName xml.Name         `xml:"rss"`
+	Version string           `xml:"version,attr"`
+	Atom    string           `xml:"xmlns:atom,attr"`
+	Channel xmlOutputChannel `xml:"channel"`
+}
+
+type xmlOutputChannel struct {
+	Title         string          `xml:"title"`
+	Link          string          `xml:"link"`
+	Self          xmlAtomLink     `xml:"atom:link"`
+	Description   string          `xml:"description"`
+	LastBuildDate string          `xml:"lastBuildDate,omitempty"`
+	Generator     string          `xml:"generator"`
+	Items         []xmlOutputItem `xml:"item"`
+}
+
+type xmlOutputItem struct {
+	Title       string     `xml:"title"`
+	Link        string     `xml:"link"`
+	Description string     `xml:"description,omitempty"`
+	Author      string     `xml:"author,omitempty"`
+	Category    string     `xml:"category,omitempty"`
+	PubDate     string     `xml:"pubDate,omitempty"`
+	GUID        xmlRSSGUID `xml:"guid"`
+}
+
+type xmlRSSGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type xmlAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type xmlAtomFeed struct {
+	XMLName   xml.Name       `xml:"http://www.w3.org/2005/Atom feed"`
+	ID        string         `xml:"id"`
+	Title     string         `xml:"title"`
+	Subtitle  string         `xml:"subtitle,omitempty"`
+	Updated   string         `xml:"updated"`
+	Links     []xmlAtomLink  `xml:"link"`
+	Generator string         `xml:"generator"`
+	Entries   []xmlAtomEntry `xml:"entry"`
+}
+
+type xmlAtomEntry struct {
+	ID        string         `xml:"id"`
+	Title     string         `xml:"title"`
+	Link      xmlAtomLink    `xml:"link"`
+	Updated   string         `xml:"updated"`
+	Published string         `xml:"published,omitempty"`
+	Author    *xmlAtomAuthor `xml:"author"`
+	Category  *xmlAtomTerm   `xml:"category"`
+	Summary   string         `xml:"summary,omitempty"`
+}
+
+type xmlAtomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type xmlAtomTerm struct {
+	Term string `xml:"term,attr"`
+}
+
+const feedGenerator = "rss_fetcher"
+
+// WriteRSS writes the feed as RSS 2.0.
+func WriteRSS(w io.Writer, feed Feed) error {
+	channel := xmlOutputChannel{
+		Title:       feed.Title,
+		Link:        feed.Link,
+		Self:        xmlAtomLink{Href: feed.Link, Rel: "self", Type: "application/rss+xml"},
+		Description: feed.Description,
+		Generator:   feedGenerator,
+		Items:       make([]xmlOutputItem, len(feed.Items)),
+	}
+	if !feed.Updated.IsZero() {
+		channel.LastBuildDate = feed.Updated.UTC().Format(time.RFC1123Z)
+	}
+	for i, item := range feed.Items {
+		channel.Items[i] = xmlOutputItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Description,
+			Author:      item.Author,
+			Category:    item.Category,
+			GUID:        xmlRSSGUID{Value: item.GUID},
+		}
+		if !item.PubDate.IsZero() {
+			channel.Items[i].PubDate = item.PubDate.UTC().Format(time.RFC1123Z)
+		}
+	}
+	return writeXML(w, xmlOutputRSS{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: channel})
+}
+
+// WriteAtom writes the feed as Atom 1.0. Items without a publication date are
+// dated with the feed.
+func WriteAtom(w io.Writer, feed Feed) error {
+	updated := feed.Updated
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+	atom := xmlAtomFeed{
+		ID:        feed.Link,
+		Title:     feed.Title,
+		Subtitle:  feed.Description,
+		Updated:   updated.UTC().Format(time.RFC3339),
+		Links:     []xmlAtomLink{{Href: feed.Link, Rel: "self", Type: "application/atom+xml"}},
+		Generator: feedGenerator,
+		Entries:   make([]xmlAtomEntry, len(feed.Items)),
+	}
+	for i, item := range feed.Items {
+		entry := xmlAtomEntry{
+			ID:      item.GUID,
+			Title:   item.Title,
+			Link:    xmlAtomLink{Href: item.Link},
+			Updated: atom.Updated,
+			Summary: item.Description,
+		}
+		if !item.PubDate.IsZero() {
+			entry.Updated = item.PubDate.UTC().Format(time.RFC3339)
+			entry.Published = entry.Updated
+		}
+		if item.Author != "" {
+			entry.Author = &xmlAtomAuthor{Name: item.Author}
+		}
+		if item.Category != "" {
+			entry.Category = &xmlAtomTerm{Term: item.Category}
+		}
+		atom.Entries[i] = entry
+	}
+	return writeXML(w, atom)
+}
+
+func writeXML(w io.Writer, document any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+)
+
+// OPMLFeed is a feed subscription of an OPML file. Folder is the path of the
+// outlines it is nested in, joined with "/", empty at the top level.
+type OPMLFeed struct {
+	URL     string
+	Title   string
+	SiteURL string
+	Folder  string
+}
+
+type xmlOPML struct {
+	XMLName xml.Name    `xml:"opml"`
+	Version string      `xml:"version,attr"`
+	Head    xmlOPMLHead `xml:"head"`
+	Body    struct {
+		Outlines []xmlOutline `xml:"outline"`
+	} `xml:"body"`
+}
+
+type xmlOPMLHead struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type xmlOutline struct {
+	Text     string       `xml:"text,attr"`
+	Title    string       `xml:"title,attr,omitempty"`
+	Type     string       `xml:"type,attr,omitempty"`
+	XMLURL   string       `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string       `xml:"htmlUrl,attr,omitempty"`
+	Outlines []xmlOutline `xml:"outline"`
+}
+
+// ParseOPML returns the feeds of an OPML file in document order. Outlines without
+// xmlUrl are folders.
+func ParseOPML(r io.Reader) ([]OPMLFeed, error) {
+	var opml xmlOPML
+	if err := xml.NewDecoder(r).Decode(&opml); err != nil {
+		return nil, err
+	}
+	feeds := make([]OPMLFeed, 0)
+	var walk func(outlines []xmlOutline, folder string)
+	walk = func(outlines []xmlOutline, folder string) {
+		for _, outline := range outlines {
+			name := strings.TrimSpace(outline.Title)
+			if name == "" {
+				name = strings.TrimSpace(outline.Text)
+			}
+			if url := strings.TrimSpace(outline.XMLURL); url != "" {
+				feeds = append(feeds, OPMLFeed{URL: url, Title: name, SiteURL: strings.TrimSpace(outline.HTMLURL), Folder: folder})
+				walk(outline.Outlines, folder)
+				continue
+			}
+			if name == "" {
+				walk(outline.Outlines, folder)
+				continue
+			}
+			if folder != "" {
+				name = folder + "/" + name
+			}
+			walk(outline.Outlines, name)
+		}
+	}
+	walk(opml.Body.Outlines, "")
+	return feeds, nil
+}
+
+// opmlFolder collects the outlines of a folder while the document is built.
+type opmlFolder struct {
+	name    string
+	feeds   []xmlOutline
+	folders []*opmlFolder
+}
+
+func (f *opmlFolder) folder(name string) *opmlFolder {
+	for _, folder := range f.folders {
+		if folder.name == name {
+			return folder
+		}
+	}
+	folder := &opmlFolder{name: name}
+	f.folders = append(f.folders, folder)
+	return folder
+}
+
+// outlines returns the feeds of the folder followed by its subfolders.
+func (f *opmlFolder) outlines() []xmlOutline {
+	outlines := f.feeds
+	for _, folder := range f.folders {
+		outlines = append(outlines, xmlOutline{Text: folder.name, Title: folder.name, Outlines: folder.outlines()})
+	}
+	return outlines
+}
+
+// WriteOPML writes an OPML 2.0 document with the feeds nested in outlines by their folders.
+func WriteOPML(w io.Writer, title string, feeds []OPMLFeed) error {
+	root := &opmlFolder{}
+	for _, feed := range feeds {
+		folder := root
+		if feed.Folder != "" {
+			for _, name := range strings.Split(feed.Folder, "/") {
+				folder = folder.folder(name)
+			}
+		}
+		text := feed.Title
+		if text == "" {
+			text = feed.URL
+		}
+		folder.feeds = append(folder.feeds, xmlOutline{Text: text, Title: feed.Title, Type: "rss", XMLURL: feed.URL, HTMLURL: feed.SiteURL})
+	}
+
+	opml := xmlOPML{Version: "2.0", Head: xmlOPMLHead{Title: title, DateCreated: time.Now().UTC().Format(time.RFC1123Z)}}
+	opml.Body.Outlines = root.outlines()
+
+	return writeXML(w, opml)
+}
@@ -1,8 +1,9 @@
 package parser
 
 import (
+	"context"
 	"encoding/xml"
-	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"rss_fetcher/internal/data"
@@ -36,27 +37,50 @@ type xmlRSSItem struct {
 	GUID        string `xml:"guid"`
 }
 
-func FetchRSS(url string) ([]data.ChannelNews, error) {
-	resp, err := http.Get(url)
+// FeedResponse is the result of a feed download, filled as far as the download got.
+type FeedResponse struct {
+	StatusCode int // 0 when no response was received
+	Bytes      int
+	News       []data.ChannelNews
+}
+
+// fetchTimeout bounds the download of a feed or an article, a server which stops
+// responding must not block the daemon.
+const fetchTimeout = 30 * time.Second
+
+// feedClient downloads feeds and articles
+var feedClient = &http.Client{Timeout: fetchTimeout}
+
+// FetchRSS downloads the feed with the custom headers of its channel.
+func FetchRSS(ctx context.Context, url string, headers map[string]string) (FeedResponse, error) {
+	var result FeedResponse
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, err
+		return result, err
+	}
+	setHeaders(req, headers)
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return result, err
 	}
 	defer resp.Body.Close()
+	result.StatusCode = resp.StatusCode
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("failed to fetch RSS feed: non-200 response")
+		return result, fmt.Errorf("failed to fetch RSS feed: non-200 response %d", resp.StatusCode)
 	}
 
 	// Read all as first implementation
 	// better to use a streaming parser for large feeds and pass it to db writer as some interface
 	body, err := io.ReadAll(resp.Body)
+	result.Bytes = len(body)
 	if err != nil {
-		return nil, err
+		return result, err
 	}
 
 	var xmlData xmlRSS
 	if err := xml.Unmarshal(body, &xmlData); err != nil {
-		return nil, err
+		return result, err
 	}
 
 	// Convert xmlRSSItem to data.RSSItem
@@ -74,5 +98,12 @@ func FetchRSS(url string) ([]data.ChannelNews, error) {
 			GUID:        item.GUID,
 		}
 	}
-	return items, nil
+	result.News = items
+	return result, nil
+}
+
+func setHeaders(req *http.Request, headers map[string]string) {
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
 }
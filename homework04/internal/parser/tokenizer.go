@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Tokenizer measures the size of a text in tokens.
+type Tokenizer interface {
+	Name() string
+	Count(text string) int
+}
+
+// WordTokenizer counts whitespace separated words. It is cheap but underestimates
+// the number of tokens the embedding model sees, often by a third or more.
+type WordTokenizer struct{}
+
+func (WordTokenizer) Name() string {
+	return "words"
+}
+
+func (WordTokenizer) Count(text string) int {
+	return countTokens(text)
+}
+
+const (
+	maxWordPieceChars = 100
+	wordPiecePrefix   = "##"
+)
+
+// WordPieceTokenizer counts tokens the way BERT-like embedding models do
+// (mxbai-embed-large, nomic-embed-text, all-minilm, ...), using the vocabulary
+// of the model. Text is lowercased, split on whitespace and punctuation, and
+// every word is split into the longest pieces found in the vocabulary.
+type WordPieceTokenizer struct {
+	path  string
+	vocab map[string]struct{}
+}
+
+// NewWordPieceTokenizer loads a vocab.txt file with one token per line,
+// as published with the model weights.
+func NewWordPieceTokenizer(path string) (*WordPieceTokenizer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	vocab := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if token := strings.TrimRight(scanner.Text(), "\r"); token != "" {
+			vocab[token] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(vocab) == 0 {
+		return nil, fmt.Errorf("vocabulary %s is empty", path)
+	}
+	return &WordPieceTokenizer{path: path, vocab: vocab}, nil
+}
+
+func (t *WordPieceTokenizer) Name() string {
+	return t.path
+}
+
+func (t *WordPieceTokenizer) Count(text string) int {
+	count := 0
+	for _, word := range basicTokenize(text) {
+		count += t.countWord(word)
+	}
+	return count
+}
+
+// countWord returns the number of pieces of the word, an unknown word is one [UNK] token.
+func (t *WordPieceTokenizer) countWord(word string) int {
+	runes := []rune(word)
+	if len(runes) > maxWordPieceChars {
+		return 1
+	}
+
+	pieces := 0
+	for start := 0; start < len(runes); {
+		end := len(runes)
+		for ; end > start; end-- {
+			piece := string(runes[start:end])
+			if start > 0 {
+				piece = wordPiecePrefix + piece
+			}
+			if _, ok := t.vocab[piece]; ok {
+				break
+			}
+		}
+		if end == start {
+			return 1
+		}
+		pieces++
+		start = end
+	}
+	return pieces
+}
+
+// basicTokenize lowercases the text and splits it into words and single punctuation marks.
+func basicTokenize(text string) []string {
+	var words []string
+	var word strings.Builder
+
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsSpace(r) || unicode.IsControl(r):
+			flush()
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.Is(unicode.Han, r):
+			flush()
+			words = append(words, string(r))
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return words
+}
@@ -0,0 +1,107 @@
+// Package rag answers questions from the indexed articles: the question is embedded
+// with the model of the profile, the closest chunks are retrieved and passed to a
+// generative model as context. The API and the evaluation harness share this path,
+// so evaluation results hold for the answers users get.
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/internal/db"
+	"rss_fetcher/internal/embedding"
+	"strings"
+
+	backend "rss_fetcher/internal/ollama"
+)
+
+// DefaultContextChunks is the number of chunks passed to the generative model.
+const DefaultContextChunks = 1
+
+// ErrEmbedding is returned by Retrieve when the embedding model failed, unlike a failure
+// of the store the model server may just be down or still loading the model.
+var ErrEmbedding = errors.New("failed to generate query embedding")
+
+// VectorStore finds the chunks closest to an embedding.
+type VectorStore interface {
+	QueryRelevantDocuments(ctx context.Context, profile data.EmbeddingProfile, embedding []float32, backend string, limit int, scope db.DocumentScope) ([]db.Document, error)
+}
+
+// Generator produces the answer for a prompt.
+type Generator interface {
+	Generate(ctx context.Context, prompt *backend.Prompt) (string, error)
+}
+
+type Pipeline struct {
+	store     VectorStore
+	embedders embedding.Provider
+}
+
+func New(store VectorStore, embedders embedding.Provider) *Pipeline {
+	return &Pipeline{store: store, embedders: embedders}
+}
+
+// Embed returns the embedding of a question or a saved search query by the model of the profile.
+// Failures of the model are ErrEmbedding.
+func (p *Pipeline) Embed(ctx context.Context, profile data.EmbeddingProfile, text string) ([]float32, error) {
+	embeddings, err := p.embedders.Embedder(profile.Model).Embed(ctx, []string{text})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEmbedding, err)
+	}
+	return embeddings[0], nil
+}
+
+// Retrieve returns up to limit chunks of the profile in the scope closest to the question, most similar first.
+func (p *Pipeline) Retrieve(ctx context.Context, profile data.EmbeddingProfile, question string, limit int, scope db.DocumentScope) ([]db.Document, error) {
+	embedding, err := p.Embed(ctx, profile, question)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := p.store.QueryRelevantDocuments(ctx, profile, embedding, "ollama", limit, scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve relevant documents: %w", err)
+	}
+	return docs, nil
+}
+
+// Answer asks the generator to answer the question using the retrieved chunks.
+func Answer(ctx context.Context, generator Generator, question string, docs []db.Document) (string, error) {
+	// Augment the query with retrieved context
+	augmentedQuery := db.CombineQueryWithContext(question, docs)
+
+	prompt := backend.NewPrompt().
+		AddMessage("system", "You — are AI assistent. Use the provided context to answer the user question").
+		AddMessage("user", augmentedQuery).
+		SetParameters(backend.Parameters{
+			MaxTokens:   150, // Supported by LLaMa
+			Temperature: 0.7, // Supported by LLaMa
+			TopP:        0.9, // Supported by LLaMa
+		})
+
+	return generator.Generate(ctx, prompt)
+}
+
+// maxSummaryInput is the number of characters of an article passed to the generative model.
+const maxSummaryInput = 8000
+
+// Summarize asks the generator for a short summary of an article.
+func Summarize(ctx context.Context, generator Generator, title, text string) (string, error) {
+	if runes := []rune(text); len(runes) > maxSummaryInput {
+		text = string(runes[:maxSummaryInput])
+	}
+	prompt := backend.NewPrompt().
+		AddMessage("system", "You — are AI assistent. Summarize the news article in two or three sentences, answer with the summary only").
+		AddMessage("user", "Title: "+title+"\n\n"+text).
+		SetParameters(backend.Parameters{
+			MaxTokens:   150,
+			Temperature: 0.3,
+			TopP:        0.9,
+		})
+
+	summary, err := generator.Generate(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(summary), nil
+}
@@ -2,16 +2,19 @@ package api
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"log"
 	"net/http"
+	"rss_fetcher/internal/data"
 	"rss_fetcher/internal/db"
+	"rss_fetcher/internal/embedding"
+	"rss_fetcher/internal/rag"
 	"strconv"
 	"time"
 
 	backend "rss_fetcher/internal/ollama"
 
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/labstack/echo/v4"
 )
 
@@ -19,81 +22,200 @@ type addChannelRequest struct {
 	Link string `json:"link"`
 }
 
+// updateChannelRequest changes only the fields it contains.
+type updateChannelRequest struct {
+	Link         *string            `json:"link"`
+	Name         *string            `json:"name"`
+	Paused       *bool              `json:"paused"`
+	PollInterval *string            `json:"poll_interval"` // e.g. 30m, 0 uses the interval of channel-service
+	FullArticles *bool              `json:"full_articles"`
+	Headers      *map[string]string `json:"headers"` // replaces all custom headers
+	Auth         *channelAuth       `json:"auth"`    // sets the Authorization header
+}
+
+type channelAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Token    string `json:"token"` // bearer token, instead of username and password
+}
+
+type addSearchRequest struct {
+	Name       string   `json:"name"`
+	Query      string   `json:"query"`
+	Threshold  *float32 `json:"threshold"` // defaultSearchThreshold when missing, 0 matches everything
+	WebhookURL string   `json:"webhook_url"`
+	Secret     string   `json:"secret"`
+	GroupID    int      `json:"group_id"` // alerts only for news of the channels in the group
+}
+
+const defaultSearchThreshold = 0.75
+
 type API struct {
-	dbConn     *pgx.Conn
-	vectorDB   *db.PGVector
-	ollamaHost string
-	embModel   string
-	genModel   string
+	store         db.Store
+	rag           *rag.Pipeline
+	ollamaHost    string
+	genModel      string
+	contextChunks int // chunks passed to the generative model
 }
 
-func New(dbConn *pgx.Conn, vectorDB *db.PGVector, host, embModel, genModel string) *API {
-	return &API{dbConn: dbConn, vectorDB: vectorDB, embModel: embModel, genModel: genModel, ollamaHost: host}
+func New(store db.Store, embedders embedding.Provider, host, genModel string, contextChunks int) *API {
+	return &API{store: store, rag: rag.New(store, embedders), genModel: genModel, ollamaHost: host,
+		contextChunks: max(contextChunks, 1)}
+}
+
+// activeProfile returns the embedding profile serving queries. It is loaded on every
+// request, so switching profiles after reindexing needs no restart. Its errors are
+// returned by the handlers as they are.
+func (api *API) activeProfile(ctx context.Context) (*data.EmbeddingProfile, error) {
+	profile, err := api.store.LoadActiveProfile(ctx)
+	if err != nil {
+		return nil, failed("Error loading embedding profile", err)
+	}
+	if profile == nil {
+		return nil, modelUnavailable("No active embedding profile", nil)
+	}
+	return profile, nil
 }
 
 func (api *API) AddChannel(c echo.Context) error {
 	var channel addChannelRequest
 	if err := c.Bind(&channel); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return invalidBody(err)
 	}
 	if channel.Link == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Link is required"})
+		return invalid("Link is required")
 	}
-	if err := db.AddChannel(api.dbConn, channel.Link); err != nil {
-		if pgErr, ok := err.(*pgconn.PgError); ok {
-			if pgErr.Code == "23505" {
-				log.Printf("Channel with link: %v already added to the channels list", channel.Link)
-				return c.JSON(http.StatusConflict, map[string]string{"error": "Channel is already exists"})
-			}
-			log.Printf("Postgres error: %s\n", pgErr.Message)
-		}
-		log.Printf("Failed to add channel: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to add channel"})
+	if _, err := api.store.AddChannel(c.Request().Context(), channel.Link); err != nil {
+		return failed("Failed to add channel", err)
 	}
 	return c.JSON(http.StatusCreated, map[string]string{"status": "ok"})
 }
 
+// GetChannels lists channels in the order they were added, a page at a time (?limit, ?cursor).
 func (api *API) GetChannels(c echo.Context) error {
-	channels, err := db.LoadChannels(api.dbConn)
+	filter, err := channelFilter(c)
 	if err != nil {
-		log.Printf("Error loading channels from database: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load channels"})
+		return invalidParameter(err.Error())
+	}
+	channels, err := api.store.LoadChannels(c.Request().Context(), filter)
+	if err != nil {
+		return failed("Failed to load channels", err)
 	}
 
-	return c.JSON(http.StatusOK, channels)
+	return c.JSON(http.StatusOK, newPage(channels, filter.Limit-1, func(last data.Channel) any {
+		return idCursor{ID: last.ID}
+	}))
 }
 
 func (api *API) GetChannel(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		log.Printf("Wrong id: %v", err)
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid channel ID	"})
+		return invalidParameter("Invalid channel ID")
 	}
-	result, err := db.LoadChannel(api.dbConn, id)
+	result, err := api.store.LoadChannel(c.Request().Context(), id)
 	if err != nil {
-		log.Printf("Error loading channel from database: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load channel"})
+		return failed("Failed to load channel", err)
+	}
+	if result == nil {
+		return notFound("channel", id)
 	}
 	return c.JSON(http.StatusOK, result)
 }
 
+// UpdateChannel changes the link or the settings of a channel, its news are kept.
+func (api *API) UpdateChannel(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return invalidParameter("Invalid channel ID")
+	}
+	var request updateChannelRequest
+	if err := c.Bind(&request); err != nil {
+		return invalidBody(err)
+	}
+
+	channels, err := api.store.LoadChannels(c.Request().Context(), db.ChannelFilter{ID: id})
+	if err != nil {
+		return failed("Failed to load channel", err)
+	}
+	if len(channels) == 0 {
+		return notFound("channel", id)
+	}
+	channel := channels[0]
+	if err := request.apply(&channel); err != nil {
+		return invalid(err.Error())
+	}
+
+	if err := api.store.UpdateChannel(c.Request().Context(), channel); err != nil {
+		return failed("Failed to update channel", err)
+	}
+	return c.JSON(http.StatusOK, channel)
+}
+
+func (r updateChannelRequest) apply(channel *data.Channel) error {
+	if r.Link != nil {
+		if *r.Link == "" {
+			return errors.New("Link must not be empty")
+		}
+		channel.Link = *r.Link
+	}
+	if r.Name != nil {
+		channel.Name = *r.Name
+	}
+	if r.Paused != nil {
+		channel.Paused = *r.Paused
+	}
+	if r.PollInterval != nil {
+		interval, err := time.ParseDuration(*r.PollInterval)
+		if err != nil || interval < 0 {
+			return errors.New("Poll interval must be a duration like 30m, or 0")
+		}
+		channel.PollInterval = interval.Truncate(time.Second)
+	}
+	if r.FullArticles != nil {
+		channel.FullArticles = *r.FullArticles
+	}
+	if r.Headers != nil {
+		channel.Headers = make(map[string]string, len(*r.Headers))
+		for name, value := range *r.Headers {
+			if name == "" {
+				return errors.New("Header names must not be empty")
+			}
+			channel.Headers[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+	if r.Auth != nil {
+		var authorization string
+		switch {
+		case r.Auth.Token != "" && r.Auth.Username == "":
+			authorization = "Bearer " + r.Auth.Token
+		case r.Auth.Token == "" && r.Auth.Username != "":
+			authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(r.Auth.Username+":"+r.Auth.Password))
+		default:
+			return errors.New("Auth needs either a token or a username and password")
+		}
+		if channel.Headers == nil {
+			channel.Headers = make(map[string]string)
+		}
+		channel.Headers["Authorization"] = authorization
+	}
+	return nil
+}
+
 func (api *API) DeleteChannels(c echo.Context) error {
-	if err := db.DeleteChannels(api.dbConn); err != nil {
-		log.Printf("Error deleting channels: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete channels"})
+	if err := api.store.DeleteChannels(c.Request().Context()); err != nil {
+		return failed("Failed to delete channels", err)
 	}
+	log.Printf("All channels deleted by %s", callerName(c))
 	return c.JSON(http.StatusOK, map[string]string{"message": "All channels deleted"})
 }
 
 func (api *API) DeleteChannel(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		log.Printf("Wrong channel id: %v", err)
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid channel ID"})
+		return invalidParameter("Invalid channel ID")
 	}
-	if err := db.DeleteChannel(api.dbConn, id); err != nil {
-		log.Printf("Error deleting channel: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to	delete channel"})
+	if err := api.store.DeleteChannel(c.Request().Context(), id); err != nil {
+		return failed("Failed to delete channel", err)
 	}
 	return c.JSON(http.StatusOK, map[string]string{"message": "Channel deleted"})
 }
@@ -101,61 +223,191 @@ func (api *API) DeleteChannel(c echo.Context) error {
 func (api *API) DeleteNews(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		log.Printf("Wrong news id: %v", err)
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid news ID"})
+		return invalidParameter("Invalid news ID")
 	}
-	if err := db.DeleteNews(api.dbConn, id); err != nil {
-		log.Printf("Error in deleting news: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to	delete news"})
+	if err := api.store.DeleteNews(c.Request().Context(), id); err != nil {
+		return failed("Failed to delete news", err)
 	}
 	return c.JSON(http.StatusOK, map[string]string{"message": "News deleted"})
 }
 
+// GetAllNews lists news a page at a time (?limit, ?cursor), sorted by id or publication
+// date (?sort=pub_date|-pub_date) and filtered by ?channel, ?from, ?to, ?author,
+// ?category and ?title. Users get the news of their subscriptions with their state,
+// ?unread and ?starred filter by it.
 func (api *API) GetAllNews(c echo.Context) error {
-	result, err := db.LoadNews(db.NewConnectionQuery(api.dbConn))
+	filter, err := newsFilter(c)
+	if err != nil {
+		return invalidParameter(err.Error())
+	}
+	filter.UserID = callerUserID(c)
+	if filter.UserID == 0 && (filter.Unread || filter.Starred) {
+		return invalidParameter("unread and starred need the credentials of a user")
+	}
+	result, err := api.store.LoadNews(c.Request().Context(), filter)
 	if err != nil {
-		log.Printf("Error loading news from database: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load news"})
+		return failed("Failed to load news", err)
 	}
 
-	return c.JSON(http.StatusOK, result)
+	return c.JSON(http.StatusOK, newPage(result, filter.Limit-1, func(last data.ChannelNews) any {
+		return newsCursor{Order: filter.Order, PubDate: last.PubDate, ID: last.ID}
+	}))
 }
 
+// GetNewsContent serves the readable version of an article as JSON (default),
+// or as bare html or text with ?format=html|text for a reader view.
+func (api *API) GetNewsContent(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return invalidParameter("Invalid news ID")
+	}
+	content, err := api.store.LoadNewsContent(c.Request().Context(), id)
+	if err != nil {
+		return failed("Failed to load news content", err)
+	}
+	if content == nil {
+		return notFound("content", id)
+	}
+
+	switch c.QueryParam("format") {
+	case "", "json":
+		return c.JSON(http.StatusOK, content)
+	case "html":
+		// the markup comes from third-party pages, scripts must not run on our origin
+		c.Response().Header().Set("Content-Security-Policy", "default-src 'none'; img-src * data:; style-src 'unsafe-inline'")
+		return c.HTML(http.StatusOK, content.HTML)
+	case "text":
+		return c.String(http.StatusOK, content.Text)
+	default:
+		return invalidParameter("Format must be json, html or text")
+	}
+}
+
+// GetJobs lists jobs in the order they were queued, a page at a time (?limit, ?cursor),
+// optionally only those with a ?status.
 func (api *API) GetJobs(c echo.Context) error {
-	items, err := db.LoadJobs(db.NewConnectionQuery(api.dbConn), "")
+	filter, err := jobFilter(c)
+	if err != nil {
+		return invalidParameter(err.Error())
+	}
+	items, err := api.store.LoadJobs(c.Request().Context(), filter)
 	if err != nil {
-		log.Printf("Error loading channels from database: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load jobs"})
+		return failed("Failed to load jobs", err)
 	}
-	return c.JSON(http.StatusOK, items)
+	return c.JSON(http.StatusOK, newPage(items, filter.Limit-1, func(last data.NewsJob) any {
+		return idCursor{ID: last.ID}
+	}))
 }
 
-func (api *API) GetQuery(c echo.Context) error {
-	q := c.Param("q")
+func (api *API) AddSearch(c echo.Context) error {
+	var request addSearchRequest
+	if err := c.Bind(&request); err != nil {
+		return invalidBody(err)
+	}
+	if request.Query == "" || request.WebhookURL == "" {
+		return invalid("Query and webhook_url are required")
+	}
+	threshold := float32(defaultSearchThreshold)
+	if request.Threshold != nil {
+		threshold = *request.Threshold
+	}
+	if threshold < 0 || threshold > 1 {
+		return invalid("Threshold must be between 0 and 1")
+	}
+	if request.Name == "" {
+		request.Name = request.Query
+	}
 
-	headers := map[string]string{
-		"Content-Type": "application/json",
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 60*time.Second)
+	defer cancel()
+
+	if request.GroupID != 0 {
+		if group, err := api.store.LoadGroup(ctx, request.GroupID); err != nil {
+			return failed("Failed to load group", err)
+		} else if group == nil {
+			return invalidReference("Group not found")
+		}
 	}
 
-	embeddingBackend := backend.NewOllamaBackend(api.ollamaHost, api.embModel, time.Duration(60*time.Second))
-	generationBackend := backend.NewOllamaBackend(api.ollamaHost, api.genModel, time.Duration(60*time.Second))
+	profile, err := api.activeProfile(ctx)
+	if err != nil {
+		return err
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	// the query is embedded like the questions of GetQuery, so the vectors are comparable
+	vector, err := api.rag.Embed(ctx, *profile, request.Query)
+	if err != nil {
+		return modelUnavailable("Error generating search embedding", err)
+	}
+
+	search := data.SavedSearch{
+		Name:       request.Name,
+		Query:      request.Query,
+		Threshold:  threshold,
+		WebhookURL: request.WebhookURL,
+		Secret:     request.Secret,
+		GroupID:    request.GroupID,
+	}
+	id, err := api.store.AddSavedSearch(ctx, search, *profile, vector)
+	if err != nil {
+		return failed("Failed to add saved search", err)
+	}
+	return c.JSON(http.StatusCreated, map[string]interface{}{"status": "ok", "id": id})
+}
+
+func (api *API) GetSearches(c echo.Context) error {
+	searches, err := api.store.LoadSavedSearches(c.Request().Context())
+	if err != nil {
+		return failed("Failed to load saved searches", err)
+	}
+	return c.JSON(http.StatusOK, searches)
+}
+
+func (api *API) DeleteSearch(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return invalidParameter("Invalid saved search ID")
+	}
+	if err := api.store.DeleteSavedSearch(c.Request().Context(), id); err != nil {
+		return failed("Failed to delete saved search", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Saved search deleted"})
+}
+
+// GetQuery answers the question from the closest chunks, of the channels in a group with ?group.
+// The chunks of users come from the channels they subscribe to.
+func (api *API) GetQuery(c echo.Context) error {
+	q := c.Param("q")
+	groupID, err := groupParam(c)
+	if err != nil {
+		return invalidParameter("Invalid group ID")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 60*time.Second)
 	defer cancel()
 
-	// Embed the query using the specified embedding backend
-	queryEmbedding, err := embeddingBackend.Embed(ctx, q, headers)
+	if groupID != 0 {
+		if group, err := api.store.LoadGroup(ctx, groupID); err != nil {
+			return failed("Failed to load group", err)
+		} else if group == nil {
+			return notFound("group", groupID)
+		}
+	}
+
+	profile, err := api.activeProfile(ctx)
 	if err != nil {
-		log.Printf("Error generating query embedding: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error generating query embedding"})
+		return err
 	}
-	log.Println("Vector embeddings generated")
 
-	// Retrieve relevant documents for the query embedding
-	retrievedDocs, err := api.vectorDB.QueryRelevantDocuments(ctx, queryEmbedding, "ollama")
+	generationBackend := backend.NewOllamaBackend(api.ollamaHost, api.genModel, time.Duration(60*time.Second))
+
+	// Retrieve relevant documents for the query
+	retrievedDocs, err := api.rag.Retrieve(ctx, *profile, q, api.contextChunks, db.DocumentScope{GroupID: groupID, UserID: callerUserID(c)})
+	if errors.Is(err, rag.ErrEmbedding) {
+		return modelUnavailable("Error generating query embedding", err)
+	}
 	if err != nil {
-		log.Printf("Error retrieving relevant documents: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error retrieving relevant documents"})
+		return failed("Error retrieving relevant documents", err)
 	}
 
 	// Log the retrieved documents to see if they include the inserted content
@@ -163,26 +415,10 @@ func (api *API) GetQuery(c echo.Context) error {
 		log.Printf("Retrieved Document: %v", doc)
 	}
 
-	// Augment the query with retrieved context
-	augmentedQuery := db.CombineQueryWithContext(q, retrievedDocs)
-
-	log.Println("READY FOR GENERATING PROMPT")
-
-	prompt := backend.NewPrompt().
-		AddMessage("system", "You — are AI assistent. Use the provided context to answer the user question").
-		AddMessage("user", augmentedQuery).
-		SetParameters(backend.Parameters{
-			MaxTokens:   150, // Supported by LLaMa
-			Temperature: 0.7, // Supported by LLaMa
-			TopP:        0.9, // Supported by LLaMa
-		})
-
-	log.Println("PROMPT PREAPARED")
 	// Generate response with the specified generation backend
-	response, err := generationBackend.Generate(ctx, prompt)
+	response, err := rag.Answer(ctx, generationBackend, q, retrievedDocs)
 	if err != nil {
-		log.Printf("Error generating response: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Error generating response"})
+		return modelUnavailable("Error generating response", err)
 	}
 
 	return c.JSON(http.StatusOK,
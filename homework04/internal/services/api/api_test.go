@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/internal/db"
+	"rss_fetcher/internal/embedding"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// downEmbedders fails like an unreachable model server and records the requested models.
+type downEmbedders struct {
+	models []string
+}
+
+func (d *downEmbedders) Embedder(model string) embedding.Embedder {
+	d.models = append(d.models, model)
+	return d
+}
+
+func (d *downEmbedders) Embed(context.Context, []string) ([][]float32, error) {
+	return nil, errors.New("connection refused")
+}
+
+// TestAddSearchEmbedding checks that saved search queries are embedded by the shared
+// provider with the model of the active profile, and that its failures are
+// answered with model_unavailable.
+func TestAddSearchEmbedding(t *testing.T) {
+	ctx := context.Background()
+	addSearch := func(embedders embedding.Provider) (db.Store, *httptest.ResponseRecorder, error) {
+		t.Helper()
+		store := db.NewMemory()
+		if _, err := store.AddProfile(ctx, data.EmbeddingProfile{Name: "test", Model: "test-model", Dims: 8, Status: data.ProfileActive, Chunker: "sentence"}); err != nil {
+			t.Fatalf("AddProfile: %v", err)
+		}
+		server := New(store, embedders, "", "", 1)
+		body := `{"query": "postgres release", "webhook_url": "http://localhost:9090"}`
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/searches", strings.NewReader(body))
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		recorder := httptest.NewRecorder()
+		return store, recorder, server.AddSearch(echo.New().NewContext(request, recorder))
+	}
+
+	store, recorder, err := addSearch(embedding.NewHashing(8))
+	if err != nil || recorder.Code != http.StatusCreated {
+		t.Fatalf("AddSearch = %d, %v, want 201", recorder.Code, err)
+	}
+	if searches, err := store.LoadSavedSearches(ctx); err != nil || len(searches) != 1 {
+		t.Errorf("saved searches = %+v, %v, want the search", searches, err)
+	}
+
+	down := &downEmbedders{}
+	_, _, err = addSearch(down)
+	if problem := problemOf(err); problem.Code != CodeModelUnavailable {
+		t.Errorf("AddSearch with the model down = %+v (%v), want %s", problem, err, CodeModelUnavailable)
+	}
+	if len(down.models) != 1 || down.models[0] != "test-model" {
+		t.Errorf("embedders requested for %v, want the model of the profile", down.models)
+	}
+}
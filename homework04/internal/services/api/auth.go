@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"rss_fetcher/internal/auth"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/openapi"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// callerKey keeps the *Caller of an authenticated request in the echo context.
+const callerKey = "caller"
+
+// Caller is who sent a request, known once it is authenticated.
+type Caller struct {
+	Name   string // name of the API key or subject of the token
+	Scope  data.APIScope
+	KeyID  int // 0 for tokens
+	UserID int // user the key belongs to or the subject of the token is, 0 for services
+}
+
+// callerOf returns the caller of the request, nil when authentication is off or the
+// operation is public.
+func callerOf(c echo.Context) *Caller {
+	caller, _ := c.Get(callerKey).(*Caller)
+	return caller
+}
+
+// callerName names the caller in logs.
+func callerName(c echo.Context) string {
+	if caller := callerOf(c); caller != nil {
+		return caller.Name
+	}
+	return "anonymous"
+}
+
+// Authenticate lets a request through when its credentials meet one of the security
+// requirements of its operation in the specification: an API key in X-API-Key, an API
+// key or a token of the issuer as bearer, or ?key= where the operation allows it (feeds).
+// Missing or invalid credentials are 401 unauthorized, credentials of a scope which
+// does not allow the operation 403 forbidden. Tokens are refused when issuer is nil.
+func (api *API) Authenticate(spec *openapi.Document, issuer *auth.Issuer) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			operation := spec.Operation(c.Request().Method, specPath(c.Path()))
+			if operation == nil {
+				// unknown routes are answered with 404
+				return next(c)
+			}
+			requirements := spec.Requirements(operation)
+			if len(requirements) == 0 {
+				return next(c)
+			}
+
+			forbidden := false
+			for _, requirement := range requirements {
+				for name, scopes := range requirement {
+					credential := credentialOf(c, spec.Components.SecuritySchemes[name])
+					if credential == "" {
+						continue
+					}
+					caller, err := api.authenticate(c.Request().Context(), credential, issuer)
+					if err != nil {
+						return err
+					}
+					if !allows(caller.Scope, scopes) {
+						forbidden = true
+						continue
+					}
+					c.Set(callerKey, caller)
+					return next(c)
+				}
+			}
+			if forbidden {
+				return &apiError{status: http.StatusForbidden, code: CodeForbidden, detail: "The scope of the credentials does not allow this operation"}
+			}
+			return unauthorized("An API key or a token is required")
+		}
+	}
+}
+
+// credentialOf reads the credential of the security scheme, empty when the request has none.
+func credentialOf(c echo.Context, scheme *openapi.SecurityScheme) string {
+	if scheme == nil {
+		return ""
+	}
+	switch {
+	case scheme.Type == "apiKey" && scheme.In == "header":
+		return strings.TrimSpace(c.Request().Header.Get(scheme.Name))
+	case scheme.Type == "apiKey" && scheme.In == "query":
+		return c.QueryParam(scheme.Name)
+	case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "bearer"):
+		header := c.Request().Header.Get(echo.HeaderAuthorization)
+		if len(header) > len("Bearer ") && strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+			return strings.TrimSpace(header[len("Bearer "):])
+		}
+	}
+	return ""
+}
+
+// authenticate finds the API key or verifies the token.
+func (api *API) authenticate(ctx context.Context, credential string, issuer *auth.Issuer) (*Caller, error) {
+	if auth.IsKey(credential) {
+		key, err := api.store.LoadAPIKeyByHash(ctx, auth.HashKey(credential))
+		if err != nil {
+			return nil, failed("Failed to load API key", err)
+		}
+		if key == nil {
+			return nil, unauthorized("Unknown API key")
+		}
+		if err := api.store.MarkAPIKeyUsed(ctx, key.ID); err != nil {
+			log.Printf("Error recording use of API key %d: %v", key.ID, err)
+		}
+		return &Caller{Name: key.Name, Scope: key.Scope, KeyID: key.ID, UserID: key.UserID}, nil
+	}
+	if issuer == nil {
+		return nil, unauthorized("Tokens are not accepted, use an API key")
+	}
+	claims, err := issuer.Verify(credential)
+	if err != nil {
+		return nil, unauthorized("Invalid token: " + err.Error())
+	}
+	caller := &Caller{Name: claims.Subject, Scope: claims.Scope}
+	user, err := api.store.LoadUserByName(ctx, claims.Subject)
+	if err != nil {
+		return nil, failed("Failed to load user", err)
+	}
+	if user != nil {
+		caller.UserID = user.ID
+	}
+	return caller, nil
+}
+
+// callerUser returns the user of the caller, 403 when the credentials do not belong to one.
+func callerUser(c echo.Context) (int, error) {
+	if caller := callerOf(c); caller != nil && caller.UserID != 0 {
+		return caller.UserID, nil
+	}
+	return 0, &apiError{status: http.StatusForbidden, code: CodeForbidden, detail: "The credentials do not belong to a user"}
+}
+
+// callerUserID returns the user of the caller, 0 for services and when authentication is off.
+func callerUserID(c echo.Context) int {
+	if caller := callerOf(c); caller != nil {
+		return caller.UserID
+	}
+	return 0
+}
+
+func allows(scope data.APIScope, required []string) bool {
+	for _, name := range required {
+		if !scope.Allows(data.APIScope(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+func unauthorized(detail string) error {
+	return &apiError{status: http.StatusUnauthorized, code: CodeUnauthorized, detail: detail}
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/internal/db"
+	"rss_fetcher/internal/parser"
+	"rss_fetcher/internal/rag"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	backend "rss_fetcher/internal/ollama"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// maxFeedSummaries limits the summaries written during one feed request, the
+	// other items keep their description until a later request.
+	maxFeedSummaries = 5
+	summaryTimeout   = 60 * time.Second
+)
+
+// errFeedNotFound answers feed names without a known format.
+var errFeedNotFound = &apiError{status: http.StatusNotFound, code: "feed_not_found", detail: "Feed not found"}
+
+// feedFormats maps the extension of a feed to its content type.
+var feedFormats = map[string]string{
+	".xml":  "application/rss+xml; charset=UTF-8",
+	".rss":  "application/rss+xml; charset=UTF-8",
+	".atom": "application/atom+xml; charset=UTF-8",
+}
+
+// GetAllFeed serves the latest news of all channels, /feeds/all.xml or /feeds/all.atom.
+func (api *API) GetAllFeed(c echo.Context) error {
+	name, ext, err := feedName(c)
+	if err != nil || name != "all" {
+		return errFeedNotFound
+	}
+	return api.serveFeed(c, ext, "All news", "Latest news of all channels", db.NewsFilter{})
+}
+
+// GetGroupFeed serves the latest news of the channels in a group, /feeds/groups/<id>.xml.
+func (api *API) GetGroupFeed(c echo.Context) error {
+	name, ext, err := feedName(c)
+	if err != nil {
+		return errFeedNotFound
+	}
+	id, err := strconv.Atoi(name)
+	if err != nil {
+		return invalidParameter("Invalid group ID")
+	}
+	group, err := api.store.LoadGroup(c.Request().Context(), id)
+	if err != nil {
+		return failed("Failed to load group", err)
+	}
+	if group == nil {
+		return notFound("group", id)
+	}
+	return api.serveFeed(c, ext, group.Name, "Latest news of the channels in "+group.Name, db.NewsFilter{GroupID: id})
+}
+
+// GetTagFeed serves the latest news of a category, /feeds/tags/<category>.xml.
+func (api *API) GetTagFeed(c echo.Context) error {
+	tag, ext, err := feedName(c)
+	if err != nil || tag == "" {
+		return errFeedNotFound
+	}
+	return api.serveFeed(c, ext, tag, "Latest news in category "+tag, db.NewsFilter{Category: tag})
+}
+
+// GetSearchFeed serves the news which matched a saved search, /feeds/searches/<id>.xml.
+func (api *API) GetSearchFeed(c echo.Context) error {
+	name, ext, err := feedName(c)
+	if err != nil {
+		return errFeedNotFound
+	}
+	id, err := strconv.Atoi(name)
+	if err != nil {
+		return invalidParameter("Invalid search ID")
+	}
+	searches, err := api.store.LoadSavedSearches(c.Request().Context())
+	if err != nil {
+		return failed("Failed to load saved searches", err)
+	}
+	index := slices.IndexFunc(searches, func(search data.SavedSearch) bool { return search.ID == id })
+	if index < 0 {
+		return notFound("search", id)
+	}
+	search := searches[index]
+	title := search.Name
+	if title == "" {
+		title = search.Query
+	}
+	return api.serveFeed(c, ext, title, "News matching "+search.Query, db.NewsFilter{SearchID: id})
+}
+
+// feedName splits the :feed parameter into the name and the extension of the format.
+func feedName(c echo.Context) (string, string, error) {
+	feed, err := url.PathUnescape(c.Param("feed"))
+	if err != nil {
+		return "", "", err
+	}
+	ext := path.Ext(feed)
+	if _, ok := feedFormats[ext]; !ok {
+		return "", "", fmt.Errorf("unknown feed format %q", ext)
+	}
+	return strings.TrimSuffix(feed, ext), ext, nil
+}
+
+// serveFeed writes the newest news of the filter as RSS or Atom. ?limit sets the
+// number of items and ?summary=true replaces descriptions with LLM summaries. The
+// ETag is the hash of the document, so readers polling an unchanged feed get 304.
+func (api *API) serveFeed(c echo.Context, ext, title, description string, filter db.NewsFilter) error {
+	limit, err := pageLimit(c)
+	if err != nil {
+		return invalidParameter(err.Error())
+	}
+	summaries := false
+	if value := c.QueryParam("summary"); value != "" {
+		if summaries, err = strconv.ParseBool(value); err != nil {
+			return invalidParameter("Summary must be true or false")
+		}
+	}
+
+	ctx := c.Request().Context()
+	filter.Order, filter.Limit = db.NewsByPubDateDesc, limit
+	news, err := api.store.LoadNews(ctx, filter)
+	if err != nil {
+		return failed("Failed to load news", err)
+	}
+
+	descriptions := make(map[int]string, len(news))
+	if summaries {
+		if descriptions, err = api.feedSummaries(ctx, news); err != nil {
+			return failed("Failed to load news summaries", err)
+		}
+	}
+
+	feed := parser.Feed{
+		Title:       title,
+		Link:        feedLink(c),
+		Description: description,
+		Items:       make([]parser.FeedItem, len(news)),
+	}
+	for i, item := range news {
+		guid := item.GUID
+		if guid == "" {
+			guid = item.Link
+		}
+		text, ok := descriptions[item.ID]
+		if !ok {
+			text = item.Description
+		}
+		feed.Items[i] = parser.FeedItem{GUID: guid, Title: item.Title, Link: item.Link, Description: text,
+			Author: item.Author, Category: item.Category, PubDate: item.PubDate}
+		if item.PubDate.After(feed.Updated) {
+			feed.Updated = item.PubDate
+		}
+	}
+
+	var body strings.Builder
+	if ext == ".atom" {
+		err = parser.WriteAtom(&body, feed)
+	} else {
+		err = parser.WriteRSS(&body, feed)
+	}
+	if err != nil {
+		return failed("Failed to write feed", err)
+	}
+
+	hash := sha256.Sum256([]byte(body.String()))
+	etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+	c.Response().Header().Set("ETag", etag)
+	if etagMatches(c.Request().Header.Get("If-None-Match"), etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.Blob(http.StatusOK, feedFormats[ext], []byte(body.String()))
+}
+
+// feedParams are the query parameters kept in the self link of a feed. Readers cache
+// and share the document, so the ?key= credential must not be written into it.
+var feedParams = []string{"limit", "summary"}
+
+// feedLink is the self link of the feed, the route path with the parameters which
+// change its content.
+func feedLink(c echo.Context) string {
+	query := url.Values{}
+	for _, param := range feedParams {
+		if value := c.QueryParam(param); value != "" {
+			query.Set(param, value)
+		}
+	}
+	link := url.URL{Scheme: c.Scheme(), Host: c.Request().Host, Path: c.Request().URL.Path, RawQuery: query.Encode()}
+	return link.String()
+}
+
+// etagMatches compares the If-None-Match header with the ETag, weakly as RFC 9110 asks.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// feedSummaries returns the stored summaries of the news and writes up to
+// maxFeedSummaries missing ones with the generative model. Items which could not be
+// summarized are left out and keep their description.
+func (api *API) feedSummaries(ctx context.Context, news []data.ChannelNews) (map[int]string, error) {
+	ids := make([]int, len(news))
+	for i, item := range news {
+		ids[i] = item.ID
+	}
+	summaries, err := api.store.LoadNewsSummaries(ctx, api.genModel, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, summaryTimeout)
+	defer cancel()
+	generator := backend.NewOllamaBackend(api.ollamaHost, api.genModel, summaryTimeout)
+	written := 0
+	for _, item := range news {
+		if _, ok := summaries[item.ID]; ok || written == maxFeedSummaries || ctx.Err() != nil {
+			continue
+		}
+		written++
+		summary, err := api.summarize(ctx, generator, item)
+		if err != nil {
+			log.Printf("Error summarizing news %d: %v", item.ID, err)
+			continue
+		}
+		summaries[item.ID] = summary
+	}
+	return summaries, nil
+}
+
+// summarize writes and stores the summary of the stored article, or of the feed
+// description when the article was not fetched.
+func (api *API) summarize(ctx context.Context, generator rag.Generator, news data.ChannelNews) (string, error) {
+	content, err := api.store.LoadNewsContent(ctx, news.ID)
+	if err != nil {
+		return "", err
+	}
+	if content == nil || content.Text == "" {
+		if content, err = parser.FeedArticle(news); err != nil {
+			return "", err
+		}
+	}
+	if content.Text == "" {
+		return "", fmt.Errorf("no text to summarize")
+	}
+	summary, err := rag.Summarize(ctx, generator, news.Title, content.Text)
+	if err != nil {
+		return "", err
+	}
+	if summary == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return summary, api.store.SaveNewsSummary(ctx, news.ID, api.genModel, summary)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/internal/db"
+	"rss_fetcher/internal/embedding"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TestFeedHidesKey checks that the ?key= credential of a feed reader is neither
+// written into the feed nor changes its ETag.
+func TestFeedHidesKey(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewMemory()
+	channelID, err := store.AddChannel(ctx, "https://example.com/feed")
+	if err != nil {
+		t.Fatalf("AddChannel: %v", err)
+	}
+	if _, err := store.AddNewsWithJob(ctx, channelID, data.ChannelNews{Title: "First", Link: "https://example.com/first", GUID: "first"}); err != nil {
+		t.Fatalf("AddNewsWithJob: %v", err)
+	}
+	server := New(store, embedding.NewHashing(8), "", "", 1)
+
+	const key = "rss_secretfeedkey"
+	get := func(feed, target string) *httptest.ResponseRecorder {
+		t.Helper()
+		e := echo.New()
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, target, nil), recorder)
+		c.SetParamNames("feed")
+		c.SetParamValues(feed)
+		if err := server.GetAllFeed(c); err != nil {
+			t.Fatalf("GetAllFeed(%s): %v", target, err)
+		}
+		return recorder
+	}
+
+	for _, feed := range []string{"all.xml", "all.atom"} {
+		withKey := get(feed, "/feeds/"+feed+"?key="+key+"&limit=5")
+		body := withKey.Body.String()
+		if strings.Contains(body, key) {
+			t.Errorf("%s contains the API key:\n%s", feed, body)
+		}
+		if !strings.Contains(body, "http://example.com/feeds/"+feed+"?limit=5") {
+			t.Errorf("%s has no self link with the limit:\n%s", feed, body)
+		}
+		withoutKey := get(feed, "/feeds/"+feed+"?limit=5")
+		if got, want := withKey.Header().Get("ETag"), withoutKey.Header().Get("ETag"); got != want {
+			t.Errorf("%s ETag with the key %s, without %s", feed, got, want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"rss_fetcher/internal/db"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+type groupRequest struct {
+	Name string `json:"name"`
+}
+
+// AddGroup creates an empty group of channels.
+func (api *API) AddGroup(c echo.Context) error {
+	var request groupRequest
+	if err := c.Bind(&request); err != nil {
+		return invalidBody(err)
+	}
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return invalid("Name is required")
+	}
+	ctx := c.Request().Context()
+	id, err := api.store.AddGroup(ctx, name)
+	if err != nil {
+		return failed("Failed to add group", err)
+	}
+	group, err := api.store.LoadGroup(ctx, id)
+	if err != nil {
+		return failed("Failed to load group", err)
+	}
+	if group == nil {
+		// deleted right after it was added
+		return notFound("group", id)
+	}
+	return c.JSON(http.StatusCreated, group)
+}
+
+// GetGroups lists all groups with the ids of their channels, ordered by name.
+func (api *API) GetGroups(c echo.Context) error {
+	groups, err := api.store.LoadGroups(c.Request().Context())
+	if err != nil {
+		return failed("Failed to load channel groups", err)
+	}
+	return c.JSON(http.StatusOK, groups)
+}
+
+func (api *API) GetGroup(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return invalidParameter("Invalid group ID")
+	}
+	group, err := api.store.LoadGroup(c.Request().Context(), id)
+	if err != nil {
+		return failed("Failed to load group", err)
+	}
+	if group == nil {
+		return notFound("group", id)
+	}
+	return c.JSON(http.StatusOK, group)
+}
+
+// UpdateGroup renames a group.
+func (api *API) UpdateGroup(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return invalidParameter("Invalid group ID")
+	}
+	var request groupRequest
+	if err := c.Bind(&request); err != nil {
+		return invalidBody(err)
+	}
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return invalid("Name is required")
+	}
+
+	ctx := c.Request().Context()
+	group, err := api.store.LoadGroup(ctx, id)
+	if err != nil {
+		return failed("Failed to load group", err)
+	}
+	if group == nil {
+		return notFound("group", id)
+	}
+	if err := api.store.RenameGroup(ctx, id, name); err != nil {
+		return failed("Failed to update group", err)
+	}
+	group.Name = name
+	return c.JSON(http.StatusOK, group)
+}
+
+// DeleteGroup deletes a group and the saved searches scoped to it, the channels are kept.
+func (api *API) DeleteGroup(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return invalidParameter("Invalid group ID")
+	}
+	if err := api.store.DeleteGroup(c.Request().Context(), id); err != nil {
+		return failed("Failed to delete group", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Group deleted"})
+}
+
+// AddGroupChannel puts a channel into a group, PUT /groups/:id/channels/:channel.
+func (api *API) AddGroupChannel(c echo.Context) error {
+	groupID, channelID, err := groupChannelParams(c)
+	if err != nil {
+		return invalidParameter(err.Error())
+	}
+	ctx := c.Request().Context()
+	group, err := api.store.LoadGroup(ctx, groupID)
+	if err != nil {
+		return failed("Failed to load group", err)
+	}
+	if group == nil {
+		return notFound("group", groupID)
+	}
+	channels, err := api.store.LoadChannels(ctx, db.ChannelFilter{ID: channelID})
+	if err != nil {
+		return failed("Failed to load channel", err)
+	}
+	if len(channels) == 0 {
+		return notFound("channel", channelID)
+	}
+	if err := api.store.AddGroupChannel(ctx, groupID, channelID); err != nil {
+		return failed("Failed to add channel to group", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Channel added to group"})
+}
+
+// DeleteGroupChannel takes a channel out of a group, the channel is kept.
+func (api *API) DeleteGroupChannel(c echo.Context) error {
+	groupID, channelID, err := groupChannelParams(c)
+	if err != nil {
+		return invalidParameter(err.Error())
+	}
+	if err := api.store.DeleteGroupChannel(c.Request().Context(), groupID, channelID); err != nil {
+		return failed("Failed to remove channel from group", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Channel removed from group"})
+}
+
+func groupChannelParams(c echo.Context) (int, int, error) {
+	groupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, 0, errors.New("Invalid group ID")
+	}
+	channelID, err := strconv.Atoi(c.Param("channel"))
+	if err != nil {
+		return 0, 0, errors.New("Invalid channel ID")
+	}
+	return groupID, channelID, nil
+}
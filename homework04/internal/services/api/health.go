@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/internal/db"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetChannelHealth reports the consecutive failures of a channel with its latest
+// fetches, ?limit sets how many of them are returned.
+func (api *API) GetChannelHealth(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return invalidParameter("Invalid channel ID")
+	}
+	limit, err := pageLimit(c)
+	if err != nil {
+		return invalidParameter(err.Error())
+	}
+
+	ctx := c.Request().Context()
+	channels, err := api.store.LoadChannels(ctx, db.ChannelFilter{ID: id})
+	if err != nil {
+		return failed("Failed to load channel", err)
+	}
+	if len(channels) == 0 {
+		return notFound("channel", id)
+	}
+	fetches, err := api.store.LoadChannelFetches(ctx, id, limit)
+	if err != nil {
+		return failed("Failed to load channel fetches", err)
+	}
+	return c.JSON(http.StatusOK, channelHealth(channels[0], fetches))
+}
+
+// channelHealth summarizes the fetches of the channel, newest first.
+func channelHealth(channel data.Channel, fetches []data.ChannelFetch) data.ChannelHealth {
+	health := data.ChannelHealth{
+		ChannelID:   channel.ID,
+		Failures:    channel.Failures,
+		LastSuccess: channel.LastSuccess,
+		Fetches:     fetches,
+	}
+	succeeded := 0
+	for _, fetch := range fetches {
+		if fetch.Error == "" {
+			succeeded++
+		} else if health.LastError == "" {
+			health.LastError = fetch.Error
+		}
+	}
+	if len(fetches) > 0 {
+		health.SuccessRate = float64(succeeded) / float64(len(fetches))
+	}
+
+	switch {
+	case channel.Paused:
+		health.Status = data.ChannelPaused
+	case channel.Failures > 0:
+		health.Status = data.ChannelFailing
+	case channel.LastSuccess.IsZero():
+		health.Status = data.ChannelUnknown
+	default:
+		health.Status = data.ChannelOK
+	}
+	return health
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/internal/db"
+	"rss_fetcher/internal/parser"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	maxOPMLSize = 5 << 20
+	opmlTitle   = "RSS fetcher channels"
+
+	feedAdded     = "added"
+	feedDuplicate = "duplicate"
+	feedFailed    = "failed"
+)
+
+// importReport tells what happened to every feed of an imported OPML file.
+type importReport struct {
+	Added      int            `json:"added"`
+	Duplicates int            `json:"duplicates"`
+	Failed     int            `json:"failed"`
+	Feeds      []importedFeed `json:"feeds"`
+}
+
+type importedFeed struct {
+	URL       string `json:"url"`
+	Title     string `json:"title,omitempty"`
+	Group     string `json:"group,omitempty"`
+	Status    string `json:"status"` // added, duplicate or failed
+	ChannelID int    `json:"channel_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ImportChannels adds the feeds of an OPML file, sent as the request body or as the
+// "file" field of a form. Feeds already subscribed are reported as duplicates, folders
+// become channel groups and existing channels are added to them too.
+func (api *API) ImportChannels(c echo.Context) error {
+	body, err := opmlBody(c)
+	if err != nil {
+		return invalid(err.Error())
+	}
+	defer body.Close()
+
+	feeds, err := parser.ParseOPML(http.MaxBytesReader(c.Response(), body, maxOPMLSize))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return err
+	}
+	if err != nil {
+		return &apiError{status: http.StatusBadRequest, code: CodeInvalidBody, detail: "Invalid OPML file", cause: err}
+	}
+
+	ctx := c.Request().Context()
+	groups, err := api.groupIDs(ctx)
+	if err != nil {
+		return failed("Failed to load channel groups", err)
+	}
+
+	report := importReport{Feeds: make([]importedFeed, 0, len(feeds))}
+	for _, feed := range feeds {
+		result := api.importFeed(ctx, feed, groups)
+		switch result.Status {
+		case feedAdded:
+			report.Added++
+		case feedDuplicate:
+			report.Duplicates++
+		default:
+			report.Failed++
+		}
+		report.Feeds = append(report.Feeds, result)
+	}
+	log.Printf("Imported OPML: %d added, %d duplicates, %d failed", report.Added, report.Duplicates, report.Failed)
+	return c.JSON(http.StatusOK, report)
+}
+
+// opmlBody returns the uploaded file of a form or the request body.
+func opmlBody(c echo.Context) (io.ReadCloser, error) {
+	if !strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		return c.Request().Body, nil
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, errors.New("OPML file is required in the file field")
+	}
+	return header.Open()
+}
+
+// groupIDs maps the names of the channel groups to their ids.
+func (api *API) groupIDs(ctx context.Context) (map[string]int, error) {
+	groups, err := api.store.LoadGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]int, len(groups))
+	for _, group := range groups {
+		ids[group.Name] = group.ID
+	}
+	return ids, nil
+}
+
+func (api *API) importFeed(ctx context.Context, feed parser.OPMLFeed, groups map[string]int) importedFeed {
+	result := importedFeed{URL: feed.URL, Title: feed.Title, Group: feed.Folder}
+	if link, err := url.Parse(feed.URL); err != nil || (link.Scheme != "http" && link.Scheme != "https") || link.Host == "" {
+		result.Status, result.Error = feedFailed, "Invalid feed URL"
+		return result
+	}
+
+	channels, err := api.store.LoadChannels(ctx, db.ChannelFilter{Link: feed.URL})
+	if err == nil && len(channels) > 0 {
+		result.Status, result.ChannelID = feedDuplicate, channels[0].ID
+	} else if err == nil {
+		result.ChannelID, err = api.store.AddChannel(ctx, feed.URL)
+		if err == nil {
+			result.Status = feedAdded
+			err = api.nameChannel(ctx, result.ChannelID, feed.Title)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to import channel %s: %v", feed.URL, err)
+		if result.Status == "" {
+			result.Status, result.Error = feedFailed, "Failed to add channel"
+			return result
+		}
+		result.Error = "Failed to set channel name"
+	}
+
+	if feed.Folder != "" {
+		if err := api.addToGroup(ctx, groups, feed.Folder, result.ChannelID); err != nil {
+			log.Printf("Failed to add channel %s to group %s: %v", feed.URL, feed.Folder, err)
+			result.Error = "Failed to add channel to group"
+		}
+	}
+	return result
+}
+
+// nameChannel sets the title of the outline as the name of a new channel.
+func (api *API) nameChannel(ctx context.Context, id int, name string) error {
+	if name == "" {
+		return nil
+	}
+	channels, err := api.store.LoadChannels(ctx, db.ChannelFilter{ID: id})
+	if err != nil || len(channels) == 0 {
+		return err
+	}
+	channel := channels[0]
+	channel.Name = name
+	return api.store.UpdateChannel(ctx, channel)
+}
+
+// addToGroup adds the channel to the group, which is created when it does not exist.
+func (api *API) addToGroup(ctx context.Context, groups map[string]int, name string, channelID int) error {
+	groupID, ok := groups[name]
+	if !ok {
+		var err error
+		groupID, err = api.store.AddGroup(ctx, name)
+		if errors.Is(err, db.ErrConflict) {
+			// created by a concurrent import
+			reloaded, err := api.groupIDs(ctx)
+			if err != nil {
+				return err
+			}
+			groupID = reloaded[name]
+		} else if err != nil {
+			return err
+		}
+		groups[name] = groupID
+	}
+	return api.store.AddGroupChannel(ctx, groupID, channelID)
+}
+
+// ExportChannels returns all channels as an OPML file. Channels are nested in the
+// folders of their groups, a channel in several groups appears in each of them and
+// channels without a group are at the top level.
+func (api *API) ExportChannels(c echo.Context) error {
+	ctx := c.Request().Context()
+	channels, err := api.store.LoadChannels(ctx, db.ChannelFilter{})
+	if err != nil {
+		return failed("Failed to load channels", err)
+	}
+	groups, err := api.store.LoadGroups(ctx)
+	if err != nil {
+		return failed("Failed to load channel groups", err)
+	}
+
+	byID := make(map[int]data.Channel, len(channels))
+	for _, channel := range channels {
+		byID[channel.ID] = channel
+	}
+	grouped := make(map[int]bool)
+	feeds := make([]parser.OPMLFeed, 0, len(channels))
+	for _, group := range groups {
+		for _, id := range group.ChannelIDs {
+			if channel, ok := byID[id]; ok {
+				feeds = append(feeds, opmlFeed(channel, group.Name))
+				grouped[id] = true
+			}
+		}
+	}
+	for _, channel := range channels {
+		if !grouped[channel.ID] {
+			feeds = append(feeds, opmlFeed(channel, ""))
+		}
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/x-opml; charset=UTF-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="channels.opml"`)
+	c.Response().WriteHeader(http.StatusOK)
+	return parser.WriteOPML(c.Response(), opmlTitle, feeds)
+}
+
+func opmlFeed(channel data.Channel, folder string) parser.OPMLFeed {
+	title := channel.Name
+	if title == "" {
+		title = channel.Title
+	}
+	return parser.OPMLFeed{URL: channel.Link, Title: title, SiteURL: channel.RSSLink, Folder: folder}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/internal/db"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// page is the envelope of list responses. NextCursor is passed as ?cursor= to get
+// the following page and is empty on the last one.
+type page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// newPage trims the one item loaded beyond the limit and builds the cursor of the
+// next page from the last item kept.
+func newPage[T any](items []T, limit int, cursor func(last T) any) page[T] {
+	if len(items) <= limit {
+		return page[T]{Items: items}
+	}
+	items = items[:limit]
+	return page[T]{Items: items, NextCursor: encodeCursor(cursor(items[len(items)-1]))}
+}
+
+// idCursor is the position in lists ordered by id.
+type idCursor struct {
+	ID int `json:"i"`
+}
+
+// newsCursor remembers the order it was made for, so it is not applied to another one.
+type newsCursor struct {
+	Order   db.NewsOrder `json:"o,omitempty"`
+	PubDate time.Time    `json:"p"`
+	ID      int          `json:"i"`
+}
+
+// Cursors are opaque to clients: base64 encoded JSON.
+func encodeCursor(cursor any) string {
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeCursor(c echo.Context, cursor any) (bool, error) {
+	value := c.QueryParam("cursor")
+	if value == "" {
+		return false, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || json.Unmarshal(decoded, cursor) != nil {
+		return false, fmt.Errorf("invalid cursor")
+	}
+	return true, nil
+}
+
+func pageLimit(c echo.Context) (int, error) {
+	value := c.QueryParam("limit")
+	if value == "" {
+		return defaultPageLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+	}
+	return limit, nil
+}
+
+// queryTime accepts RFC 3339 timestamps and dates.
+func queryTime(c echo.Context, name string) (time.Time, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s must be a date (2006-01-02) or an RFC 3339 timestamp", name)
+}
+
+// queryBool reads a flag, false when it is not given.
+func queryBool(c echo.Context, name string) (bool, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return false, nil
+	}
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", name)
+	}
+	return flag, nil
+}
+
+// groupParam reads ?group, 0 when it is not given.
+func groupParam(c echo.Context) (int, error) {
+	value := c.QueryParam("group")
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid group ID")
+	}
+	return id, nil
+}
+
+// channelFilter reads ?limit and ?cursor of the channel list.
+func channelFilter(c echo.Context) (db.ChannelFilter, error) {
+	limit, err := pageLimit(c)
+	if err != nil {
+		return db.ChannelFilter{}, err
+	}
+	filter := db.ChannelFilter{Limit: limit + 1}
+	var cursor idCursor
+	if _, err := decodeCursor(c, &cursor); err != nil {
+		return db.ChannelFilter{}, err
+	}
+	filter.AfterID = cursor.ID
+	return filter, nil
+}
+
+// jobFilter reads ?status, ?limit and ?cursor of the job list.
+func jobFilter(c echo.Context) (db.JobFilter, error) {
+	limit, err := pageLimit(c)
+	if err != nil {
+		return db.JobFilter{}, err
+	}
+	filter := db.JobFilter{Status: data.JobStatus(c.QueryParam("status")), Limit: limit + 1}
+	switch filter.Status {
+	case "", data.JobPending, data.JobRunning, data.JobCompleted, data.JobFailed, data.JobDead:
+	default:
+		return db.JobFilter{}, fmt.Errorf("status must be pending, running, completed, failed or dead")
+	}
+	var cursor idCursor
+	if _, err := decodeCursor(c, &cursor); err != nil {
+		return db.JobFilter{}, err
+	}
+	filter.AfterID = cursor.ID
+	return filter, nil
+}
+
+// newsFilter reads the filters (?channel, ?group, ...), ?sort, ?limit and ?cursor of the news list.
+func newsFilter(c echo.Context) (db.NewsFilter, error) {
+	limit, err := pageLimit(c)
+	if err != nil {
+		return db.NewsFilter{}, err
+	}
+	filter := db.NewsFilter{
+		Author:   c.QueryParam("author"),
+		Category: c.QueryParam("category"),
+		Title:    c.QueryParam("title"),
+		Limit:    limit + 1,
+	}
+
+	switch order := db.NewsOrder(c.QueryParam("sort")); order {
+	case db.NewsByID, "id":
+	case db.NewsByPubDate, db.NewsByPubDateDesc:
+		filter.Order = order
+	default:
+		return db.NewsFilter{}, fmt.Errorf("sort must be id, pub_date or -pub_date")
+	}
+
+	if channel := c.QueryParam("channel"); channel != "" {
+		if filter.ChannelID, err = strconv.Atoi(channel); err != nil {
+			return db.NewsFilter{}, fmt.Errorf("invalid channel ID")
+		}
+	}
+	if filter.GroupID, err = groupParam(c); err != nil {
+		return db.NewsFilter{}, err
+	}
+	if filter.From, err = queryTime(c, "from"); err != nil {
+		return db.NewsFilter{}, err
+	}
+	if filter.To, err = queryTime(c, "to"); err != nil {
+		return db.NewsFilter{}, err
+	}
+	if filter.Unread, err = queryBool(c, "unread"); err != nil {
+		return db.NewsFilter{}, err
+	}
+	if filter.Starred, err = queryBool(c, "starred"); err != nil {
+		return db.NewsFilter{}, err
+	}
+
+	var cursor newsCursor
+	if ok, err := decodeCursor(c, &cursor); err != nil {
+		return db.NewsFilter{}, err
+	} else if ok {
+		if cursor.Order != filter.Order {
+			return db.NewsFilter{}, fmt.Errorf("cursor was made for another sort order")
+		}
+		filter.After = &db.NewsCursor{PubDate: cursor.PubDate, ID: cursor.ID}
+	}
+	return filter, nil
+}
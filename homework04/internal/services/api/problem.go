@@ -0,0 +1,196 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"rss_fetcher/internal/db"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MIMEProblemJSON is the content type of error responses (RFC 7807).
+const MIMEProblemJSON = "application/problem+json"
+
+// problemTypePrefix makes the stable code of an error its problem type.
+const problemTypePrefix = "urn:rss-fetcher:problem:"
+
+// Error codes of the problems, clients switch on them. Codes of missing rows are
+// built from the entity, e.g. channel_not_found or group_not_found.
+const (
+	CodeInvalidBody         = "invalid_body"           // 400, the body is not valid JSON or OPML
+	CodeInvalidParameter    = "invalid_parameter"      // 400, a path or query parameter
+	CodeUnauthorized        = "unauthorized"           // 401, missing or invalid credentials
+	CodeForbidden           = "forbidden"              // 403, the scope of the credentials is too narrow
+	CodeNotFound            = "not_found"              // 404, no such route
+	CodeConflict            = "conflict"               // 409, a duplicate not listed in conflicts
+	CodeUnsupportedMedia    = "unsupported_media_type" // 415, a JSON body of another content type
+	CodeValidationFailed    = "validation_failed"      // 422, a field of a well-formed body
+	CodeInvalidReference    = "invalid_reference"      // 422, the body refers to a missing row
+	CodeInternal            = "internal_error"         // 500
+	CodeDatabaseUnavailable = "database_unavailable"   // 503, retry later
+	CodeModelUnavailable    = "model_unavailable"      // 503, Ollama failed or no embedding profile is active
+)
+
+type conflict struct {
+	code   string
+	detail string
+}
+
+// conflicts name the duplicates of the unique constraints.
+var conflicts = map[string]conflict{
+	"channels_link_key":           {code: "channel_exists", detail: "Channel already exists"},
+	"channel_groups_name_key":     {code: "group_exists", detail: "Group already exists"},
+	"embedding_profiles_name_key": {code: "profile_exists", detail: "Embedding profile already exists"},
+	"users_name_key":              {code: "user_exists", detail: "User already exists"},
+}
+
+// Problem is the body of every error response.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+// apiError is returned by the handlers and answered by HandleError. The cause is
+// logged, it is never sent to clients.
+type apiError struct {
+	status int
+	code   string
+	detail string
+	cause  error
+}
+
+func (e *apiError) Error() string {
+	if e.cause == nil {
+		return e.detail
+	}
+	return fmt.Sprintf("%s: %v", e.detail, e.cause)
+}
+
+func (e *apiError) Unwrap() error {
+	return e.cause
+}
+
+// invalidParameter is a path or query parameter which can not be parsed.
+func invalidParameter(detail string) error {
+	return &apiError{status: http.StatusBadRequest, code: CodeInvalidParameter, detail: detail}
+}
+
+func invalidBody(cause error) error {
+	return &apiError{status: http.StatusBadRequest, code: CodeInvalidBody, detail: "Invalid request body", cause: cause}
+}
+
+// invalid is a field of the body with a wrong value.
+func invalid(detail string) error {
+	return &apiError{status: http.StatusUnprocessableEntity, code: CodeValidationFailed, detail: detail}
+}
+
+// invalidReference is a field of the body referring to a missing row.
+func invalidReference(detail string) error {
+	return &apiError{status: http.StatusUnprocessableEntity, code: CodeInvalidReference, detail: detail}
+}
+
+func notFound(entity string, id int) error {
+	return &db.NotFoundError{Entity: entity, ID: id}
+}
+
+// failed is an unexpected error. Errors of the stores keep their own status, e.g.
+// a missing row is still answered with 404.
+func failed(detail string, cause error) error {
+	return &apiError{status: http.StatusInternalServerError, code: CodeInternal, detail: detail, cause: cause}
+}
+
+// modelUnavailable is a failure of Ollama, or a missing embedding profile.
+func modelUnavailable(detail string, cause error) error {
+	return &apiError{status: http.StatusServiceUnavailable, code: CodeModelUnavailable, detail: detail, cause: cause}
+}
+
+// HandleError answers every error returned by a handler, or by echo itself, with a
+// problem. It is set as the HTTPErrorHandler of echo.
+func (api *API) HandleError(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+	problem := problemOf(err)
+	problem.Instance = c.Request().URL.Path
+	if problem.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", c.Request().Method, c.Request().URL.Path, err)
+	}
+
+	switch problem.Status {
+	case http.StatusUnauthorized:
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="rss-fetcher"`)
+	case http.StatusServiceUnavailable:
+		c.Response().Header().Set("Retry-After", "30")
+	}
+	c.Response().Header().Set(echo.HeaderContentType, MIMEProblemJSON)
+	c.Response().WriteHeader(problem.Status)
+	if c.Request().Method == http.MethodHead {
+		return
+	}
+	if err := json.NewEncoder(c.Response()).Encode(problem); err != nil {
+		log.Printf("Failed to write error response: %v", err)
+	}
+}
+
+// problemOf maps the error to its status and code. Errors of the handlers come first,
+// then the errors of the stores, which win over the 500 of failed. The cause of an
+// unavailable model is not classified, a refused connection to Ollama is no database error.
+func problemOf(err error) Problem {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.status != http.StatusInternalServerError {
+		return newProblem(apiErr.status, apiErr.code, apiErr.detail)
+	}
+
+	err = db.Classify(err)
+	var notFoundErr *db.NotFoundError
+	var constraintErr *db.ConstraintError
+	var httpErr *echo.HTTPError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &notFoundErr):
+		detail := notFoundErr.Error()
+		return newProblem(http.StatusNotFound, notFoundErr.Entity+"_not_found", strings.ToUpper(detail[:1])+detail[1:])
+	case errors.As(err, &constraintErr) && errors.Is(constraintErr, db.ErrConflict):
+		duplicate, ok := conflicts[constraintErr.Constraint]
+		if !ok {
+			duplicate = conflict{code: CodeConflict, detail: "Already exists"}
+		}
+		return newProblem(http.StatusConflict, duplicate.code, duplicate.detail)
+	case errors.As(err, &constraintErr):
+		return newProblem(http.StatusUnprocessableEntity, CodeInvalidReference, "A referenced item does not exist")
+	case errors.Is(err, db.ErrUnavailable):
+		return newProblem(http.StatusServiceUnavailable, CodeDatabaseUnavailable, "The database is unavailable, try again later")
+	case errors.As(err, &apiErr):
+		return newProblem(apiErr.status, apiErr.code, apiErr.detail)
+	case errors.As(err, &maxBytesErr):
+		return newProblem(http.StatusRequestEntityTooLarge, "payload_too_large",
+			fmt.Sprintf("The body is larger than %d bytes", maxBytesErr.Limit))
+	case errors.As(err, &httpErr):
+		return newProblem(httpErr.Code, statusCode(httpErr.Code), fmt.Sprint(httpErr.Message))
+	default:
+		return newProblem(http.StatusInternalServerError, CodeInternal, "Internal server error")
+	}
+}
+
+func newProblem(status int, code, detail string) Problem {
+	return Problem{Type: problemTypePrefix + code, Title: http.StatusText(status), Status: status, Detail: detail, Code: code}
+}
+
+// statusCode is the code of the errors of echo, e.g. method_not_allowed.
+func statusCode(status int) string {
+	switch status {
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusBadRequest:
+		return CodeInvalidBody
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/internal/db"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newsStateRequest changes only the fields it contains.
+type newsStateRequest struct {
+	Read    *bool `json:"read"`
+	Starred *bool `json:"starred"`
+}
+
+// GetSubscriptions lists the channels the caller subscribes to, a page at a time (?limit, ?cursor).
+func (api *API) GetSubscriptions(c echo.Context) error {
+	userID, err := callerUser(c)
+	if err != nil {
+		return err
+	}
+	filter, err := channelFilter(c)
+	if err != nil {
+		return invalidParameter(err.Error())
+	}
+	filter.UserID = userID
+	channels, err := api.store.LoadChannels(c.Request().Context(), filter)
+	if err != nil {
+		return failed("Failed to load subscriptions", err)
+	}
+	return c.JSON(http.StatusOK, newPage(channels, filter.Limit-1, func(last data.Channel) any {
+		return idCursor{ID: last.ID}
+	}))
+}
+
+// AddSubscription subscribes the caller to the feed of a link. Channels are shared, a
+// feed followed by someone else already is not added and fetched again. Adding a
+// channel changes what every user can see, so it needs the admin scope.
+func (api *API) AddSubscription(c echo.Context) error {
+	userID, err := callerUser(c)
+	if err != nil {
+		return err
+	}
+	var request addChannelRequest
+	if err := c.Bind(&request); err != nil {
+		return invalidBody(err)
+	}
+	if request.Link == "" {
+		return invalid("Link is required")
+	}
+
+	ctx := c.Request().Context()
+	channels, err := api.store.LoadChannels(ctx, db.ChannelFilter{Link: request.Link})
+	if err != nil {
+		return failed("Failed to load channel", err)
+	}
+	if len(channels) == 0 {
+		if caller := callerOf(c); caller == nil || !caller.Scope.Allows(data.ScopeAdmin) {
+			return &apiError{status: http.StatusForbidden, code: CodeForbidden,
+				detail: "Only admin credentials add channels, subscribe to a channel which exists already"}
+		}
+		_, err := api.store.AddChannel(ctx, request.Link)
+		if err != nil && !errors.Is(err, db.ErrConflict) {
+			return failed("Failed to add channel", err)
+		}
+		// added here, or by another request in the meantime
+		if channels, err = api.store.LoadChannels(ctx, db.ChannelFilter{Link: request.Link}); err != nil {
+			return failed("Failed to load channel", err)
+		}
+		if len(channels) == 0 {
+			return failed("Failed to add channel", errors.New("channel deleted right after it was added"))
+		}
+	}
+	if err := api.store.AddSubscription(ctx, userID, channels[0].ID); err != nil {
+		return failed("Failed to subscribe", err)
+	}
+	return c.JSON(http.StatusCreated, channels[0])
+}
+
+// SubscribeChannel subscribes the caller to a channel, PUT /subscriptions/:channel.
+func (api *API) SubscribeChannel(c echo.Context) error {
+	userID, err := callerUser(c)
+	if err != nil {
+		return err
+	}
+	channelID, err := strconv.Atoi(c.Param("channel"))
+	if err != nil {
+		return invalidParameter("Invalid channel ID")
+	}
+	ctx := c.Request().Context()
+	channels, err := api.store.LoadChannels(ctx, db.ChannelFilter{ID: channelID})
+	if err != nil {
+		return failed("Failed to load channel", err)
+	}
+	if len(channels) == 0 {
+		return notFound("channel", channelID)
+	}
+	if err := api.store.AddSubscription(ctx, userID, channelID); err != nil {
+		return failed("Failed to subscribe", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Subscribed to channel"})
+}
+
+// UnsubscribeChannel ends a subscription of the caller, the channel and its news are kept.
+func (api *API) UnsubscribeChannel(c echo.Context) error {
+	userID, err := callerUser(c)
+	if err != nil {
+		return err
+	}
+	channelID, err := strconv.Atoi(c.Param("channel"))
+	if err != nil {
+		return invalidParameter("Invalid channel ID")
+	}
+	if err := api.store.DeleteSubscription(c.Request().Context(), userID, channelID); err != nil {
+		return failed("Failed to unsubscribe", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{"message": "Unsubscribed from channel"})
+}
+
+// UpdateNewsState marks a news item of the subscriptions of the caller as read or
+// unread and starred or not.
+func (api *API) UpdateNewsState(c echo.Context) error {
+	userID, err := callerUser(c)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return invalidParameter("Invalid news ID")
+	}
+	var request newsStateRequest
+	if err := c.Bind(&request); err != nil {
+		return invalidBody(err)
+	}
+
+	ctx := c.Request().Context()
+	news, err := api.store.LoadNews(ctx, db.NewsFilter{ID: id, UserID: userID})
+	if err != nil {
+		return failed("Failed to load news", err)
+	}
+	if len(news) == 0 {
+		return notFound("news", id)
+	}
+	state := *news[0].State
+	if request.Read != nil {
+		state.Read = *request.Read
+	}
+	if request.Starred != nil {
+		state.Starred = *request.Starred
+	}
+	if err := api.store.SaveNewsState(ctx, userID, id, state); err != nil {
+		return failed("Failed to save news state", err)
+	}
+	return c.JSON(http.StatusOK, state)
+}
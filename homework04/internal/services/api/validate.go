@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"rss_fetcher/openapi"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxJSONBodySize limits the JSON bodies read by the validation.
+const maxJSONBodySize = 1 << 20
+
+// echoParam matches the parameters of echo routes, :id becomes {id} in the specification.
+var echoParam = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// specPath converts the path of an echo route to the path of the specification.
+func specPath(route string) string {
+	return echoParam.ReplaceAllString(route, "{$1}")
+}
+
+// GetOpenAPI serves the OpenAPI specification of the service.
+func (api *API) GetOpenAPI(c echo.Context) error {
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, openapi.Spec)
+}
+
+// CheckRoutes fails when a route of the server has no operation in the specification,
+// so the specification cannot silently fall behind the handlers.
+func CheckRoutes(spec *openapi.Document, routes []*echo.Route) error {
+	var missing []string
+	for _, route := range routes {
+		if spec.Operation(route.Method, specPath(route.Path)) == nil {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("routes missing from the OpenAPI specification: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// ValidateRequests rejects requests which do not match their operation in the
+// specification before they reach the handlers: path and query parameters of the
+// wrong type or out of range are 400 invalid_parameter, JSON bodies which do not match
+// the schema are 422 validation_failed. Routes without an operation pass through.
+func ValidateRequests(spec *openapi.Document) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			operation := spec.Operation(c.Request().Method, specPath(c.Path()))
+			if operation == nil {
+				return next(c)
+			}
+			if err := validateParameters(spec, operation, c); err != nil {
+				return err
+			}
+			if err := validateBody(spec, operation, c); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+func validateParameters(spec *openapi.Document, operation *openapi.Operation, c echo.Context) error {
+	query := c.QueryParams()
+	for _, parameter := range operation.Parameters {
+		var value string
+		switch parameter.In {
+		case "path":
+			value = c.Param(parameter.Name)
+		case "query":
+			if !query.Has(parameter.Name) {
+				if parameter.Required {
+					return invalidParameter(fmt.Sprintf("%s is required", parameter.Name))
+				}
+				continue
+			}
+			value = query.Get(parameter.Name)
+			if value == "" && !parameter.Required {
+				// empty values are ignored by the handlers like missing ones
+				continue
+			}
+		default:
+			continue
+		}
+		if err := spec.ValidateParameter(parameter, value); err != nil {
+			return invalidParameter(err.Error())
+		}
+	}
+	return nil
+}
+
+// validateBody checks JSON bodies, other content types are left to the handlers.
+func validateBody(spec *openapi.Document, operation *openapi.Operation, c echo.Context) error {
+	if operation.RequestBody == nil {
+		return nil
+	}
+	media := operation.RequestBody.Content[echo.MIMEApplicationJSON]
+	if media == nil {
+		return nil
+	}
+	request := c.Request()
+	if contentType := request.Header.Get(echo.HeaderContentType); contentType != "" {
+		if parsed, _, err := mime.ParseMediaType(contentType); err != nil || parsed != echo.MIMEApplicationJSON {
+			return &apiError{status: http.StatusUnsupportedMediaType, code: CodeUnsupportedMedia,
+				detail: "Request body must be application/json"}
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Response(), request.Body, maxJSONBodySize))
+	if err != nil {
+		return err
+	}
+	request.Body.Close()
+	// the handlers bind the body again
+	request.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if operation.RequestBody.Required {
+			return invalid("Request body is required")
+		}
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return invalidBody(err)
+	}
+	if decoder.More() {
+		return invalidBody(fmt.Errorf("unexpected data after the JSON value"))
+	}
+	if err := spec.ValidateValue(media.Schema, value, ""); err != nil {
+		return invalid(err.Error())
+	}
+	return nil
+}
@@ -2,85 +2,107 @@ package daemon
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"rss_fetcher/internal/data"
 	"rss_fetcher/internal/db"
 	"rss_fetcher/internal/parser"
-
-	"github.com/jackc/pgx/v5"
+	"time"
 )
 
 type ChannelDaemon struct {
-	dbConn *pgx.Conn
+	store       db.Store
+	maxFailures int // consecutive failed fetches after which a channel is paused, 0 never pauses
 }
 
-func NewChannelDaemon(dbConn *pgx.Conn) *ChannelDaemon {
-	return &ChannelDaemon{dbConn: dbConn}
+func NewChannelDaemon(store db.Store, maxFailures int) *ChannelDaemon {
+	return &ChannelDaemon{store: store, maxFailures: maxFailures}
 }
 
-func (daemon *ChannelDaemon) CheckFeeds() {
-	channels, err := db.LoadChannels(daemon.dbConn)
+func (daemon *ChannelDaemon) CheckFeeds(ctx context.Context) {
+	channels, err := daemon.store.LoadChannels(ctx, db.ChannelFilter{})
 	if err != nil {
 		log.Printf("Failed to load channels: %v", err)
 		return
 	}
 
 	for _, channel := range channels {
-		if err := daemon.processChannel(channel); err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		if channel.Paused || !channelDue(channel) {
+			continue
+		}
+		if err := daemon.processChannel(ctx, channel); err != nil {
 			log.Printf("Error processing channel %s: %v", channel.Link, err)
 		}
 	}
 }
 
-func (daemon *ChannelDaemon) saveNews(channelID int, news data.ChannelNews) error {
-	tx, err := db.StartTransaction(daemon.dbConn)
+// CheckFeed fetches a single channel, e.g. right after it was added.
+func (daemon *ChannelDaemon) CheckFeed(ctx context.Context, id int) {
+	channel, err := daemon.store.LoadChannel(ctx, id)
 	if err != nil {
-		log.Printf("Failed to start transaction: %v", err)
-		return err
-	}
-	defer tx.Rollback(context.Background())
-
-	transactionQuery := db.NewTransactionQuery(tx)
-
-	if rows, err := db.LoadNewsByLink(transactionQuery, news.Link); err != nil {
-		log.Printf("Failed to load news by link %s: %v", news.Link, err)
-		return err
-	} else if len(rows) > 0 {
-		log.Printf("News item %s already exists, skipping", news.Link)
-		return nil
+		log.Printf("Failed to load channel %d: %v", id, err)
+		return
 	}
-	log.Printf("Found news: %s", news.Link)
-	if err := db.AddNews(transactionQuery, channelID, news); err != nil {
-		log.Printf("Failed to save news item %s: %v", news.Link, err)
-		return err
+	if channel == nil {
+		log.Printf("Channel %d not found", id)
+		return
 	}
-
-	if db.AddJob(transactionQuery, news.Link) != nil {
-		log.Printf("Failed to add job for news item %s: %v", news.Link, err)
-		return err
+	if channel.Paused {
+		return
 	}
-
-	err = tx.Commit(context.Background())
-	if err != nil {
-		return fmt.Errorf("commit error: %w", err)
+	if err := daemon.processChannel(ctx, *channel); err != nil {
+		log.Printf("Error processing channel %s: %v", channel.Link, err)
 	}
+}
 
-	return nil
+// channelDue tells whether the poll interval of the channel has passed since its last check.
+// Channels without their own interval are checked on every tick of the service.
+func channelDue(channel data.Channel) bool {
+	return channel.PollInterval <= 0 || time.Since(channel.UpdatedAt) >= channel.PollInterval
 }
 
-func (daemon *ChannelDaemon) processChannel(channel data.Channel) error {
-	news, err := parser.FetchRSS(channel.Link)
+func (daemon *ChannelDaemon) processChannel(ctx context.Context, channel data.Channel) error {
+	fetch := data.ChannelFetch{ChannelID: channel.ID, StartedAt: time.Now()}
+	response, err := parser.FetchRSS(ctx, channel.Link, channel.Headers)
+	fetch.Duration = time.Since(fetch.StartedAt)
+	fetch.StatusCode = response.StatusCode
+	fetch.Bytes = response.Bytes
+	fetch.Items = len(response.News)
+	if markErr := daemon.store.MarkChannelChecked(ctx, channel.ID); markErr != nil {
+		log.Printf("Failed to update check time of channel %s: %v", channel.Link, markErr)
+	}
 	if err != nil {
 		log.Printf("Failed to fetch RSS for channel %s: %v", channel.Link, err)
+		fetch.Error = err.Error()
+		daemon.recordFetch(ctx, channel, fetch)
 		return err
 	}
-	for _, item := range news {
-		daemon.saveNews(channel.ID, item)
+	for _, item := range response.News {
+		added, err := daemon.store.AddNewsWithJob(ctx, channel.ID, item)
 		if err != nil {
 			log.Printf("Failed to save news item %s: %v", item.Link, err)
+			continue
+		}
+		if added {
+			fetch.NewItems++
+			log.Printf("Found news: %s", item.Link)
 		}
 	}
-	log.Printf("Processed channel %s with %d items", channel.Link, len(news))
+	daemon.recordFetch(ctx, channel, fetch)
+	log.Printf("Processed channel %s with %d items", channel.Link, len(response.News))
 	return nil
 }
+
+// recordFetch adds the fetch to the history of the channel, pausing the channel when it failed too often.
+func (daemon *ChannelDaemon) recordFetch(ctx context.Context, channel data.Channel, fetch data.ChannelFetch) {
+	paused, err := daemon.store.RecordChannelFetch(ctx, fetch, daemon.maxFailures)
+	if err != nil {
+		log.Printf("Failed to record fetch of channel %s: %v", channel.Link, err)
+		return
+	}
+	if paused {
+		log.Printf("Channel %s is paused after %d failed fetches", channel.Link, daemon.maxFailures)
+	}
+}
@@ -0,0 +1,72 @@
+package daemon
+
+import (
+	"context"
+	"log"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/internal/webhook"
+	"time"
+)
+
+const (
+	webhookTimeout     = 10 * time.Second
+	alertMaxAttempts   = 6
+	alertRetryBaseWait = 30 * time.Second
+	alertBatch         = 20
+	// alertLease covers the delivery of a whole batch, alerts of a replica which
+	// died are posted by another one once it expires
+	alertLease = alertBatch*webhookTimeout + time.Minute
+)
+
+// DeliverAlerts posts pending saved search alerts to their webhooks.
+// Alerts are claimed in batches, so replicas never post the same alert twice.
+// Failed deliveries are retried with exponential backoff on later calls.
+func (daemon *NewsDaemon) DeliverAlerts(ctx context.Context) {
+	for ctx.Err() == nil {
+		alerts, err := daemon.store.ClaimAlerts(ctx, alertLease, alertBatch)
+		if err != nil {
+			log.Printf("Failed to claim pending alerts: %v", err)
+			return
+		}
+
+		for _, alert := range alerts {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := daemon.deliverAlert(ctx, alert); err != nil {
+				log.Printf("Error delivering alert %d to %s: %v", alert.ID, alert.Search.WebhookURL, err)
+			}
+		}
+		if len(alerts) < alertBatch {
+			return
+		}
+	}
+}
+
+func (daemon *NewsDaemon) deliverAlert(ctx context.Context, alert data.SearchAlert) error {
+	sendCtx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	payload := webhook.Payload{
+		SearchID:   alert.Search.ID,
+		SearchName: alert.Search.Name,
+		Query:      alert.Search.Query,
+		NewsID:     alert.News.ID,
+		Title:      alert.News.Title,
+		Link:       alert.News.Link,
+		PubDate:    alert.News.PubDate,
+		Similarity: alert.Similarity,
+		Chunk:      alert.Chunk,
+	}
+
+	if err := daemon.webhooks.Send(sendCtx, alert.Search.WebhookURL, alert.Search.Secret, payload); err != nil {
+		retryIn := alertRetryBaseWait << alert.Attempts
+		if dbErr := daemon.store.MarkAlertFailed(ctx, alert.ID, err.Error(), retryIn, alertMaxAttempts); dbErr != nil {
+			log.Printf("Failed to update alert %d: %v", alert.ID, dbErr)
+		}
+		return err
+	}
+
+	log.Printf("Alert %d for search %q delivered: %s", alert.ID, alert.Search.Name, alert.News.Link)
+	return daemon.store.MarkAlertDelivered(ctx, alert.ID)
+}
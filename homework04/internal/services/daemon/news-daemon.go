@@ -2,89 +2,311 @@ package daemon
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"rss_fetcher/internal/data"
 	"rss_fetcher/internal/db"
+	"rss_fetcher/internal/embedding"
 	"rss_fetcher/internal/parser"
+	"rss_fetcher/internal/webhook"
+	"sync"
 	"time"
-
-	backend "rss_fetcher/internal/ollama"
-
-	"github.com/jackc/pgx/v5"
 )
 
 type NewsDaemon struct {
-	dbConn     *pgx.Conn
-	vectorDB   *db.PGVector
+	store      db.Store
+	embedders  embedding.Provider
 	ollamaHost string
-	embModel   string
 	genModel   string
+	webhooks   *webhook.Sender
+	jobPolicy  JobPolicy
+	workers    int
+
+	chunkersMu sync.Mutex
+	chunkers   map[string]parser.Chunker // by spec, vocabularies of tokenizers are loaded once
+}
+
+// JobPolicy controls how news jobs are retried.
+type JobPolicy struct {
+	MaxAttempts  int           // Attempts before a job is declared dead
+	Lease        time.Duration // Time a claimed job stays locked without a renewal by its worker
+	RetryBackoff time.Duration // Delay before the first retry, doubled on every next one
 }
 
-func NewNewsDaemon(dbConn *pgx.Conn, vectorDB *db.PGVector, host, embModel, genModel string) *NewsDaemon {
-	return &NewsDaemon{dbConn: dbConn, vectorDB: vectorDB, embModel: embModel, genModel: genModel, ollamaHost: host}
+var DefaultJobPolicy = JobPolicy{
+	MaxAttempts:  5,
+	Lease:        10 * time.Minute,
+	RetryBackoff: 1 * time.Minute,
+}
+
+const (
+	embeddingTimeout = 2 * time.Minute
+	// jobStatusTimeout bounds the write of the final status of a job, which is done
+	// even when the daemon is stopping
+	jobStatusTimeout = 10 * time.Second
+)
+
+func NewNewsDaemon(store db.Store, embedders embedding.Provider, host, genModel string, jobPolicy JobPolicy, workers int) *NewsDaemon {
+	if workers < 1 {
+		workers = 1
+	}
+	return &NewsDaemon{store: store, embedders: embedders, genModel: genModel, ollamaHost: host,
+		webhooks: webhook.NewSender(webhookTimeout), jobPolicy: jobPolicy, workers: workers, chunkers: make(map[string]parser.Chunker)}
 }
 
-func (daemon *NewsDaemon) CheckJobs() {
-	items, err := db.LoadJobs(db.NewConnectionQuery(daemon.dbConn), " Status = 0")
+// EnsureProfile makes sure that there is an active embedding profile, creating one for
+// the model, chunker and contextual headers on a fresh database. The settings of an
+// existing profile are never changed here, switching them is done by reindexing.
+func (daemon *NewsDaemon) EnsureProfile(ctx context.Context, model, chunkerSpec string, contextual bool) error {
+	chunker, err := daemon.chunker(chunkerSpec)
 	if err != nil {
-		log.Printf("Failed to load jobs: %v", err)
-		return
+		return err
 	}
 
-	for _, item := range items {
-		if err := daemon.processJob(item); err != nil {
-			log.Printf("Error processing job for %s news: %v", item.Link, err)
+	profile, err := daemon.store.LoadActiveProfile(ctx)
+	if err != nil {
+		return err
+	}
+	if profile != nil {
+		if profile.Model != model {
+			log.Printf("Active embedding profile %s uses model %s, model %s is ignored until reindexing", profile.Name, profile.Model, model)
+		}
+		if profile.Chunker != chunker.Name() {
+			log.Printf("Active embedding profile %s uses chunker %s, chunker %s is ignored until reindexing", profile.Name, profile.Chunker, chunker.Name())
 		}
+		if profile.Contextual != contextual {
+			log.Printf("Active embedding profile %s has contextual headers %t, setting %t is ignored until reindexing", profile.Name, profile.Contextual, contextual)
+		}
+		_, err := daemon.chunker(profile.Chunker)
+		return err
 	}
-}
 
-func (daemon *NewsDaemon) saveChunk(newsID int, chunk string) error {
-	embeddingBackend := backend.NewOllamaBackend(daemon.ollamaHost, daemon.embModel, time.Duration(20*time.Second))
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, embeddingTimeout)
 	defer cancel()
 
-	embedding, err := embeddingBackend.Embed(ctx, chunk, map[string]string{"Content-Type": "application/json"})
+	dims, err := embedding.Dimension(ctx, daemon.embedders.Embedder(model))
 	if err != nil {
-		log.Printf("Failed to generate embedding for chunk: %s, error: %v", chunk, err)
-		return err
+		return fmt.Errorf("failed to detect dimension of model %s: %w", model, err)
 	}
-	err = daemon.vectorDB.InsertDocument(ctx, newsID, chunk, embedding)
+	profile, err = daemon.store.AddProfile(ctx, data.EmbeddingProfile{
+		Name:       model,
+		Model:      model,
+		Dims:       dims,
+		Status:     data.ProfileActive,
+		Chunker:    chunker.Name(),
+		Contextual: contextual,
+		Index:      data.DefaultIndexSettings,
+	})
 	if err != nil {
-		log.Fatalf("Error inserting data into vector database: %v", err)
 		return err
 	}
-	return nil
+	log.Printf("Created embedding profile %s (%d dimensions)", profile.Name, profile.Dims)
+	return daemon.store.CreateProfileIndex(ctx, *profile)
 }
 
-func (daemon *NewsDaemon) processJob(job data.NewsJob) error {
-	err := db.UpdateJobStatus(db.NewConnectionQuery(daemon.dbConn), job.ID, 1)
+// chunker returns the chunker for the spec, creating it on first use.
+func (daemon *NewsDaemon) chunker(spec string) (parser.Chunker, error) {
+	daemon.chunkersMu.Lock()
+	defer daemon.chunkersMu.Unlock()
+
+	if chunker, ok := daemon.chunkers[spec]; ok {
+		return chunker, nil
+	}
+	chunker, err := parser.NewChunker(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chunker %q: %w", spec, err)
+	}
+	daemon.chunkers[spec] = chunker
+	return chunker, nil
+}
+
+// CheckJobs drains the queue with a pool of workers and returns when
+// there is no runnable job left or the context is cancelled.
+func (daemon *NewsDaemon) CheckJobs(ctx context.Context) {
+	if dead, err := daemon.store.ReapJobs(ctx, daemon.jobPolicy.MaxAttempts); err != nil {
+		log.Printf("Failed to reap expired jobs: %v", err)
+	} else if dead > 0 {
+		log.Printf("%d jobs exhausted their attempts and are dead", dead)
+	}
+
+	var wg sync.WaitGroup
+	for worker := 1; worker <= daemon.workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			daemon.runWorker(ctx, worker)
+		}()
+	}
+	wg.Wait()
+}
+
+func (daemon *NewsDaemon) runWorker(ctx context.Context, worker int) {
+	for ctx.Err() == nil {
+		job, err := daemon.store.ClaimJob(ctx, daemon.jobPolicy.Lease, daemon.jobPolicy.MaxAttempts)
+		if err != nil {
+			log.Printf("Worker %d failed to claim job: %v", worker, err)
+			return
+		}
+		if job == nil {
+			return
+		}
+		daemon.runJob(ctx, worker, *job)
+	}
+}
+
+// runJob processes the job while its lease is renewed, so a slow download or embedding
+// does not let another replica take it over. The final status is written even when
+// the context was cancelled by a shutdown, otherwise the job would stay running until
+// its lease expires and lose an attempt.
+func (daemon *NewsDaemon) runJob(ctx context.Context, worker int, job data.NewsJob) {
+	renewCtx, stopRenewing := context.WithCancel(ctx)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		daemon.renewLease(renewCtx, job.ID)
+	}()
+	err := daemon.processJob(ctx, job)
+	stopRenewing()
+	<-renewed
+
+	statusCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jobStatusTimeout)
+	defer cancel()
+	if err != nil {
+		log.Printf("Worker %d: error processing job for %s news (attempt %d): %v", worker, job.Link, job.Attempts, err)
+		err = daemon.store.FailJob(statusCtx, job.ID, err.Error(), daemon.jobPolicy.MaxAttempts, daemon.jobPolicy.RetryBackoff)
+	} else {
+		err = daemon.store.CompleteJob(statusCtx, job.ID)
+	}
 	if err != nil {
 		log.Printf("Failed to update job status for job %d: %v", job.ID, err)
+	}
+}
+
+// renewLease extends the lease of the job every third of the lease until the context is done.
+func (daemon *NewsDaemon) renewLease(ctx context.Context, id int) {
+	ticker := time.NewTicker(max(daemon.jobPolicy.Lease/3, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := daemon.store.RenewJob(ctx, id, daemon.jobPolicy.Lease); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to renew lease of job %d: %v", id, err)
+			}
+		}
+	}
+}
+
+// saveChunks embeds all chunks of an article in one request and replaces
+// the indexed version of the article in the profile with them in one transaction.
+// Contextual profiles embed the chunks with the header of the article, the raw
+// chunks are stored for display either way.
+func (daemon *NewsDaemon) saveChunks(ctx context.Context, profile data.EmbeddingProfile, newsID int, chunks []string) error {
+	ctx, cancel := context.WithTimeout(ctx, embeddingTimeout)
+	defer cancel()
+
+	texts := chunks
+	if profile.Contextual {
+		chunkContext, err := daemon.store.LoadChunkContext(ctx, newsID)
+		if err != nil {
+			return fmt.Errorf("failed to load context of news %d: %w", newsID, err)
+		}
+		texts = parser.ContextualChunks(chunkContext, chunks)
+	}
+
+	embeddings, err := daemon.embedders.Embedder(profile.Model).Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to generate embeddings with %s: %w", profile.Model, err)
+	}
+
+	alerts, err := daemon.store.IndexArticle(ctx, profile, newsID, chunks, embeddings)
+	if err != nil {
 		return err
 	}
+	if alerts > 0 {
+		log.Printf("News %d matched %d saved searches", newsID, alerts)
+	}
+	return nil
+}
 
-	defer db.UpdateJobStatus(db.NewConnectionQuery(daemon.dbConn), job.ID, 2)
+// loadContent returns the readable content of the article. The page is fetched only once,
+// with the headers of the channel, retries and reprocessing use the stored content.
+func (daemon *NewsDaemon) loadContent(ctx context.Context, news data.ChannelNews) (*data.NewsContent, error) {
+	content, err := daemon.store.LoadNewsContent(ctx, news.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load content: %w", err)
+	}
+	if content != nil {
+		return content, nil
+	}
 
-	linkItems, err := db.LoadNewsByLink(db.NewConnectionQuery(daemon.dbConn), job.Link)
-	if err != nil || len(linkItems) == 0 {
-		log.Printf("Failed to load news for link %s: %v", job.Link, err)
+	channels, err := daemon.store.LoadChannels(ctx, db.ChannelFilter{ID: news.ChannelID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load channel: %w", err)
 	}
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("channel %d not found", news.ChannelID)
+	}
+	content, err = parser.ChannelArticle(ctx, channels[0], news)
+	if err != nil {
+		return nil, err
+	}
+	if err := daemon.store.SaveNewsContent(ctx, *content); err != nil {
+		return nil, fmt.Errorf("failed to save content: %w", err)
+	}
+	return content, nil
+}
 
-	news := linkItems[0]
+func (daemon *NewsDaemon) processJob(ctx context.Context, job data.NewsJob) error {
+	news, err := daemon.store.LoadNewsByLink(ctx, job.Link)
+	if err != nil {
+		return fmt.Errorf("failed to load news for link %s: %w", job.Link, err)
+	}
+	if news == nil {
+		return fmt.Errorf("news for link %s not found", job.Link)
+	}
 
-	chunks, err := parser.ExtractArticle(job.Link, 400)
+	content, err := daemon.loadContent(ctx, *news)
 	if err != nil {
 		log.Printf("Failed to parse article %s: %v", job.Link, err)
 		return err
 	}
 
-	for _, chunk := range chunks {
-		daemon.saveChunk(news.ID, chunk)
-		if err != nil {
-			log.Printf("Failed to save chunk %s for link %s: %v", chunk, news.Link, err)
+	// the article is indexed by the active profile and by profiles being built,
+	// so a reindexing running in the background does not miss new articles
+	profiles, err := daemon.store.LoadProfiles(ctx, data.ProfileActive, data.ProfileBuilding)
+	if err != nil {
+		return fmt.Errorf("failed to load embedding profiles: %w", err)
+	}
+
+	// profiles may split articles differently, each chunking is done once
+	chunksBySpec := make(map[string][]string)
+	for _, profile := range profiles {
+		chunks, ok := chunksBySpec[profile.Chunker]
+		if !ok {
+			chunker, err := daemon.chunker(profile.Chunker)
+			if err != nil {
+				return err
+			}
+			if chunks, err = chunker.Chunk(*content); err != nil {
+				return fmt.Errorf("failed to chunk article %s: %w", job.Link, err)
+			}
+			chunksBySpec[profile.Chunker] = chunks
+		}
+		if len(chunks) == 0 {
+			// an article which lost its text must not keep the chunks of an earlier version
+			if _, err := daemon.store.IndexArticle(ctx, profile, news.ID, nil, nil); err != nil {
+				return fmt.Errorf("failed to remove chunks for link %s: %w", news.Link, err)
+			}
+			log.Printf("No text found in news.link %s for profile %s", news.Link, profile.Name)
+			continue
+		}
+
+		if err := daemon.saveChunks(ctx, profile, news.ID, chunks); err != nil {
+			return fmt.Errorf("failed to save chunks for link %s: %w", news.Link, err)
 		}
+		log.Printf("Processed news.link %s with %d chunks of %s for profile %s", news.Link, len(chunks), profile.Chunker, profile.Name)
 	}
-	log.Printf("Processed news.link %s with %d chunks", news.Link, len(chunks))
 	return nil
 }
@@ -0,0 +1,273 @@
+// Package reindex re-embeds the corpus with another embedding model or chunker.
+// The new vectors are built in a separate profile next to the active one,
+// which keeps serving queries until the new profile is complete and is
+// activated in a single transaction.
+package reindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"rss_fetcher/internal/data"
+	"rss_fetcher/internal/db"
+	"rss_fetcher/internal/embedding"
+	"rss_fetcher/internal/parser"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	articleTimeout = 2 * time.Minute
+	progressStep   = 100
+)
+
+type Reindexer struct {
+	store     db.Store
+	embedders embedding.Provider
+	workers   int
+	chunker   parser.Chunker // re-chunks stored articles, nil when the chunks of the source profile are copied
+}
+
+func New(store db.Store, embedders embedding.Provider, workers int) *Reindexer {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Reindexer{store: store, embedders: embedders, workers: workers}
+}
+
+// Run builds the profile described by spec (name, model, chunker, contextual headers
+// and index settings) and activates it. An empty chunker keeps the chunker of the active
+// profile. Without activate the profile is left building: it is complete and kept up to
+// date with new articles, so it can be evaluated against the active one before switching.
+// An interrupted run is resumed by running it again with the same name.
+func (r *Reindexer) Run(ctx context.Context, spec data.EmbeddingProfile, activate bool) error {
+	active, err := r.store.LoadActiveProfile(ctx)
+	if err != nil {
+		return err
+	}
+	if active == nil {
+		return errors.New("there is no active profile to reindex")
+	}
+
+	if spec.Chunker == "" {
+		spec.Chunker = active.Chunker
+	}
+	chunker, err := parser.NewChunker(spec.Chunker)
+	if err != nil {
+		return fmt.Errorf("invalid chunker %q: %w", spec.Chunker, err)
+	}
+	spec.Chunker = chunker.Name()
+
+	target, err := r.targetProfile(ctx, spec)
+	if err != nil {
+		return err
+	}
+	log.Printf("Reindexing profile %s (%s, %s, contextual %t) into %s (%s, %s, contextual %t, %d dimensions)",
+		active.Name, active.Model, active.Chunker, active.Contextual, target.Name, target.Model, target.Chunker, target.Contextual, target.Dims)
+
+	// chunks of the active profile are reused unless articles are split differently
+	if target.Chunker != active.Chunker {
+		r.chunker = chunker
+	}
+	if err := r.copyArticles(ctx, *active, *target); err != nil {
+		return err
+	}
+
+	// ivfflat needs the data to pick its lists, so the index is built once the corpus is copied
+	if target.Index.Type == data.IndexIVFFlat {
+		rows, err := r.store.CountVectors(ctx, *target)
+		if err != nil {
+			return err
+		}
+		target.Index.Lists = db.RecommendedLists(rows)
+	}
+	if err := r.store.RebuildProfileIndex(ctx, *target, target.Index); err != nil {
+		return err
+	}
+
+	if !activate {
+		log.Printf("Profile %s is complete and left building, profile %s stays active", target.Name, active.Name)
+		return nil
+	}
+
+	searchEmbeddings, err := r.embedSavedSearches(ctx, *target)
+	if err != nil {
+		return err
+	}
+
+	if err := r.store.ActivateProfile(ctx, *target, searchEmbeddings); err != nil {
+		return err
+	}
+	log.Printf("Profile %s is active, profile %s is retired", target.Name, active.Name)
+	return nil
+}
+
+func (r *Reindexer) targetProfile(ctx context.Context, spec data.EmbeddingProfile) (*data.EmbeddingProfile, error) {
+	profile, err := r.store.LoadProfileByName(ctx, spec.Name)
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		if profile.Status != data.ProfileBuilding {
+			return nil, fmt.Errorf("profile %s is already %s", spec.Name, profile.Status)
+		}
+		if profile.Model != spec.Model {
+			return nil, fmt.Errorf("profile %s is being built with model %s", spec.Name, profile.Model)
+		}
+		if profile.Chunker != spec.Chunker {
+			return nil, fmt.Errorf("profile %s is being built with chunker %s", spec.Name, profile.Chunker)
+		}
+		if profile.Contextual != spec.Contextual {
+			return nil, fmt.Errorf("profile %s is being built with contextual headers %t", spec.Name, profile.Contextual)
+		}
+		log.Printf("Resuming profile %s", spec.Name)
+		return profile, nil
+	}
+
+	dims, err := embedding.Dimension(ctx, r.embedders.Embedder(spec.Model))
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect dimension of model %s: %w", spec.Model, err)
+	}
+	spec.Dims = dims
+	spec.Status = data.ProfileBuilding
+	return r.store.AddProfile(ctx, spec)
+}
+
+// copyArticles re-embeds every article indexed in the source profile. With the same
+// chunker, the chunk texts are taken from the source profile, otherwise the stored
+// article content is chunked again. Pages are fetched only for articles indexed
+// before their content was stored.
+func (r *Reindexer) copyArticles(ctx context.Context, source, target data.EmbeddingProfile) error {
+	ids, err := r.store.LoadUnindexedNews(ctx, source, target)
+	if err != nil {
+		return err
+	}
+	log.Printf("%d articles to reindex", len(ids))
+
+	embedder := r.embedders.Embedder(target.Model)
+	queue := make(chan int)
+	var done, failed atomic.Int64
+	var wg sync.WaitGroup
+
+	for range r.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for newsID := range queue {
+				if err := r.copyArticle(ctx, embedder, source, target, newsID); err != nil {
+					log.Printf("Failed to reindex news %d: %v", newsID, err)
+					failed.Add(1)
+				}
+				if n := done.Add(1); n%progressStep == 0 {
+					log.Printf("Reindexed %d of %d articles", n, len(ids))
+				}
+			}
+		}()
+	}
+
+	for _, id := range ids {
+		select {
+		case queue <- id:
+		case <-ctx.Done():
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if n := failed.Load(); n > 0 {
+		return fmt.Errorf("%d articles failed, run reindexing again to retry them", n)
+	}
+	return nil
+}
+
+func (r *Reindexer) copyArticle(ctx context.Context, embedder embedding.Embedder, source, target data.EmbeddingProfile, newsID int) error {
+	ctx, cancel := context.WithTimeout(ctx, articleTimeout)
+	defer cancel()
+
+	chunks, err := r.loadChunks(ctx, source, newsID)
+	if err != nil {
+		return err
+	}
+	if len(chunks) == 0 {
+		// nothing to embed, but chunks the profile kept of the article are removed
+		_, err := r.store.IndexArticle(ctx, target, newsID, nil, nil)
+		return err
+	}
+	texts := chunks
+	if target.Contextual {
+		chunkContext, err := r.store.LoadChunkContext(ctx, newsID)
+		if err != nil {
+			return err
+		}
+		texts = parser.ContextualChunks(chunkContext, chunks)
+	}
+	embeddings, err := embedder.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+	_, err = r.store.IndexArticle(ctx, target, newsID, chunks, embeddings)
+	return err
+}
+
+func (r *Reindexer) loadChunks(ctx context.Context, source data.EmbeddingProfile, newsID int) ([]string, error) {
+	if r.chunker == nil {
+		return r.store.LoadChunks(ctx, source, newsID)
+	}
+
+	content, err := r.store.LoadNewsContent(ctx, newsID)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		news, err := r.store.LoadNewsByID(ctx, newsID)
+		if err != nil {
+			return nil, err
+		}
+		if news == nil {
+			return nil, fmt.Errorf("news %d not found", newsID)
+		}
+		channels, err := r.store.LoadChannels(ctx, db.ChannelFilter{ID: news.ChannelID})
+		if err != nil {
+			return nil, err
+		}
+		if len(channels) == 0 {
+			return nil, fmt.Errorf("channel %d not found", news.ChannelID)
+		}
+		if content, err = parser.ChannelArticle(ctx, channels[0], *news); err != nil {
+			return nil, err
+		}
+		if err := r.store.SaveNewsContent(ctx, *content); err != nil {
+			return nil, err
+		}
+	}
+	return r.chunker.Chunk(*content)
+}
+
+func (r *Reindexer) embedSavedSearches(ctx context.Context, target data.EmbeddingProfile) (map[int][]float32, error) {
+	searches, err := r.store.LoadSavedSearches(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[int][]float32, len(searches))
+	if len(searches) == 0 {
+		return result, nil
+	}
+
+	queries := make([]string, len(searches))
+	for i, search := range searches {
+		queries[i] = search.Query
+	}
+	embeddings, err := r.embedders.Embedder(target.Model).Embed(ctx, queries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed saved searches: %w", err)
+	}
+	for i, search := range searches {
+		result[search.ID] = embeddings[i]
+	}
+	return result, nil
+}
@@ -0,0 +1,103 @@
+// Package webhook delivers saved search alerts to HTTP endpoints.
+// Every request is signed with HMAC-SHA256 so receivers can verify
+// that the payload was produced by this service and was not replayed.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-RSS-Signature"
+	TimestampHeader = "X-RSS-Timestamp"
+	signaturePrefix = "sha256="
+)
+
+// Payload is the JSON body posted to a webhook when an article matches a saved search.
+type Payload struct {
+	SearchID   int       `json:"search_id"`
+	SearchName string    `json:"search_name"`
+	Query      string    `json:"query"`
+	NewsID     int       `json:"news_id"`
+	Title      string    `json:"title"`
+	Link       string    `json:"link"`
+	PubDate    time.Time `json:"pub_date"`
+	Similarity float32   `json:"similarity"`
+	Chunk      string    `json:"chunk"`
+}
+
+type Sender struct {
+	client *http.Client
+}
+
+func NewSender(timeout time.Duration) *Sender {
+	return &Sender{client: &http.Client{Timeout: timeout}}
+}
+
+// Sign returns the signature of the body for the given timestamp.
+// The timestamp is part of the signed message to prevent replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature headers of a received webhook request.
+// Requests older than tolerance are rejected.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration) error {
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s header: %w", TimestampHeader, err)
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("timestamp is out of tolerance: %v", age)
+	}
+	expected := Sign(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(SignatureHeader))) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// Send posts the payload to the url. Any non-2xx response is treated as a failure.
+func (s *Sender) Send(ctx context.Context, url, secret string, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	if secret != "" {
+		req.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
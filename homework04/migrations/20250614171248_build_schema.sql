@@ -42,11 +42,11 @@ WITH (lists = 100);
 
 -- +goose Down
 -- +goose StatementBegin
-DROP INDEX IF EXISTS channel_news_embeddings_idx;
+DROP INDEX IF EXISTS news_embeddings_idx;
 DROP INDEX IF EXISTS channel_news_pub_date_idx;
-DROP TABLE channel_jobs;
-DROP TABLE news_embeddings;
-DROP TABLE channel_items;
-DROP TABLE channels;
+DROP TABLE IF EXISTS news_jobs;
+DROP TABLE IF EXISTS news_embeddings;
+DROP TABLE IF EXISTS channel_news;
+DROP TABLE IF EXISTS channels;
 DROP EXTENSION IF EXISTS vector;
--- +goose StatementEnd
\ No newline at end of file
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS saved_searches (
+    search_id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    query TEXT NOT NULL,
+    embedding VECTOR(1024) NOT NULL,
+    threshold REAL NOT NULL DEFAULT 0.75,
+    webhook_url TEXT NOT NULL,
+    secret TEXT NOT NULL DEFAULT '',
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS saved_search_alerts (
+    alert_id SERIAL PRIMARY KEY,
+    search_id INTEGER NOT NULL REFERENCES saved_searches(search_id) ON DELETE CASCADE,
+    news_id INTEGER NOT NULL REFERENCES channel_news(news_id) ON DELETE CASCADE,
+    similarity REAL NOT NULL,
+    chunk TEXT NOT NULL DEFAULT '',
+    status INTEGER NOT NULL DEFAULT 0, -- 0: pending, 1: delivered, 2: failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (search_id, news_id)
+);
+
+CREATE INDEX saved_search_alerts_pending_idx ON saved_search_alerts(next_attempt_at) WHERE status = 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS saved_search_alerts_pending_idx;
+DROP TABLE IF EXISTS saved_search_alerts;
+DROP TABLE IF EXISTS saved_searches;
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE news_jobs ALTER COLUMN status DROP DEFAULT;
+-- jobs stuck in progress are put back to the queue
+ALTER TABLE news_jobs ALTER COLUMN status TYPE TEXT
+    USING CASE status WHEN 2 THEN 'completed' ELSE 'pending' END;
+ALTER TABLE news_jobs ALTER COLUMN status SET DEFAULT 'pending';
+ALTER TABLE news_jobs ADD CONSTRAINT news_jobs_status_check
+    CHECK (status IN ('pending', 'running', 'completed', 'failed', 'dead'));
+ALTER TABLE news_jobs
+    ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN last_error TEXT NOT NULL DEFAULT '',
+    ADD COLUMN next_run_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN locked_until timestamp;
+
+CREATE INDEX news_jobs_runnable_idx ON news_jobs(next_run_at) WHERE status IN ('pending', 'failed');
+CREATE INDEX news_jobs_running_idx ON news_jobs(locked_until) WHERE status = 'running';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS news_jobs_running_idx;
+DROP INDEX IF EXISTS news_jobs_runnable_idx;
+ALTER TABLE news_jobs
+    DROP COLUMN locked_until,
+    DROP COLUMN next_run_at,
+    DROP COLUMN last_error,
+    DROP COLUMN attempts;
+ALTER TABLE news_jobs DROP CONSTRAINT news_jobs_status_check;
+ALTER TABLE news_jobs ALTER COLUMN status DROP DEFAULT;
+-- the integer status had no failure state, failed and dead jobs go back to pending
+ALTER TABLE news_jobs ALTER COLUMN status TYPE INTEGER
+    USING CASE status WHEN 'completed' THEN 2 WHEN 'running' THEN 1 ELSE 0 END;
+ALTER TABLE news_jobs ALTER COLUMN status SET DEFAULT 0;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION notify_channel_added() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('channel_added', NEW.channel_id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION notify_job_added() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('job_added', NEW.job_id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER channels_notify_insert AFTER INSERT ON channels
+    FOR EACH ROW EXECUTE FUNCTION notify_channel_added();
+CREATE TRIGGER news_jobs_notify_insert AFTER INSERT ON news_jobs
+    FOR EACH ROW EXECUTE FUNCTION notify_job_added();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS news_jobs_notify_insert ON news_jobs;
+DROP TRIGGER IF EXISTS channels_notify_insert ON channels;
+DROP FUNCTION IF EXISTS notify_job_added();
+DROP FUNCTION IF EXISTS notify_channel_added();
+-- +goose StatementEnd
@@ -0,0 +1,78 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS embedding_profiles (
+    profile_id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    model TEXT NOT NULL,
+    dims INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'building' CHECK (status IN ('building', 'active', 'retired')),
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    activated_at timestamp
+);
+-- only one profile serves queries at a time
+CREATE UNIQUE INDEX embedding_profiles_active_idx ON embedding_profiles(status) WHERE status = 'active';
+
+-- vectors stored so far were produced by the default 1024-dim model, a database without
+-- vectors gets its first profile from news-service --emb like a SQLite file does
+INSERT INTO embedding_profiles (name, model, dims, status, activated_at)
+SELECT 'mxbai-embed-large', 'mxbai-embed-large', 1024, 'active', CURRENT_TIMESTAMP
+WHERE EXISTS (SELECT 1 FROM news_embeddings) OR EXISTS (SELECT 1 FROM saved_searches);
+
+-- the column keeps vectors of any dimension, every profile gets its own partial index
+DROP INDEX IF EXISTS news_embeddings_idx;
+ALTER TABLE news_embeddings ALTER COLUMN embedding TYPE vector;
+ALTER TABLE news_embeddings
+    ADD COLUMN profile_id INTEGER REFERENCES embedding_profiles(profile_id) ON DELETE CASCADE,
+    ADD COLUMN model TEXT,
+    ADD COLUMN dims INTEGER;
+UPDATE news_embeddings SET
+    profile_id = (SELECT profile_id FROM embedding_profiles WHERE name = 'mxbai-embed-large'),
+    model = 'mxbai-embed-large',
+    dims = 1024;
+ALTER TABLE news_embeddings
+    ALTER COLUMN profile_id SET NOT NULL,
+    ALTER COLUMN model SET NOT NULL,
+    ALTER COLUMN dims SET NOT NULL,
+    ADD CONSTRAINT news_embeddings_dims_check CHECK (vector_dims(embedding) = dims);
+CREATE INDEX news_embeddings_profile_news_idx ON news_embeddings(profile_id, news_id);
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM embedding_profiles WHERE profile_id = 1) THEN
+        CREATE INDEX news_embeddings_profile_1_idx ON news_embeddings
+        USING ivfflat ((embedding::vector(1024)) vector_cosine_ops)
+        WITH (lists = 100)
+        WHERE profile_id = 1;
+    END IF;
+END $$;
+
+ALTER TABLE saved_searches ALTER COLUMN embedding TYPE vector;
+ALTER TABLE saved_searches ADD COLUMN profile_id INTEGER REFERENCES embedding_profiles(profile_id);
+UPDATE saved_searches SET profile_id = (SELECT profile_id FROM embedding_profiles WHERE status = 'active');
+ALTER TABLE saved_searches ALTER COLUMN profile_id SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- only vectors of the active profile can be kept in a VECTOR(1024) column
+DELETE FROM news_embeddings WHERE profile_id <> (SELECT profile_id FROM embedding_profiles WHERE status = 'active');
+DELETE FROM news_embeddings WHERE dims <> 1024;
+DELETE FROM saved_searches WHERE vector_dims(embedding) <> 1024;
+
+ALTER TABLE saved_searches DROP COLUMN profile_id;
+ALTER TABLE saved_searches ALTER COLUMN embedding TYPE VECTOR(1024);
+
+DROP INDEX IF EXISTS news_embeddings_profile_1_idx;
+DROP INDEX IF EXISTS news_embeddings_profile_news_idx;
+ALTER TABLE news_embeddings
+    DROP CONSTRAINT news_embeddings_dims_check,
+    DROP COLUMN dims,
+    DROP COLUMN model,
+    DROP COLUMN profile_id;
+ALTER TABLE news_embeddings ALTER COLUMN embedding TYPE VECTOR(1024);
+CREATE INDEX news_embeddings_idx ON news_embeddings
+USING ivfflat (embedding vector_cosine_ops)
+WITH (lists = 100);
+
+DROP INDEX IF EXISTS embedding_profiles_active_idx;
+DROP TABLE IF EXISTS embedding_profiles;
+-- +goose StatementEnd
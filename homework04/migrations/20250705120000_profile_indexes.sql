@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE embedding_profiles
+    ADD COLUMN index_type TEXT NOT NULL DEFAULT 'hnsw' CHECK (index_type IN ('ivfflat', 'hnsw')),
+    ADD COLUMN ivfflat_lists INTEGER NOT NULL DEFAULT 100,
+    ADD COLUMN ivfflat_probes INTEGER NOT NULL DEFAULT 10,
+    ADD COLUMN hnsw_m INTEGER NOT NULL DEFAULT 16,
+    ADD COLUMN hnsw_ef_construction INTEGER NOT NULL DEFAULT 64,
+    ADD COLUMN hnsw_ef_search INTEGER NOT NULL DEFAULT 40;
+
+-- ivfflat lists are computed when the index is built, an index built on an empty
+-- table never gets good recall; HNSW does not depend on the data at build time.
+-- Only the profile backfilled from existing vectors has an index yet.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM embedding_profiles WHERE profile_id = 1) THEN
+        DROP INDEX IF EXISTS news_embeddings_profile_1_idx;
+        UPDATE embedding_profiles SET index_type = 'hnsw' WHERE profile_id = 1;
+        CREATE INDEX news_embeddings_profile_1_idx ON news_embeddings
+        USING hnsw ((embedding::vector(1024)) vector_cosine_ops)
+        WITH (m = 16, ef_construction = 64)
+        WHERE profile_id = 1;
+    END IF;
+END $$;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM embedding_profiles WHERE profile_id = 1 AND dims = 1024) THEN
+        DROP INDEX IF EXISTS news_embeddings_profile_1_idx;
+        CREATE INDEX news_embeddings_profile_1_idx ON news_embeddings
+        USING ivfflat ((embedding::vector(1024)) vector_cosine_ops)
+        WITH (lists = 100)
+        WHERE profile_id = 1;
+    END IF;
+END $$;
+
+ALTER TABLE embedding_profiles
+    DROP COLUMN hnsw_ef_search,
+    DROP COLUMN hnsw_ef_construction,
+    DROP COLUMN hnsw_m,
+    DROP COLUMN ivfflat_probes,
+    DROP COLUMN ivfflat_lists,
+    DROP COLUMN index_type;
+-- +goose StatementEnd
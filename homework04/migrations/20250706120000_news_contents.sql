@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS news_contents (
+    news_id INTEGER PRIMARY KEY REFERENCES channel_news(news_id) ON DELETE CASCADE,
+    title TEXT NOT NULL DEFAULT '',
+    byline TEXT NOT NULL DEFAULT '',
+    excerpt TEXT NOT NULL DEFAULT '',
+    site_name TEXT NOT NULL DEFAULT '',
+    image TEXT NOT NULL DEFAULT '',
+    length INTEGER NOT NULL DEFAULT 0,
+    html TEXT NOT NULL DEFAULT '', -- cleaned article markup produced by readability
+    text TEXT NOT NULL DEFAULT '', -- plain text used for chunking
+    fetched_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS news_contents;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- existing vectors were produced by the fixed sentence chunker without overlap
+ALTER TABLE embedding_profiles ADD COLUMN chunker TEXT NOT NULL DEFAULT 'sentence:size=400,overlap=0,tokenizer=words';
+ALTER TABLE embedding_profiles ALTER COLUMN chunker DROP DEFAULT;
+
+ALTER TABLE news_embeddings ADD COLUMN chunker TEXT NOT NULL DEFAULT 'sentence:size=400,overlap=0,tokenizer=words';
+ALTER TABLE news_embeddings ALTER COLUMN chunker DROP DEFAULT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE news_embeddings DROP COLUMN IF EXISTS chunker;
+ALTER TABLE embedding_profiles DROP COLUMN IF EXISTS chunker;
+-- +goose StatementEnd
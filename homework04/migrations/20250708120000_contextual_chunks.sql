@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- contextual profiles embed chunks with a header of the article title, channel and date
+ALTER TABLE embedding_profiles ADD COLUMN contextual BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE embedding_profiles DROP COLUMN IF EXISTS contextual;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE channels
+    ADD COLUMN name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN paused BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN poll_interval INTEGER NOT NULL DEFAULT 0, -- seconds, 0 uses the interval of channel-service
+    ADD COLUMN full_articles BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN headers JSONB NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE channels
+    DROP COLUMN IF EXISTS headers,
+    DROP COLUMN IF EXISTS full_articles,
+    DROP COLUMN IF EXISTS poll_interval,
+    DROP COLUMN IF EXISTS paused,
+    DROP COLUMN IF EXISTS name;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS channel_groups (
+    group_id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS channel_group_members (
+    group_id INTEGER NOT NULL REFERENCES channel_groups(group_id) ON DELETE CASCADE,
+    channel_id INTEGER NOT NULL REFERENCES channels(channel_id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, channel_id)
+);
+CREATE INDEX channel_group_members_channel_idx ON channel_group_members(channel_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS channel_group_members_channel_idx;
+DROP TABLE IF EXISTS channel_group_members;
+DROP TABLE IF EXISTS channel_groups;
+-- +goose StatementEnd
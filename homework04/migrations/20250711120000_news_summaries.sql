@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS news_summaries (
+    news_id INTEGER NOT NULL REFERENCES channel_news(news_id) ON DELETE CASCADE,
+    model TEXT NOT NULL, -- generative model which wrote the summary
+    summary TEXT NOT NULL,
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (news_id, model)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS news_summaries;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- a saved search scoped to a group only raises alerts for news of its channels
+ALTER TABLE saved_searches ADD COLUMN IF NOT EXISTS group_id INTEGER REFERENCES channel_groups(group_id) ON DELETE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE saved_searches DROP COLUMN IF EXISTS group_id;
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS users (
+    user_id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS subscriptions (
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    channel_id INTEGER NOT NULL REFERENCES channels(channel_id) ON DELETE CASCADE,
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, channel_id)
+);
+CREATE INDEX subscriptions_channel_idx ON subscriptions(channel_id);
+CREATE TABLE IF NOT EXISTS news_states (
+    user_id INTEGER NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    news_id INTEGER NOT NULL REFERENCES channel_news(news_id) ON DELETE CASCADE,
+    read BOOLEAN NOT NULL DEFAULT FALSE,
+    starred BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, news_id)
+);
+CREATE INDEX news_states_news_idx ON news_states(news_id);
+ALTER TABLE api_keys ADD COLUMN user_id INTEGER REFERENCES users(user_id) ON DELETE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE api_keys DROP COLUMN IF EXISTS user_id;
+DROP INDEX IF EXISTS news_states_news_idx;
+DROP TABLE IF EXISTS news_states;
+DROP INDEX IF EXISTS subscriptions_channel_idx;
+DROP TABLE IF EXISTS subscriptions;
+DROP TABLE IF EXISTS users;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- a replica leases the alerts it delivers, so other replicas do not post them again
+ALTER TABLE saved_search_alerts ADD COLUMN locked_until timestamp;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE saved_search_alerts DROP COLUMN IF EXISTS locked_until;
+-- +goose StatementEnd
@@ -0,0 +1,8 @@
+// Package migrations embeds the goose migrations of the Postgres schema, so the
+// binaries apply them without the migration files next to them.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,243 @@
+// Package openapi embeds the OpenAPI 3 specification of api-service. The service
+// serves it and validates requests against it, the client package is generated from it.
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//go:embed openapi.json
+var Spec []byte
+
+// Document is the part of an OpenAPI document used by the service and the client
+// generator. Parameters and responses are resolved while loading, schemas keep their
+// references so the generator can name their types.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"` // path, lower case method
+	Components Components                       `json:"components"`
+	Security   []SecurityRequirement            `json:"security"` // of operations which declare none
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Components struct {
+	Schemas    map[string]*Schema    `json:"schemas"`
+	Parameters map[string]*Parameter `json:"parameters"`
+	Responses  map[string]*Response  `json:"responses"`
+	// SecuritySchemes tell where the credentials of a request are found.
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme is an apiKey scheme (a header or a query parameter) or an http bearer scheme.
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	In     string `json:"in"`
+	Name   string `json:"name"`
+	Scheme string `json:"scheme"`
+}
+
+// SecurityRequirement maps the names of schemes to the scopes they need, an operation
+// is allowed when one of its requirements is met.
+type SecurityRequirement map[string][]string
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Tags        []string             `json:"tags"`
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+	// Security overrides the requirements of the document, empty for public operations.
+	Security []SecurityRequirement `json:"security"`
+}
+
+type Parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path or query
+	Required    bool    `json:"required"`
+	Description string  `json:"description"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref         string                `json:"$ref"`
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of JSON Schema used by the specification.
+type Schema struct {
+	Ref                  string     `json:"$ref"`
+	Type                 string     `json:"type"`
+	Format               string     `json:"format"`
+	Description          string     `json:"description"`
+	Enum                 []any      `json:"enum"`
+	Nullable             bool       `json:"nullable"`
+	Properties           Properties `json:"properties"`
+	Required             []string   `json:"required"`
+	AdditionalProperties any        `json:"additionalProperties"` // false, or the schema of the values
+	Items                *Schema    `json:"items"`
+	Minimum              *float64   `json:"minimum"`
+	Maximum              *float64   `json:"maximum"`
+	MinLength            *int       `json:"minLength"`
+	// Partial marks request bodies which change only the fields they contain, the
+	// generated client makes their optional fields pointers.
+	Partial bool `json:"x-partial"`
+}
+
+// Property is a property of an object schema, in the order of the specification.
+type Property struct {
+	Name   string
+	Schema *Schema
+}
+
+type Properties []Property
+
+func (p *Properties) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil {
+		return err
+	} else if token != json.Delim('{') {
+		return fmt.Errorf("properties must be an object")
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		property := Property{Name: token.(string)}
+		if err := decoder.Decode(&property.Schema); err != nil {
+			return fmt.Errorf("property %s: %w", property.Name, err)
+		}
+		*p = append(*p, property)
+	}
+	return nil
+}
+
+// Property returns the schema of the property, nil when there is none.
+func (s *Schema) Property(name string) *Schema {
+	for _, property := range s.Properties {
+		if property.Name == name {
+			return property.Schema
+		}
+	}
+	return nil
+}
+
+// AdditionalSchema returns the schema of additional properties, nil when they are
+// not allowed or not described.
+func (s *Schema) AdditionalSchema() *Schema {
+	object, ok := s.AdditionalProperties.(map[string]any)
+	if !ok {
+		return nil
+	}
+	encoded, _ := json.Marshal(object)
+	var schema Schema
+	if json.Unmarshal(encoded, &schema) != nil {
+		return nil
+	}
+	return &schema
+}
+
+// Load parses the embedded specification.
+func Load() (*Document, error) {
+	return Parse(Spec)
+}
+
+// Parse parses a specification and resolves the references of its parameters and responses.
+func Parse(spec []byte) (*Document, error) {
+	var document Document
+	if err := json.Unmarshal(spec, &document); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	for path, operations := range document.Paths {
+		for method, operation := range operations {
+			for i, parameter := range operation.Parameters {
+				if parameter.Ref == "" {
+					continue
+				}
+				resolved, ok := document.Components.Parameters[refName(parameter.Ref, "parameters")]
+				if !ok {
+					return nil, fmt.Errorf("%s %s: unknown parameter %s", method, path, parameter.Ref)
+				}
+				operation.Parameters[i] = resolved
+			}
+			for status, response := range operation.Responses {
+				if response.Ref == "" {
+					continue
+				}
+				resolved, ok := document.Components.Responses[refName(response.Ref, "responses")]
+				if !ok {
+					return nil, fmt.Errorf("%s %s: unknown response %s", method, path, response.Ref)
+				}
+				operation.Responses[status] = resolved
+			}
+		}
+	}
+	return &document, nil
+}
+
+// Operation returns the operation of the method and the path, e.g. GET /channels/{id},
+// nil when there is none.
+func (d *Document) Operation(method, path string) *Operation {
+	return d.Paths[path][strings.ToLower(method)]
+}
+
+// Requirements returns the security requirements of the operation, none when it is public.
+func (d *Document) Requirements(operation *Operation) []SecurityRequirement {
+	if operation.Security != nil {
+		return operation.Security
+	}
+	return d.Security
+}
+
+// Resolve follows the reference of the schema, the schema itself is returned when it has none.
+func (d *Document) Resolve(schema *Schema) (*Schema, error) {
+	for schema != nil && schema.Ref != "" {
+		resolved, ok := d.Components.Schemas[SchemaName(schema)]
+		if !ok {
+			return nil, fmt.Errorf("unknown schema %s", schema.Ref)
+		}
+		schema = resolved
+	}
+	return schema, nil
+}
+
+// SchemaName is the name of a referenced schema, empty for inline schemas.
+func SchemaName(schema *Schema) string {
+	return refName(schema.Ref, "schemas")
+}
+
+func refName(ref, kind string) string {
+	return strings.TrimPrefix(ref, "#/components/"+kind+"/")
+}
+
+// Methods lists the methods of a path in a stable order.
+func (d *Document) Methods(path string) []string {
+	methods := make([]string, 0, len(d.Paths[path]))
+	for method := range d.Paths[path] {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
@@ -0,0 +1,174 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ValidateParameter checks the raw value of a path or query parameter against its schema.
+func (d *Document) ValidateParameter(parameter *Parameter, value string) error {
+	schema, err := d.Resolve(parameter.Schema)
+	if err != nil || schema == nil {
+		return err
+	}
+	var parsed any = value
+	switch schema.Type {
+	case "integer":
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be an integer", parameter.Name)
+		}
+		parsed = json.Number(strconv.FormatInt(number, 10))
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%s must be a number", parameter.Name)
+		}
+		parsed = json.Number(value)
+	case "boolean":
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", parameter.Name)
+		}
+		parsed = flag
+	}
+	return d.ValidateValue(schema, parsed, parameter.Name)
+}
+
+// ValidateValue checks a JSON value decoded with UseNumber against the schema, the
+// error names the offending field by its path, e.g. auth.token.
+func (d *Document) ValidateValue(schema *Schema, value any, path string) error {
+	schema, err := d.Resolve(schema)
+	if err != nil || schema == nil {
+		return err
+	}
+	if value == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%s must not be null", name(path))
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s must be an object", name(path))
+		}
+		return d.validateObject(schema, object, path)
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s must be an array", name(path))
+		}
+		for i, item := range items {
+			if err := d.ValidateValue(schema.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", name(path))
+		}
+		if schema.MinLength != nil && len([]rune(strings.TrimSpace(text))) < *schema.MinLength {
+			if *schema.MinLength == 1 {
+				return fmt.Errorf("%s must not be empty", name(path))
+			}
+			return fmt.Errorf("%s must have at least %d characters", name(path), *schema.MinLength)
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s must be a number", name(path))
+		}
+		parsed, err := number.Float64()
+		if err != nil {
+			return fmt.Errorf("%s must be a number", name(path))
+		}
+		if schema.Type == "integer" {
+			if _, err := number.Int64(); err != nil {
+				return fmt.Errorf("%s must be an integer", name(path))
+			}
+		}
+		if schema.Minimum != nil && parsed < *schema.Minimum {
+			return fmt.Errorf("%s must be at least %v", name(path), *schema.Minimum)
+		}
+		if schema.Maximum != nil && parsed > *schema.Maximum {
+			return fmt.Errorf("%s must be at most %v", name(path), *schema.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be true or false", name(path))
+		}
+	}
+
+	if len(schema.Enum) > 0 && !slices.ContainsFunc(schema.Enum, func(allowed any) bool {
+		return fmt.Sprint(allowed) == fmt.Sprint(value)
+	}) {
+		return fmt.Errorf("%s must be one of %s", name(path), enumList(schema.Enum))
+	}
+	return nil
+}
+
+func (d *Document) validateObject(schema *Schema, object map[string]any, path string) error {
+	for _, required := range schema.Required {
+		if _, ok := object[required]; !ok {
+			return fmt.Errorf("%s is required", join(path, required))
+		}
+	}
+	// properties in the order of the specification, the first error is stable
+	for _, property := range schema.Properties {
+		if value, ok := object[property.Name]; ok {
+			if err := d.ValidateValue(property.Schema, value, join(path, property.Name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	additional := schema.AdditionalSchema()
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		if schema.Property(key) == nil {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		switch {
+		case additional != nil:
+			if err := d.ValidateValue(additional, object[key], join(path, key)); err != nil {
+				return err
+			}
+		case schema.AdditionalProperties == false:
+			return fmt.Errorf("%s is not a known field", join(path, key))
+		}
+	}
+	return nil
+}
+
+func join(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// name is the path of a value in an error, the root of a body is "body".
+func name(path string) string {
+	if path == "" {
+		return "body"
+	}
+	return path
+}
+
+func enumList(values []any) string {
+	names := make([]string, len(values))
+	for i, value := range values {
+		names[i] = fmt.Sprint(value)
+	}
+	return strings.Join(names, ", ")
+}